@@ -30,6 +30,9 @@ const (
 	EP // en passant file bitboard
 )
 
+// PieceColour maps piece type to the colour of the piece (Both for NoPiece)
+var PieceColour = [13]int{Both, White, White, White, White, White, White, Black, Black, Black, Black, Black, Black}
+
 // IsSlider maps piece type to information if it is a sliding piece or not (i.e. rook, bishop, queen)
 var IsSlider = map[int]bool{
 	WP: false,
@@ -136,9 +139,15 @@ type Board struct {
 	Side              int
 	castlePermissions int
 	material          [2]int             // material scores for black and white
+	psqt              EvalScore          // piece-square table values of all pieces (white minus black)
+	phase             int                // game phase of the pieces on the board, not capped at TotalPhase
 	ply               int                // how many half moves have been made
 	fiftyMove         int                // how many moves from the fifty move rule have been made
+	fullMove          int                // fullmove number, starts at 1 and is incremented after black's move
+	params            *EvalParams        // evaluation parameters, kept when the board is reset
+	pawnTable         *PawnHashTable     // caches the pawn structure evaluation, only set during a search
 	positionKey       uint64             // position key is a unique key stored for each position (used to keep track of 3fold repetition)
+	pawnKey           uint64             // key of the pawn placement only, used to index the pawn hash table
 	history           [MaxGameMoves]Undo // array that stores current position and variables before a move is made
 }
 
@@ -158,9 +167,16 @@ func (board *Board) Reset() {
 	board.castlePermissions = 0
 	board.material[White] = 0
 	board.material[Black] = 0
+	board.psqt = EvalScore{}
+	board.phase = 0
 	board.ply = 0
 	board.fiftyMove = 0
+	board.fullMove = 1
 	board.positionKey = 0
+	board.pawnKey = 0
+	if board.params == nil {
+		board.params = defaultEvalParams
+	}
 }
 
 // String Return string representing the current board (from the stored bitboards)
@@ -349,3 +365,13 @@ func (board *Board) GetMoves() (moveList MoveList) {
 	}
 	return moveList
 }
+
+// GetCaptures Returns a struct that holds all the legal captures and promotions for a given position
+func (board *Board) GetCaptures() (moveList MoveList) {
+	if board.Side == White {
+		board.LegalCapturesWhite(&moveList)
+	} else {
+		board.LegalCapturesBlack(&moveList)
+	}
+	return moveList
+}
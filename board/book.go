@@ -0,0 +1,158 @@
+package board
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+)
+
+// bookEntrySize size in bytes of an entry of a Polyglot book
+const bookEntrySize = 16
+
+// bookEntry one move of a position in a Polyglot book. All values are stored big endian
+type bookEntry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+	learn  uint32
+}
+
+// BookMove a legal move from an opening book and its weight. Moves with higher weights were played more
+// often or more successfully
+type BookMove struct {
+	Move   int
+	Weight int
+}
+
+// Book Polyglot opening book (.bin). The entries are sorted by the Polyglot key of their position
+type Book struct {
+	entries []bookEntry
+}
+
+// LoadBook reads a Polyglot opening book file
+func LoadBook(filename string) (*Book, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseBook(data)
+}
+
+// ReadBook reads a Polyglot opening book
+func ReadBook(reader io.Reader) (*Book, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return parseBook(data)
+}
+
+func parseBook(data []byte) (*Book, error) {
+	if len(data)%bookEntrySize != 0 {
+		return nil, fmt.Errorf("Invalid book size %d, expected a multiple of %d bytes", len(data), bookEntrySize)
+	}
+
+	book := &Book{entries: make([]bookEntry, len(data)/bookEntrySize)}
+	for i := range book.entries {
+		entry := data[i*bookEntrySize:]
+		book.entries[i] = bookEntry{
+			key:    binary.BigEndian.Uint64(entry),
+			move:   binary.BigEndian.Uint16(entry[8:]),
+			weight: binary.BigEndian.Uint16(entry[10:]),
+			learn:  binary.BigEndian.Uint32(entry[12:]),
+		}
+		if i > 0 && book.entries[i].key < book.entries[i-1].key {
+			return nil, fmt.Errorf("Invalid book, the entries are not sorted")
+		}
+	}
+	return book, nil
+}
+
+// Size returns the number of entries of the book
+func (book *Book) Size() int {
+	return len(book.entries)
+}
+
+// Moves returns the legal book moves of the position, ordered by decreasing weight
+func (book *Book) Moves(board *Board) (moves []BookMove) {
+	key := board.PolyglotKey()
+	first := sort.Search(len(book.entries), func(i int) bool { return book.entries[i].key >= key })
+
+	moveList := board.GetMoves()
+	for i := first; i < len(book.entries) && book.entries[i].key == key; i++ {
+		if move, found := board.bookMove(&moveList, book.entries[i].move); found {
+			moves = append(moves, BookMove{move, int(book.entries[i].weight)})
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].Weight > moves[j].Weight })
+	return moves
+}
+
+// BestMove returns the book move with the highest weight
+func (book *Book) BestMove(board *Board) (int, bool) {
+	moves := book.Moves(board)
+	if len(moves) == 0 {
+		return 0, false
+	}
+	return moves[0].Move, true
+}
+
+// RandomMove returns a random book move, the probability of a move is proportional to its weight.
+// Moves with weight 0 are never played
+func (book *Book) RandomMove(board *Board, random *rand.Rand) (int, bool) {
+	moves := book.Moves(board)
+	total := 0
+	for _, move := range moves {
+		total += move.Weight
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	choice := random.Intn(total)
+	for _, move := range moves {
+		if choice < move.Weight {
+			return move.Move, true
+		}
+		choice -= move.Weight
+	}
+	return 0, false
+}
+
+// bookMove returns the legal move of a Polyglot move. Polyglot moves store the squares (from a1) in
+// bits 0-5 (to) and 6-11 (from) and the promotion piece (knight 1 to queen 4) in bits 12-14.
+// Castling is stored as the king capturing its own rook
+func (board *Board) bookMove(moveList *MoveList, polyglotMove uint16) (int, bool) {
+	to := int(polyglotMove&0x3F) ^ 56
+	from := int(polyglotMove>>6&0x3F) ^ 56
+	promotion := int(polyglotMove >> 12 & 0x7)
+
+	if piece := board.position[from]; piece == WK || piece == BK {
+		if board.position[to] == piece-WK+WR {
+			if to > from {
+				to = from + 2
+			} else {
+				to = from - 2
+			}
+		}
+	}
+
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		move := moveList.Moves[moveNum].Move
+		if FromSq(move) != from || ToSq(move) != to {
+			continue
+		}
+		promoted := Promoted(move)
+		if promotion == 0 && promoted == NoPiece {
+			return move, true
+		}
+		// the promotion pieces are knight, bishop, rook, queen in both encodings
+		if promoted != NoPiece && (promoted-WN)%6 == promotion-1 {
+			return move, true
+		}
+	}
+	return 0, false
+}
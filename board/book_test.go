@@ -0,0 +1,135 @@
+package board
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+func TestPolyglotKey(t *testing.T) {
+	InitHashKeys()
+
+	// keys from the specification of the Polyglot book format
+	tests := []struct {
+		moves string
+		key   uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(StartingPosition)
+		if test.moves != "" {
+			if err := board.MakeMoves(test.moves); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if key := board.PolyglotKey(); key != test.key {
+			t.Errorf("%s: expected %016x, got %016x", test.moves, test.key, key)
+		}
+	}
+}
+
+func TestHashKeysReproducible(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	key := board.positionKey
+
+	InitHashKeys()
+	board.ParseFen(StartingPosition)
+	if board.positionKey != key {
+		t.Errorf("Expected the same hashkeys after every initialization")
+	}
+}
+
+// writeTestBook returns a Polyglot book of the given entries, which have to be sorted
+func writeTestBook(entries []bookEntry) []byte {
+	var book bytes.Buffer
+	for _, entry := range entries {
+		binary.Write(&book, binary.BigEndian, entry)
+	}
+	return book.Bytes()
+}
+
+// polyglotMove returns the Polyglot encoding of a move given by its squares
+func polyglotMove(from, to string, promotion uint16) uint16 {
+	fromSq, _ := parseSquare(from)
+	toSq, _ := parseSquare(to)
+	return uint16(toSq^56) | uint16(fromSq^56)<<6 | promotion<<12
+}
+
+func TestBook(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	start := board.PolyglotKey()
+	board.ParseFen("r3k3/1P6/8/8/8/8/8/4K2R w Kq - 0 1")
+	castling := board.PolyglotKey()
+
+	entries := []bookEntry{
+		{key: start, move: polyglotMove("e2", "e4", 0), weight: 30},
+		{key: start, move: polyglotMove("d2", "d4", 0), weight: 10},
+		{key: start, move: polyglotMove("g1", "f3", 0), weight: 0},
+		{key: start, move: polyglotMove("e2", "e5", 0), weight: 100}, // illegal
+		{key: castling, move: polyglotMove("e1", "h1", 0), weight: 1},
+		{key: castling, move: polyglotMove("b7", "a8", 2), weight: 2},
+	}
+	if castling < start {
+		entries = append(entries[4:], entries[:4]...)
+	}
+	book, err := ReadBook(bytes.NewReader(writeTestBook(entries)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Size() != len(entries) {
+		t.Errorf("Expected %d entries, got %d", len(entries), book.Size())
+	}
+
+	board.ParseFen(StartingPosition)
+	moves := book.Moves(&board)
+	if len(moves) != 3 || GetMoveString(moves[0].Move) != "e2e4" || moves[0].Weight != 30 {
+		t.Errorf("Expected e2e4, d2d4 and g1f3, got %v", moves)
+	}
+	if move, found := book.BestMove(&board); !found || GetMoveString(move) != "e2e4" {
+		t.Errorf("Expected the best move e2e4, got %s", GetMoveString(move))
+	}
+
+	// the moves are chosen in proportion to their weights
+	counts := map[string]int{}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		move, _ := book.RandomMove(&board, random)
+		counts[GetMoveString(move)]++
+	}
+	if counts["g1f3"] != 0 || counts["e2e4"] < 2700 || counts["e2e4"] > 3300 || counts["d2d4"] < 700 {
+		t.Errorf("Expected e2e4 three times as often as d2d4, got %v", counts)
+	}
+
+	board.ParseFen("r3k3/1P6/8/8/8/8/8/4K2R w Kq - 0 1")
+	moves = book.Moves(&board)
+	if len(moves) != 2 || GetMoveString(moves[0].Move) != "b7a8b" || GetMoveString(moves[1].Move) != "e1g1" {
+		t.Errorf("Expected the promotion b7a8b and castling e1g1, got %v", moves)
+	}
+
+	board.ParseFen("4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	if _, found := book.RandomMove(&board, random); found {
+		t.Errorf("Expected no book move")
+	}
+
+	if _, err := ReadBook(bytes.NewReader(make([]byte, 17))); err == nil {
+		t.Errorf("Expected an error for an invalid size")
+	}
+	if _, err := ReadBook(bytes.NewReader(writeTestBook([]bookEntry{{key: 2}, {key: 1}}))); err == nil {
+		t.Errorf("Expected an error for unsorted entries")
+	}
+}
@@ -0,0 +1,328 @@
+package board
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// KnownWin score of endgames that are won but in which the search can't see the mate yet
+const KnownWin int = 10000
+
+// endgame specialised evaluation function for a material signature. The score is from the
+// perspective of the strong side, i.e. the side the signature was registered for
+type endgame struct {
+	name   string
+	strong int
+	eval   func(board *Board, strong int) int
+}
+
+// endgames specialised evaluation functions indexed by the material key of their signature
+var endgames = map[uint64]endgame{}
+
+// endgameMaxPieces number of pieces (including the kings) of the largest registered signature
+var endgameMaxPieces int
+
+func init() {
+	registerEndgame("KPvK", evalKPK)
+	registerEndgame("KQvK", evalKXK)
+	registerEndgame("KRvK", evalKXK)
+	registerEndgame("KBNvK", evalKBNK)
+
+	generateKPK()
+}
+
+// materialKey packs the number of pieces of every piece type into a key
+func materialKey(counts [13]int) (key uint64) {
+	for piece := WP; piece <= BK; piece++ {
+		key |= uint64(counts[piece]) << (4 * uint(piece))
+	}
+	return key
+}
+
+// registerEndgame registers an evaluation function for a signature like "KBNvK" where
+// the pieces before the 'v' belong to the strong side. The signature is registered for both colours
+func registerEndgame(signature string, eval func(board *Board, strong int) int) {
+	sides := strings.Split(signature, "v")
+
+	for strong := White; strong <= Black; strong++ {
+		var counts [13]int
+		for side, pieces := range sides {
+			// the strong side's pieces are the first part of the signature
+			colour := side ^ strong
+			for _, char := range pieces {
+				counts[colour*6+strings.IndexRune(PieceChar, char)]++
+			}
+		}
+		endgames[materialKey(counts)] = endgame{name: signature, strong: strong, eval: eval}
+	}
+
+	if pieceNum := len(signature) - 1; pieceNum > endgameMaxPieces {
+		endgameMaxPieces = pieceNum
+	}
+}
+
+// probeEndgame returns the evaluation from white's perspective if there is a specialised
+// evaluation function for the material signature of the position
+func (board *Board) probeEndgame() (score int, name string, found bool) {
+	if bits.OnesCount64(board.sidePieces(White)|board.sidePieces(Black)) > endgameMaxPieces {
+		return 0, "", false
+	}
+
+	endgame, found := endgames[materialKey(board.pieceCounts())]
+	if !found {
+		return 0, "", false
+	}
+	score = endgame.eval(board, endgame.strong)
+	if endgame.strong == Black {
+		score = -score
+	}
+	return score, endgame.name, true
+}
+
+// squareDistance returns the number of king moves between two squares
+func squareDistance(sq1, sq2 int) int {
+	fileDistance, rankDistance := abs(sq1%8-sq2%8), abs(sq1/8-sq2/8)
+	if fileDistance > rankDistance {
+		return fileDistance
+	}
+	return rankDistance
+}
+
+// pushToEdge bonus for driving the king to the edge of the board (0 in the centre, 120 in the corners)
+func pushToEdge(sq int) int {
+	file, rank := sq%8, sq/8
+	centreDistance := 0
+	if file < 4 {
+		centreDistance += 3 - file
+	} else {
+		centreDistance += file - 4
+	}
+	if rank < 4 {
+		centreDistance += 3 - rank
+	} else {
+		centreDistance += rank - 4
+	}
+	return 20 * centreDistance
+}
+
+// pushClose bonus for bringing the kings close to each other
+func pushClose(sq1, sq2 int) int {
+	return 140 - 20*squareDistance(sq1, sq2)
+}
+
+// evalKXK drives the lone king to the edge with the attacking king close to it, e.g. KQK and KRK
+func evalKXK(board *Board, strong int) int {
+	weak := strong ^ 1
+	strongKing := bits.TrailingZeros64(board.bitboards[strong*6+WK])
+	weakKing := bits.TrailingZeros64(board.bitboards[weak*6+WK])
+
+	material := board.material[strong] - board.material[weak]
+	return KnownWin + material + pushToEdge(weakKing) + pushClose(strongKing, weakKing)
+}
+
+// evalKBNK drives the lone king to a corner of the colour of the bishop, the only corners it can be mated in
+func evalKBNK(board *Board, strong int) int {
+	weak := strong ^ 1
+	strongKing := bits.TrailingZeros64(board.bitboards[strong*6+WK])
+	weakKing := bits.TrailingZeros64(board.bitboards[weak*6+WK])
+
+	// a8 and h1 are light, h8 and a1 are dark
+	corners := [2]int{7, 56}
+	if board.bitboards[strong*6+WB]&LightSquares != 0 {
+		corners = [2]int{0, 63}
+	}
+	cornerDistance := 14
+	for _, corner := range corners {
+		distance := abs(weakKing%8-corner%8) + abs(weakKing/8-corner/8)
+		if distance < cornerDistance {
+			cornerDistance = distance
+		}
+	}
+
+	material := board.material[strong] - board.material[weak]
+	return KnownWin + material + 20*(14-cornerDistance) + pushClose(strongKing, weakKing)
+}
+
+// evalKPK uses the KPK bitbase, won positions are scored higher the further the pawn has advanced
+func evalKPK(board *Board, strong int) int {
+	weak := strong ^ 1
+	strongKing := bits.TrailingZeros64(board.bitboards[strong*6+WK])
+	weakKing := bits.TrailingZeros64(board.bitboards[weak*6+WK])
+	pawn := bits.TrailingZeros64(board.bitboards[strong*6+WP])
+	side := board.Side
+
+	// the bitbase is for a white pawn, black is flipped vertically
+	if strong == Black {
+		strongKing, weakKing, pawn = strongKing^56, weakKing^56, pawn^56
+		side ^= 1
+	}
+	if !probeKPK(side, strongKing, weakKing, pawn) {
+		return 0
+	}
+	rank := 7 - pawn/8
+	return KnownWin + PieceValue[WP] + 20*rank
+}
+
+// Results of positions during the KPK bitbase generation. The values are bit flags
+// so the results of all successors of a position can be combined
+const (
+	kpkInvalid uint8 = 0
+	kpkUnknown uint8 = 1
+	kpkDraw    uint8 = 2
+	kpkWin     uint8 = 4
+)
+
+// kpkSize number of positions in the KPK bitbase: side to move, both kings and
+// the pawn on the files a to d (the other files are mirrored) on ranks 2 to 7
+const kpkSize int = 2 * 64 * 64 * 24
+
+// kpkBitbase has a bit set for every position that white wins
+var kpkBitbase [kpkSize / 64]uint64
+
+// kpkIndex returns the bitbase index of a position with the pawn on the files a to d
+func kpkIndex(side, whiteKing, blackKing, pawn int) int {
+	pawnIdx := (pawn%8)*6 + pawn/8 - 1
+	return ((side*64+blackKing)*64+whiteKing)*24 + pawnIdx
+}
+
+// probeKPK returns true if white (with the pawn) wins the position
+func probeKPK(side, whiteKing, blackKing, pawn int) bool {
+	// the files e to h are mirrored to the files a to d
+	if pawn%8 >= 4 {
+		whiteKing, blackKing, pawn = whiteKing^7, blackKing^7, pawn^7
+	}
+	idx := kpkIndex(side, whiteKing, blackKing, pawn)
+	return kpkBitbase[idx/64]&(1<<uint(idx%64)) != 0
+}
+
+// kingAdjacent returns the squares next to a square, which doesn't depend on InitHashKeys
+func kingAdjacent(sq int) (adjacent uint64) {
+	for other := 0; other < BoardSquareNum; other++ {
+		if squareDistance(sq, other) == 1 {
+			adjacent |= 1 << uint(other)
+		}
+	}
+	return adjacent
+}
+
+// whitePawnAttacks returns the squares attacked by a white pawn
+func whitePawnAttacks(sq int) (attacks uint64) {
+	if sq%8 < 7 {
+		attacks |= 1 << uint(sq-7)
+	}
+	if sq%8 > 0 {
+		attacks |= 1 << uint(sq-9)
+	}
+	return attacks
+}
+
+// generateKPK generates the KPK bitbase by retrograde analysis. Positions that are won or drawn
+// immediately are classified first, the rest is resolved from the results of their successors
+// until nothing changes. Positions that are still unknown can't be won and are draws
+func generateKPK() {
+	var adjacent [BoardSquareNum]uint64
+	for sq := range adjacent {
+		adjacent[sq] = kingAdjacent(sq)
+	}
+
+	positions := make([]uint8, kpkSize)
+	for idx := range positions {
+		pawnIdx := idx % 24
+		pawn := (pawnIdx%6+1)*8 + pawnIdx/6
+		whiteKing := idx / 24 % 64
+		blackKing := idx / (24 * 64) % 64
+		side := idx / (24 * 64 * 64)
+		positions[idx] = kpkInitial(side, whiteKing, blackKing, pawn, &adjacent)
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for idx := range positions {
+			if positions[idx] != kpkUnknown {
+				continue
+			}
+			pawnIdx := idx % 24
+			pawn := (pawnIdx%6+1)*8 + pawnIdx/6
+			whiteKing := idx / 24 % 64
+			blackKing := idx / (24 * 64) % 64
+			side := idx / (24 * 64 * 64)
+
+			if result := kpkClassify(positions, side, whiteKing, blackKing, pawn, &adjacent); result != kpkUnknown {
+				positions[idx] = result
+				changed = true
+			}
+		}
+	}
+
+	for idx, result := range positions {
+		if result == kpkWin {
+			kpkBitbase[idx/64] |= 1 << uint(idx%64)
+		}
+	}
+}
+
+// kpkInitial classifies positions that are illegal, won by a safe promotion or drawn
+// because black is stalemated or can capture the pawn
+func kpkInitial(side, whiteKing, blackKing, pawn int, adjacent *[BoardSquareNum]uint64) uint8 {
+	if whiteKing == blackKing || whiteKing == pawn || blackKing == pawn || squareDistance(whiteKing, blackKing) <= 1 {
+		return kpkInvalid
+	}
+	if side == White {
+		// black can't be in check with white to move
+		if whitePawnAttacks(pawn)&(1<<uint(blackKing)) != 0 {
+			return kpkInvalid
+		}
+		// the pawn promotes and the queen can't be captured
+		promotion := pawn - 8
+		if pawn/8 == 1 && promotion != whiteKing && promotion != blackKing &&
+			(squareDistance(blackKing, promotion) > 1 || squareDistance(whiteKing, promotion) == 1) {
+			return kpkWin
+		}
+		return kpkUnknown
+	}
+
+	moves := adjacent[blackKing] &^ (adjacent[whiteKing] | whitePawnAttacks(pawn))
+	// stalemate, or the pawn can be captured (it is undefended if the king can move there)
+	if moves == 0 || moves&(1<<uint(pawn)) != 0 {
+		return kpkDraw
+	}
+	return kpkUnknown
+}
+
+// kpkClassify returns the result of a position from the results of its successors: white wins if
+// any move wins, black draws if any move draws. Otherwise it stays unknown until all successors are resolved
+func kpkClassify(positions []uint8, side, whiteKing, blackKing, pawn int, adjacent *[BoardSquareNum]uint64) uint8 {
+	result := kpkInvalid
+	good, bad := kpkDraw, kpkWin
+
+	if side == White {
+		good, bad = kpkWin, kpkDraw
+		for moves := adjacent[whiteKing] &^ (adjacent[blackKing] | 1<<uint(pawn)); moves != 0; moves &= moves - 1 {
+			result |= positions[kpkIndex(Black, bits.TrailingZeros64(moves), blackKing, pawn)]
+		}
+
+		// promotions are classified by kpkInitial
+		push := pawn - 8
+		if pawn/8 > 1 && push != whiteKing && push != blackKing {
+			result |= positions[kpkIndex(Black, whiteKing, blackKing, push)]
+
+			doublePush := pawn - 16
+			if pawn/8 == 6 && doublePush != whiteKing && doublePush != blackKing {
+				result |= positions[kpkIndex(Black, whiteKing, blackKing, doublePush)]
+			}
+		}
+	} else {
+		moves := adjacent[blackKing] &^ (adjacent[whiteKing] | whitePawnAttacks(pawn) | 1<<uint(pawn))
+		for ; moves != 0; moves &= moves - 1 {
+			result |= positions[kpkIndex(White, whiteKing, bits.TrailingZeros64(moves), pawn)]
+		}
+	}
+
+	if result&good != 0 {
+		return good
+	}
+	if result&kpkUnknown != 0 {
+		return kpkUnknown
+	}
+	return bad
+}
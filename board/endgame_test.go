@@ -0,0 +1,112 @@
+package board
+
+import (
+	"testing"
+)
+
+func TestKPK(t *testing.T) {
+	InitHashKeys()
+
+	positions := map[string]bool{
+		"3k4/8/3K4/3P4/8/8/8/8 w - - 0 1": true,  // king on the 6th rank in front of the pawn
+		"3k4/8/3K4/3P4/8/8/8/8 b - - 0 1": true,  // regardless of the side to move
+		"7k/8/7P/8/8/8/8/K7 w - - 0 1":    false, // the king is in front of the rook pawn
+		"8/8/8/8/8/k7/7P/K7 w - - 0 1":    true,  // the king is outside the square of the pawn
+		"8/8/8/8/6k1/8/7P/K7 w - - 0 1":   false, // the pawn is lost
+		"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1": false, // stalemate
+		"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1": true,
+		"8/8/8/3k4/8/8/3P4/3K4 w - - 0 1": false, // the defending king has the opposition
+		// black has the pawn
+		"k7/7p/K7/8/8/8/8/8 b - - 0 1":    true,
+		"8/8/8/8/3p4/3k4/8/3K4 w - - 0 1": true,
+		"8/8/8/8/3p4/3k4/8/3K4 b - - 0 1": true,
+		"3k4/3p4/8/8/3K4/8/8/8 b - - 0 1": false,
+	}
+
+	for fen, win := range positions {
+		board := Board{}
+		if err := board.ParseFen(fen); err != nil {
+			t.Fatal(err)
+		}
+		trace := board.EvalTrace()
+		if trace.Endgame != "KPvK" {
+			t.Errorf("%s: expected the KPK evaluation, got %q", fen, trace.Endgame)
+		}
+		if win != (trace.Score != 0) {
+			t.Errorf("%s: expected win %v, got score %d", fen, win, trace.Score)
+		}
+	}
+}
+
+func TestMatingEndgames(t *testing.T) {
+	InitHashKeys()
+
+	// pairs of positions where the first one is closer to the mate
+	tests := []struct {
+		name   string
+		better string
+		worse  string
+	}{
+		{"KQK edge", "k7/8/2K5/8/8/8/8/6Q1 w - - 0 1", "8/8/8/3k4/8/8/2K5/6Q1 w - - 0 1"},
+		{"KRK kings close", "3k4/8/3K4/8/8/8/8/7R w - - 0 1", "3k4/8/8/8/8/8/3K4/7R w - - 0 1"},
+		{"KBNK bishop's corner", "7k/8/5K2/8/8/8/8/4BN2 w - - 0 1", "k7/8/2K5/8/8/8/8/4BN2 w - - 0 1"},
+		{"KBNK light bishop", "k7/8/2K5/8/8/8/8/3B1N2 w - - 0 1", "7k/8/5K2/8/8/8/8/3B1N2 w - - 0 1"},
+	}
+
+	for _, test := range tests {
+		better, worse := Board{}, Board{}
+		better.ParseFen(test.better)
+		worse.ParseFen(test.worse)
+
+		if better.EvalPosition() <= worse.EvalPosition() {
+			t.Errorf("%s: expected %d > %d", test.name, better.EvalPosition(), worse.EvalPosition())
+		}
+		if worse.EvalPosition() < KnownWin {
+			t.Errorf("%s: expected a known win, got %d", test.name, worse.EvalPosition())
+		}
+	}
+
+	// the evaluation is the same for black
+	board := Board{}
+	board.ParseFen("k7/8/2K5/8/8/8/8/6Q1 w - - 0 1")
+	mirrored := board
+	MirrorBoard(&mirrored)
+	if board.EvalPosition() != mirrored.EvalPosition() {
+		t.Errorf("Expected the same evaluation for the mirrored position: %d != %d",
+			board.EvalPosition(), mirrored.EvalPosition())
+	}
+}
+
+func TestEndgameAfterCapture(t *testing.T) {
+	InitHashKeys()
+
+	// the capture leaves KBNvK, MakeMove doesn't update the state boards
+	board := Board{}
+	board.ParseFen("4k3/8/8/8/8/p7/8/BN2K3 w - - 0 1")
+	if err := board.MakeMoves("b1a3"); err != nil {
+		t.Fatal(err)
+	}
+	if trace := board.EvalTrace(); trace.Endgame != "KBNvK" {
+		t.Errorf("Expected the KBNvK evaluation after the capture, got %q", trace.Endgame)
+	}
+}
+
+func TestOppositeBishops(t *testing.T) {
+	InitHashKeys()
+
+	board := Board{}
+	// opposite coloured bishops
+	board.ParseFen("4k3/5p2/8/2b5/8/8/4PPP1/4KB2 w - - 0 1")
+	if trace := board.EvalTrace(); trace.Scale != ScaleOppositeBishops {
+		t.Errorf("Expected scale %d, got %d", ScaleOppositeBishops, trace.Scale)
+	}
+	board.ParseFen("r3k3/5p2/8/2b5/8/8/4PPP1/R3KB2 w - - 0 1")
+	if trace := board.EvalTrace(); trace.Scale != ScaleOppositeBishopsPieces {
+		t.Errorf("Expected scale %d, got %d", ScaleOppositeBishopsPieces, trace.Scale)
+	}
+	// same coloured bishops
+	board.ParseFen("4k3/5p2/8/1b6/8/8/4PPP1/4KB2 w - - 0 1")
+	if trace := board.EvalTrace(); trace.Scale != ScaleNormal {
+		t.Errorf("Expected scale %d, got %d", ScaleNormal, trace.Scale)
+	}
+}
@@ -0,0 +1,108 @@
+package board
+
+import (
+	"math/bits"
+)
+
+// kingAttackUnitsMax attack units above this value don't increase the king danger any further
+const kingAttackUnitsMax int = 50
+
+// pieceAttacks returns the squares attacked by a knight, bishop, rook or queen on the given square
+func (board *Board) pieceAttacks(piece, sq int, occupied uint64) uint64 {
+	switch piece {
+	case WN, BN:
+		return KnightMoves[sq]
+	case WB, BB:
+		return board.DiagonalAndAntiDiagonalMoves(sq, occupied)
+	case WR, BR:
+		return board.HorizontalAndVerticalMoves(sq, occupied)
+	case WQ, BQ:
+		return board.DiagonalAndAntiDiagonalMoves(sq, occupied) | board.HorizontalAndVerticalMoves(sq, occupied)
+	}
+	return 0
+}
+
+// sidePieces returns the squares occupied by the pieces of a side. The evaluation doesn't use the
+// stateBoards, MakeMove doesn't update them
+func (board *Board) sidePieces(side int) (pieces uint64) {
+	for piece := side*6 + WP; piece <= side*6+WK; piece++ {
+		pieces |= board.bitboards[piece]
+	}
+	return pieces
+}
+
+// evalKingSafety evaluates the safety of a side's king: enemy attacks on the squares around the
+// king, the pawn shield in front of it, enemy pawns storming it and open files next to it
+func (board *Board) evalKingSafety(side int, trace *EvalTrace) (score EvalScore) {
+	params := board.params
+	enemy := side ^ 1
+	kingSq := bits.TrailingZeros64(board.bitboards[side*6+WK])
+	zone := KingMoves[kingSq] | 1<<kingSq
+	occupied := board.sidePieces(White) | board.sidePieces(Black)
+
+	// attack units of the enemy pieces (knight to queen) that attack the king zone
+	attackers, units := 0, 0
+	for pieceType := 0; pieceType < 4; pieceType++ {
+		piece := enemy*6 + WN + pieceType
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			attacks := board.pieceAttacks(piece, bits.TrailingZeros64(bitboard), occupied) & zone
+			if attacks != 0 {
+				attackers++
+				units += params.KingAttackWeight[pieceType] * bits.OnesCount64(attacks)
+			}
+		}
+	}
+
+	var attack EvalScore
+	// a single attacker can't do much damage on its own
+	if attackers >= 2 {
+		if units > kingAttackUnitsMax {
+			units = kingAttackUnitsMax
+		}
+		attack = EvalScore{-units * units * params.KingDanger.Mg / 64, -units * units * params.KingDanger.Eg / 64}
+	}
+
+	// pawns on the king file and the files next to it, ranks are from the side's perspective
+	files := IsolatedMask[kingSq] | FileMasks8[kingSq%8]
+	ownPawns := board.bitboards[side*6+WP]
+	enemyPawns := board.bitboards[enemy*6+WP]
+	kingRank := tableSquare(side, kingSq) / 8
+
+	var shield, storm EvalScore
+	for bitboard := ownPawns & files; bitboard != 0; bitboard &= bitboard - 1 {
+		distance := tableSquare(side, bits.TrailingZeros64(bitboard))/8 - kingRank
+		if distance == 1 || distance == 2 {
+			shield.Add(params.KingShield[distance-1])
+		}
+	}
+	for bitboard := enemyPawns & files; bitboard != 0; bitboard &= bitboard - 1 {
+		distance := tableSquare(side, bits.TrailingZeros64(bitboard))/8 - kingRank
+		if distance >= 1 && distance <= 3 {
+			storm.Add(params.KingPawnStorm[distance])
+		}
+	}
+
+	var openFiles EvalScore
+	for file := 0; file < 8; file++ {
+		if files&FileMasks8[file] == 0 {
+			continue
+		}
+		if (ownPawns|enemyPawns)&FileMasks8[file] == 0 {
+			openFiles.Add(params.KingNearOpenFile)
+		} else if ownPawns&FileMasks8[file] == 0 {
+			openFiles.Add(params.KingNearSemiOpenFile)
+		}
+	}
+
+	if trace != nil {
+		trace.Terms[TermKingAttacks][side] = attack
+		trace.Terms[TermPawnShield][side] = shield
+		trace.Terms[TermPawnStorm][side] = storm
+		trace.Terms[TermKingFiles][side] = openFiles
+	}
+	score.Add(attack)
+	score.Add(shield)
+	score.Add(storm)
+	score.Add(openFiles)
+	return score
+}
@@ -0,0 +1,43 @@
+package board
+
+import (
+	"math/bits"
+)
+
+// pawnAttacks returns the squares attacked by the pawns of a side
+func (board *Board) pawnAttacks(side int) uint64 {
+	if side == White {
+		pawns := board.bitboards[WP]
+		return ((pawns >> 7) & (^FileA)) | ((pawns >> 9) & (^FileH))
+	}
+	pawns := board.bitboards[BP]
+	return ((pawns << 7) & (^FileH)) | ((pawns << 9) & (^FileA))
+}
+
+// evalMobility evaluates the number of squares the knights, bishops, rooks and queens of a side
+// can move to. Squares occupied by own pieces or attacked by enemy pawns don't count
+func (board *Board) evalMobility(side int, trace *EvalTrace) (score EvalScore) {
+	params := board.params
+	tables := [4][]EvalScore{params.KnightMobility[:], params.BishopMobility[:],
+		params.RookMobility[:], params.QueenMobility[:]}
+
+	ownPieces := board.sidePieces(side)
+	area := ^ownPieces &^ board.pawnAttacks(side^1)
+	occupied := ownPieces | board.sidePieces(side^1)
+
+	for pieceType := 0; pieceType < 4; pieceType++ {
+		piece := side*6 + WN + pieceType
+
+		var mobility EvalScore
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			moves := board.pieceAttacks(piece, bits.TrailingZeros64(bitboard), occupied) & area
+			mobility.Add(tables[pieceType][bits.OnesCount64(moves)])
+		}
+
+		if trace != nil {
+			trace.Terms[TermKnightMobility+pieceType][side] = mobility
+		}
+		score.Add(mobility)
+	}
+	return score
+}
@@ -0,0 +1,192 @@
+package board
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// PieceSquareTable middlegame and endgame values of a piece on every square. The tables
+// are from white's perspective and start at a1 (index 0), black uses the mirrored squares
+type PieceSquareTable struct {
+	Mg [BoardSquareNum]int `json:"mg"`
+	Eg [BoardSquareNum]int `json:"eg"`
+}
+
+// EvalParams weights used by the evaluation. Every board has its own parameter set, so boards
+// with different weights can be compared without recompiling. The parameters must not be
+// modified while a board uses them, changes take effect when they are (re)set with SetEvalParams
+type EvalParams struct {
+	PawnValue   int `json:"pawnValue"`
+	KnightValue int `json:"knightValue"`
+	BishopValue int `json:"bishopValue"`
+	RookValue   int `json:"rookValue"`
+	QueenValue  int `json:"queenValue"`
+
+	PawnTable   PieceSquareTable `json:"pawnTable"`
+	KnightTable PieceSquareTable `json:"knightTable"`
+	BishopTable PieceSquareTable `json:"bishopTable"`
+	RookTable   PieceSquareTable `json:"rookTable"`
+	QueenTable  PieceSquareTable `json:"queenTable"`
+	KingTable   PieceSquareTable `json:"kingTable"`
+
+	// PawnPassed passed pawn bonuses depending on the rank from the pawn's perspective
+	PawnPassed        [8]EvalScore `json:"pawnPassed"`
+	PawnIsolated      EvalScore    `json:"pawnIsolated"`
+	PawnDoubled       EvalScore    `json:"pawnDoubled"`
+	RookOpenFile      EvalScore    `json:"rookOpenFile"`
+	RookSemiOpenFile  EvalScore    `json:"rookSemiOpenFile"`
+	QueenOpenFile     EvalScore    `json:"queenOpenFile"`
+	QueenSemiOpenFile EvalScore    `json:"queenSemiOpenFile"`
+	BishopPair        EvalScore    `json:"bishopPair"`
+
+	// KingAttackWeight attack units per attacked king zone square for knights, bishops, rooks and queens
+	KingAttackWeight [4]int `json:"kingAttackWeight"`
+	// KingDanger penalty per squared attack unit (in 1/64)
+	KingDanger EvalScore `json:"kingDanger"`
+	// KingShield bonus for own pawns one and two ranks in front of the king
+	KingShield [2]EvalScore `json:"kingShield"`
+	// KingPawnStorm bonus for enemy pawns depending on how many ranks they are in front of the king
+	KingPawnStorm        [4]EvalScore `json:"kingPawnStorm"`
+	KingNearOpenFile     EvalScore    `json:"kingNearOpenFile"`
+	KingNearSemiOpenFile EvalScore    `json:"kingNearSemiOpenFile"`
+
+	// mobility bonuses depending on the number of squares a piece can move to
+	KnightMobility [9]EvalScore  `json:"knightMobility"`
+	BishopMobility [14]EvalScore `json:"bishopMobility"`
+	RookMobility   [15]EvalScore `json:"rookMobility"`
+	QueenMobility  [28]EvalScore `json:"queenMobility"`
+
+	// lookup tables derived from the parameters above
+	pieceValue        [13]int
+	pieceSquareScores [13][BoardSquareNum]EvalScore
+}
+
+// DefaultEvalParams returns a copy of the built-in evaluation weights
+func DefaultEvalParams() *EvalParams {
+	params := &EvalParams{
+		PawnValue:   PieceValue[WP],
+		KnightValue: PieceValue[WN],
+		BishopValue: PieceValue[WB],
+		RookValue:   PieceValue[WR],
+		QueenValue:  PieceValue[WQ],
+
+		PawnTable:   PieceSquareTable{PawnTable, PawnTableE},
+		KnightTable: PieceSquareTable{KnightTable, KnightTableE},
+		BishopTable: PieceSquareTable{BishopTable, BishopTableE},
+		RookTable:   PieceSquareTable{RookTable, RookTableE},
+		KingTable:   PieceSquareTable{KingO, KingE},
+
+		PawnIsolated:      PawnIsolated,
+		PawnDoubled:       PawnDoubled,
+		RookOpenFile:      RookOpenFile,
+		RookSemiOpenFile:  RookSemiOpenfile,
+		QueenOpenFile:     QueenOpenFile,
+		QueenSemiOpenFile: QueenSemiOpenFile,
+		BishopPair:        BishopPair,
+
+		KingAttackWeight:     KingAttackWeight,
+		KingDanger:           KingDanger,
+		KingShield:           KingShield,
+		KingPawnStorm:        KingPawnStorm,
+		KingNearOpenFile:     KingNearOpenFile,
+		KingNearSemiOpenFile: KingNearSemiOpenFile,
+
+		KnightMobility: KnightMobility,
+		BishopMobility: BishopMobility,
+		RookMobility:   RookMobility,
+		QueenMobility:  QueenMobility,
+	}
+	for rank := range params.PawnPassed {
+		params.PawnPassed[rank] = EvalScore{PawnPassed[rank], PawnPassedE[rank]}
+	}
+	params.update()
+	return params
+}
+
+// defaultEvalParams parameters of boards that don't have their own set
+var defaultEvalParams = DefaultEvalParams()
+
+// update computes the lookup tables from the parameters
+func (params *EvalParams) update() {
+	values := [6]int{params.PawnValue, params.KnightValue, params.BishopValue,
+		params.RookValue, params.QueenValue, PieceValue[WK]}
+	tables := [6]*PieceSquareTable{&params.PawnTable, &params.KnightTable, &params.BishopTable,
+		&params.RookTable, &params.QueenTable, &params.KingTable}
+
+	for pieceType := 0; pieceType < 6; pieceType++ {
+		params.pieceValue[WP+pieceType] = values[pieceType]
+		params.pieceValue[BP+pieceType] = values[pieceType]
+
+		table := tables[pieceType]
+		for sq := 0; sq < BoardSquareNum; sq++ {
+			white := tableSquare(White, sq)
+			params.pieceSquareScores[WP+pieceType][sq] = EvalScore{table.Mg[white], table.Eg[white]}
+			// black values are negated so the values of all pieces on the board can be summed up
+			black := tableSquare(Black, sq)
+			params.pieceSquareScores[BP+pieceType][sq] = EvalScore{-table.Mg[black], -table.Eg[black]}
+		}
+	}
+}
+
+// ReadEvalParams reads parameters in JSON format. Parameters that are missing
+// from the input keep their default values
+func ReadEvalParams(reader io.Reader) (*EvalParams, error) {
+	params := DefaultEvalParams()
+
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(params); err != nil {
+		return nil, err
+	}
+	params.update()
+	return params, nil
+}
+
+// LoadEvalParams reads parameters from a JSON file
+func LoadEvalParams(filename string) (*EvalParams, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadEvalParams(file)
+}
+
+// WriteJSON writes the parameters in JSON format
+func (params *EvalParams) WriteJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(params)
+}
+
+// Save writes the parameters to a JSON file
+func (params *EvalParams) Save(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := params.WriteJSON(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// EvalParams returns the evaluation parameters used by the board
+func (board *Board) EvalParams() *EvalParams {
+	return board.params
+}
+
+// SetEvalParams sets the evaluation parameters used by the board (nil for the defaults). The
+// parameters are kept by ParseFen and copies of the board, so they apply to a whole search
+func (board *Board) SetEvalParams(params *EvalParams) {
+	if params == nil {
+		params = defaultEvalParams
+	} else {
+		params.update()
+	}
+	board.params = params
+	board.material = board.computeMaterial()
+	board.psqt, board.phase = board.computePieceSquareScores()
+}
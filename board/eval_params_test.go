@@ -0,0 +1,99 @@
+package board
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvalParamsRoundTrip(t *testing.T) {
+	InitHashKeys()
+
+	var out bytes.Buffer
+	if err := DefaultEvalParams().WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	params, err := ReadEvalParams(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *params != *DefaultEvalParams() {
+		t.Errorf("Parameters changed after writing and reading them")
+	}
+
+	filename := filepath.Join(t.TempDir(), "params.json")
+	params.BishopPair = EvalScore{10, 20}
+	if err := params.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadEvalParams(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.BishopPair != params.BishopPair {
+		t.Errorf("Expected bishop pair %+v, got %+v", params.BishopPair, loaded.BishopPair)
+	}
+}
+
+func TestReadEvalParams(t *testing.T) {
+	params, err := ReadEvalParams(strings.NewReader(`{"knightValue": 300, "pawnIsolated": {"mg": -20, "eg": -30}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.KnightValue != 300 || params.PawnIsolated != (EvalScore{-20, -30}) {
+		t.Errorf("Parameters were not read: %+v %+v", params.KnightValue, params.PawnIsolated)
+	}
+	if params.RookValue != PieceValue[WR] || params.PawnTable != DefaultEvalParams().PawnTable {
+		t.Errorf("Missing parameters should keep their default values")
+	}
+
+	for _, input := range []string{`{"knightValue": "300"}`, `{"unknownTerm": 1}`, `{`} {
+		if _, err := ReadEvalParams(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %s", input)
+		}
+	}
+}
+
+func TestBoardEvalParams(t *testing.T) {
+	InitHashKeys()
+
+	// white is a knight up, both sides have the bishop pair
+	fen := "rnbqkb1r/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	defaults := Board{}
+	defaults.ParseFen(fen)
+
+	params := DefaultEvalParams()
+	params.KnightValue = 500
+	params.BishopPair = EvalScore{10, 20}
+	tuned := Board{}
+	tuned.SetEvalParams(params)
+	tuned.ParseFen(fen)
+
+	diff := tuned.EvalPosition() - defaults.EvalPosition()
+	if expected := params.KnightValue - PieceValue[WN]; diff != expected {
+		t.Errorf("Expected the evaluations to differ by %d, got %d", expected, diff)
+	}
+	if bishopPair := tuned.EvalTrace().Terms[TermBishopPair][White]; bishopPair != params.BishopPair {
+		t.Errorf("Expected bishop pair %+v, got %+v", params.BishopPair, bishopPair)
+	}
+	if defaults.EvalParams() != defaultEvalParams || tuned.EvalParams() != params {
+		t.Errorf("Boards should keep their own parameters")
+	}
+
+	// the incremental updates use the board's parameters
+	for _, move := range []string{"e2e4", "b8c6", "g1f3", "c6d4", "f3d4", "c7c5", "d4c6"} {
+		if err := tuned.MakeMoves(move); err != nil {
+			t.Fatal(err)
+		}
+		if err := tuned.checkIncrementalEval(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// changing the parameters of a board with pieces recomputes the incremental values
+	tuned.SetEvalParams(nil)
+	if err := tuned.checkIncrementalEval(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,160 @@
+package board
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Evaluation terms recorded in an EvalTrace
+const (
+	TermMaterial = iota
+	TermPawnPst
+	TermKnightPst
+	TermBishopPst
+	TermRookPst
+	TermQueenPst
+	TermKingPst
+	TermPassedPawns
+	TermIsolatedPawns
+	TermDoubledPawns
+	TermBishopPair
+	TermRookFiles
+	TermQueenFiles
+	TermKingAttacks
+	TermPawnShield
+	TermPawnStorm
+	TermKingFiles
+	TermKnightMobility
+	TermBishopMobility
+	TermRookMobility
+	TermQueenMobility
+	termCount
+)
+
+// EvalTermNames names of the evaluation terms used by the printers
+var EvalTermNames = [termCount]string{
+	"Material",
+	"Pawn PST",
+	"Knight PST",
+	"Bishop PST",
+	"Rook PST",
+	"Queen PST",
+	"King PST",
+	"Passed pawns",
+	"Isolated pawns",
+	"Doubled pawns",
+	"Bishop pair",
+	"Rook files",
+	"Queen files",
+	"King attacks",
+	"Pawn shield",
+	"Pawn storm",
+	"King files",
+	"Knight mobility",
+	"Bishop mobility",
+	"Rook mobility",
+	"Queen mobility",
+}
+
+// EvalTrace breakdown of the evaluation of a position. Every term is stored for each side
+// from the perspective of that side, the total of a term is the white score minus the black score
+type EvalTrace struct {
+	Terms [termCount][2]EvalScore
+	// Phase game phase used to taper the terms
+	Phase int
+	// Scale factor (out of ScaleNormal) the sum of the terms is scaled with in drawish positions
+	Scale int
+	// Endgame name of the specialised endgame evaluation that was used instead of the terms
+	Endgame string
+	// Score evaluation from white's perspective
+	Score int
+}
+
+// EvalTrace evaluates the position and records the value of every evaluation term
+func (board *Board) EvalTrace() *EvalTrace {
+	trace := &EvalTrace{Phase: board.gamePhase()}
+
+	for side := White; side <= Black; side++ {
+		// the kings are always on the board so they are left out of the material
+		material := board.material[side] - board.params.pieceValue[WK]
+		trace.Terms[TermMaterial][side] = EvalScore{material, material}
+	}
+
+	for piece := WP; piece <= BK; piece++ {
+		side := PieceColour[piece]
+		term := TermPawnPst + (piece-1)%6
+
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			score := board.params.pieceSquareScores[piece][bits.TrailingZeros64(bitboard)]
+			// the black scores are stored negated
+			if side == Black {
+				score = EvalScore{-score.Mg, -score.Eg}
+			}
+			trace.Terms[term][side].Add(score)
+		}
+	}
+
+	trace.Score = board.evaluate(trace)
+	return trace
+}
+
+// Total returns the tapered value of a term from white's perspective
+func (trace *EvalTrace) Total(term int) int {
+	score := trace.Terms[term][White]
+	score.Sub(trace.Terms[term][Black])
+	// material is not tapered
+	if term == TermMaterial {
+		return score.Mg
+	}
+	return score.Taper(trace.Phase)
+}
+
+// String returns the trace as a text table
+func (trace *EvalTrace) String() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "%-16s %13s %13s %7s\n", "Term", "White", "Black", "Total")
+	fmt.Fprintf(&builder, "%-16s %6s %6s %6s %6s %7s\n", "", "mg", "eg", "mg", "eg", "")
+	for term := 0; term < termCount; term++ {
+		white, black := trace.Terms[term][White], trace.Terms[term][Black]
+		fmt.Fprintf(&builder, "%-16s %6d %6d %6d %6d %7d\n",
+			EvalTermNames[term], white.Mg, white.Eg, black.Mg, black.Eg, trace.Total(term))
+	}
+	fmt.Fprintf(&builder, "Phase: %d/%d\n", trace.Phase, TotalPhase)
+	fmt.Fprintf(&builder, "Scale: %d/%d\n", trace.Scale, ScaleNormal)
+	if trace.Endgame != "" {
+		fmt.Fprintf(&builder, "Endgame: %s\n", trace.Endgame)
+	}
+	fmt.Fprintf(&builder, "Score: %d (white's perspective)\n", trace.Score)
+	return builder.String()
+}
+
+type evalTermJSON struct {
+	Name  string    `json:"name"`
+	White EvalScore `json:"white"`
+	Black EvalScore `json:"black"`
+	Total int       `json:"total"`
+}
+
+// MarshalJSON encodes the trace as a list of named terms with the values for each side
+func (trace *EvalTrace) MarshalJSON() ([]byte, error) {
+	terms := make([]evalTermJSON, termCount)
+	for term := range terms {
+		terms[term] = evalTermJSON{
+			Name:  EvalTermNames[term],
+			White: trace.Terms[term][White],
+			Black: trace.Terms[term][Black],
+			Total: trace.Total(term),
+		}
+	}
+
+	return json.Marshal(struct {
+		Terms   []evalTermJSON `json:"terms"`
+		Phase   int            `json:"phase"`
+		Scale   int            `json:"scale"`
+		Endgame string         `json:"endgame,omitempty"`
+		Score   int            `json:"score"`
+	}{terms, trace.Phase, trace.Scale, trace.Endgame, trace.Score})
+}
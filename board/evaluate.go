@@ -1,6 +1,12 @@
 package board
 
-// PieceValue A map used to identify a piece's value
+import (
+	"fmt"
+	"math/bits"
+)
+
+// PieceValue A map used to identify a piece's value. The evaluation values of the
+// pieces are part of the EvalParams, these defaults are also used by the search
 var PieceValue = map[int]int{
    NoPiece: 0,
    WP: 100,
@@ -17,7 +23,49 @@ var PieceValue = map[int]int{
    BK: 50000,
 }
 
-// PawnTable pawn table
+// EvalScore value of an evaluation term in the middlegame (Mg) and in the endgame (Eg).
+// The two values are blended depending on the game phase
+type EvalScore struct {
+	Mg int `json:"mg"`
+	Eg int `json:"eg"`
+}
+
+// Add adds another score to the score
+func (score *EvalScore) Add(other EvalScore) {
+	score.Mg += other.Mg
+	score.Eg += other.Eg
+}
+
+// Sub subtracts another score from the score
+func (score *EvalScore) Sub(other EvalScore) {
+	score.Mg -= other.Mg
+	score.Eg -= other.Eg
+}
+
+// Taper interpolates between the middlegame and endgame value. phase is
+// TotalPhase at the start of the game and 0 when only kings and pawns are left
+func (score EvalScore) Taper(phase int) int {
+	return (score.Mg*phase + score.Eg*(TotalPhase-phase)) / TotalPhase
+}
+
+// Contribution of each piece to the game phase, pawns and kings don't count
+const (
+	PhaseKnight = 1
+	PhaseBishop = 1
+	PhaseRook   = 2
+	PhaseQueen  = 4
+	// TotalPhase phase of the starting position
+	TotalPhase = 4*PhaseKnight + 4*PhaseBishop + 4*PhaseRook + 2*PhaseQueen
+)
+
+// PiecePhase contribution of each piece type to the game phase
+var PiecePhase = [13]int{
+	NoPiece: 0,
+	WN: PhaseKnight, WB: PhaseBishop, WR: PhaseRook, WQ: PhaseQueen,
+	BN: PhaseKnight, BB: PhaseBishop, BR: PhaseRook, BQ: PhaseQueen,
+}
+
+// PawnTable pawn middlegame table
 var PawnTable = [BoardSquareNum]int{
 	0,  0,  0,   0,   0,  0,  0,  0,
    10, 10,  0, -10, -10,  0, 10, 10,
@@ -29,7 +77,19 @@ var PawnTable = [BoardSquareNum]int{
 	0,  0,  0,   0,   0,  0,  0,  0,
 }
 
-// KnightTable knight table
+// PawnTableE pawn endgame table
+var PawnTableE = [BoardSquareNum]int{
+	0,  0,  0,  0,  0,  0,  0,  0,
+	5,  5,  5,  5,  5,  5,  5,  5,
+	5,  5,  5,  5,  5,  5,  5,  5,
+   10, 10, 10, 10, 10, 10, 10, 10,
+   20, 20, 20, 20, 20, 20, 20, 20,
+   35, 35, 35, 35, 35, 35, 35, 35,
+   50, 50, 50, 50, 50, 50, 50, 50,
+	0,  0,  0,  0,  0,  0,  0,  0,
+}
+
+// KnightTable knight middlegame table
 var KnightTable = [BoardSquareNum]int{
    0, -10,  0,  0,  0, 0, -10,  0,
    0,   0,  0,  5,  5,  0,  0,  0,
@@ -41,7 +101,19 @@ var KnightTable = [BoardSquareNum]int{
    0,   0,  0,  0,  0,  0,  0,  0,
 }
 
-// BishopTable bishop table
+// KnightTableE knight endgame table
+var KnightTableE = [BoardSquareNum]int{
+   -20, -10, -10, -10, -10, -10, -10, -20,
+   -10,   0,   0,   5,   5,   0,   0, -10,
+   -10,   0,  10,  10,  10,  10,   0, -10,
+   -10,   5,  10,  15,  15,  10,   5, -10,
+   -10,   5,  10,  15,  15,  10,   5, -10,
+   -10,   0,  10,  10,  10,  10,   0, -10,
+   -10,   0,   0,   5,   5,   0,   0, -10,
+   -20, -10, -10, -10, -10, -10, -10, -20,
+}
+
+// BishopTable bishop middlegame table
 var BishopTable = [BoardSquareNum]int{
    0,  0, -10,  0,  0, -10,  0, 0,
    0,  0,   0, 10, 10,   0,  0, 0,
@@ -53,7 +125,19 @@ var BishopTable = [BoardSquareNum]int{
    0,  0,   0,  0,  0,   0,  0, 0,
 }
 
-// RookTable rook table
+// BishopTableE bishop endgame table
+var BishopTableE = [BoardSquareNum]int{
+   -10, -5, -5, -5, -5, -5, -5, -10,
+	-5,  0,  0,  0,  0,  0,  0,  -5,
+	-5,  0,  5,  5,  5,  5,  0,  -5,
+	-5,  0,  5, 10, 10,  5,  0,  -5,
+	-5,  0,  5, 10, 10,  5,  0,  -5,
+	-5,  0,  5,  5,  5,  5,  0,  -5,
+	-5,  0,  0,  0,  0,  0,  0,  -5,
+   -10, -5, -5, -5, -5, -5, -5, -10,
+}
+
+// RookTable rook middlegame table
 var RookTable = [BoardSquareNum]int{
 	0,  0,  5, 10, 10,  5,  0,  0,
 	0,  0,  5, 10, 10,  5,  0,  0,
@@ -65,6 +149,18 @@ var RookTable = [BoardSquareNum]int{
 	0,  0,  5, 10, 10,  5,  0,  0,
 }
 
+// RookTableE rook endgame table
+var RookTableE = [BoardSquareNum]int{
+	0,  0,  0,  0,  0,  0,  0,  0,
+	0,  0,  0,  0,  0,  0,  0,  0,
+	0,  0,  0,  0,  0,  0,  0,  0,
+	0,  0,  0,  0,  0,  0,  0,  0,
+	0,  0,  0,  0,  0,  0,  0,  0,
+	0,  0,  0,  0,  0,  0,  0,  0,
+   10, 10, 10, 10, 10, 10, 10, 10,
+	0,  0,  0,  0,  0,  0,  0,  0,
+}
+
 // KingE king endgame table
 var KingE = [BoardSquareNum]int{
    -50, -10,  0,  0,  0,  0, -10, -50,
@@ -101,88 +197,310 @@ var Mirror64 = [BoardSquareNum]int{
 	0,  1,  2,  3,  4,  5,  6,  7,
 }
 
-// PawnPassed passed pawn bonuses depending on how far down the board it is
+// PawnPassed passed pawn middlegame bonuses depending on how far down the board it is
 var PawnPassed = [8]int{0, 5, 10, 20, 35, 60, 100, 200}
 
-const (
+// PawnPassedE passed pawn endgame bonuses depending on how far down the board it is
+var PawnPassedE = [8]int{0, 10, 20, 35, 60, 100, 150, 250}
+
+var (
 	// PawnIsolated isolated pawn bonus
-	PawnIsolated = -10
+	PawnIsolated = EvalScore{-10, -15}
 	// PawnDoubled doubled pawn bonus
-	PawnDoubled = -10
+	PawnDoubled = EvalScore{-10, -20}
 	// RookOpenFile rook on open file bonus
-	RookOpenFile = 10
+	RookOpenFile = EvalScore{10, 5}
 	// RookSemiOpenfile rook on semi-open file bonus
-	RookSemiOpenfile = 5
+	RookSemiOpenfile = EvalScore{5, 3}
 	// QueenOpenFile queen on open file bonus
-	QueenOpenFile = 5
+	QueenOpenFile = EvalScore{5, 3}
 	// QueenSemiOpenFile queen on semi-open file bonus
-	QueenSemiOpenFile = 3
+	QueenSemiOpenFile = EvalScore{3, 2}
 	// BishopPair bonus
-	BishopPair = 30
+	BishopPair = EvalScore{30, 50}
 	// KingNearOpenFile king on or near open file bonus
-	KingNearOpenFile = -10
+	KingNearOpenFile = EvalScore{-10, 0}
+	// KingNearSemiOpenFile king on or near a file without own pawns bonus
+	KingNearSemiOpenFile = EvalScore{-5, 0}
+	// KingDanger penalty per squared attack unit on the king zone (in 1/64)
+	KingDanger = EvalScore{24, 4}
 )
 
-// EndGameMaterial defines the boundary limit for the endgame
-var EndGameMaterial = 1*PieceValue[WR] + 2*PieceValue[WN] + 2*PieceValue[WP] + PieceValue[WK]
+// KingAttackWeight attack units per square of the king zone that is attacked by a knight, bishop, rook or queen
+var KingAttackWeight = [4]int{2, 2, 3, 5}
+
+// KingShield bonus for own pawns one and two ranks in front of the king
+var KingShield = [2]EvalScore{{10, 0}, {5, 0}}
+
+// KingPawnStorm bonus for enemy pawns on the files of the king depending on how many ranks they are in front of it
+var KingPawnStorm = [4]EvalScore{{0, 0}, {-5, 0}, {-15, 0}, {-5, 0}}
+
+// KnightMobility knight bonus depending on the number of squares it can move to
+var KnightMobility = [9]EvalScore{
+	{-16, -16}, {-12, -12}, {-8, -8}, {-4, -4}, {0, 0}, {4, 4}, {8, 8}, {12, 12}, {16, 16},
+}
+
+// BishopMobility bishop bonus depending on the number of squares it can move to
+var BishopMobility = [14]EvalScore{
+	{-30, -30}, {-25, -25}, {-20, -20}, {-15, -15}, {-10, -10}, {-5, -5}, {0, 0},
+	{5, 5}, {10, 10}, {15, 15}, {20, 20}, {25, 25}, {30, 30}, {35, 35},
+}
+
+// RookMobility rook bonus depending on the number of squares it can move to
+var RookMobility = [15]EvalScore{
+	{-14, -28}, {-12, -24}, {-10, -20}, {-8, -16}, {-6, -12}, {-4, -8}, {-2, -4}, {0, 0},
+	{2, 4}, {4, 8}, {6, 12}, {8, 16}, {10, 20}, {12, 24}, {14, 28},
+}
+
+// QueenMobility queen bonus depending on the number of squares it can move to
+var QueenMobility = [28]EvalScore{
+	{-13, -26}, {-12, -24}, {-11, -22}, {-10, -20}, {-9, -18}, {-8, -16}, {-7, -14},
+	{-6, -12}, {-5, -10}, {-4, -8}, {-3, -6}, {-2, -4}, {-1, -2}, {0, 0},
+	{1, 2}, {2, 4}, {3, 6}, {4, 8}, {5, 10}, {6, 12}, {7, 14},
+	{8, 16}, {9, 18}, {10, 20}, {11, 22}, {12, 24}, {13, 26}, {14, 28},
+}
+
+
+// IsolatedMask squares on the files next to the file of a square. A pawn is isolated if there are
+// no friendly pawns on these squares
+var IsolatedMask = generateIsolatedMasks()
+
+// PawnPassedMask squares in front of a pawn (for each side) on its own and the neighbouring files.
+// A pawn is passed if there are no enemy pawns on these squares
+var PawnPassedMask = generatePassedMasks()
+
+func generateIsolatedMasks() (masks [BoardSquareNum]uint64) {
+	for sq := 0; sq < BoardSquareNum; sq++ {
+		file := sq % 8
+		if file > 0 {
+			masks[sq] |= FileMasks8[file-1]
+		}
+		if file < 7 {
+			masks[sq] |= FileMasks8[file+1]
+		}
+	}
+	return masks
+}
+
+func generatePassedMasks() (masks [2][BoardSquareNum]uint64) {
+	for sq := 0; sq < BoardSquareNum; sq++ {
+		files := IsolatedMask[sq] | FileMasks8[sq%8]
+		for rank := 0; rank < 8; rank++ {
+			// rank 0 is the 8th rank, white pawns move towards it
+			if rank < sq/8 {
+				masks[White][sq] |= files & RankMasks8[rank]
+			} else if rank > sq/8 {
+				masks[Black][sq] |= files & RankMasks8[rank]
+			}
+		}
+	}
+	return masks
+}
+
+// tableSquare returns the index into the evaluation tables (which are from white's
+// perspective starting at a1) for a piece of the given side
+func tableSquare(side, sq int) int {
+	if side == White {
+		return Mirror64[sq]
+	}
+	return sq
+}
+
+// computePieceSquareScores computes the piece-square table values and the game phase from scratch.
+// Both are normally updated incrementally when pieces are added or removed
+func (board *Board) computePieceSquareScores() (psqt EvalScore, phase int) {
+	for piece := WP; piece <= BK; piece++ {
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			psqt.Add(board.params.pieceSquareScores[piece][bits.TrailingZeros64(bitboard)])
+			phase += PiecePhase[piece]
+		}
+	}
+	return psqt, phase
+}
+
+// computeMaterial computes the material of both sides from scratch
+func (board *Board) computeMaterial() (material [2]int) {
+	for piece := WP; piece <= BK; piece++ {
+		material[PieceColour[piece]] += bits.OnesCount64(board.bitboards[piece]) * board.params.pieceValue[piece]
+	}
+	return material
+}
+
+// debugEval enables checking the incrementally updated evaluation against a recompute at every evaluation
+const debugEval = false
+
+// checkIncrementalEval returns an error if the incrementally updated material,
+// piece-square table values or game phase differ from a recompute
+func (board *Board) checkIncrementalEval() error {
+	psqt, phase := board.computePieceSquareScores()
+	if psqt != board.psqt || phase != board.phase {
+		return fmt.Errorf("Incremental evaluation mismatch: psqt %+v (expected %+v) phase %d (expected %d) in %s",
+			board.psqt, psqt, board.phase, phase, board.Fen())
+	}
+	if material := board.computeMaterial(); material != board.material {
+		return fmt.Errorf("Incremental evaluation mismatch: material %v (expected %v) in %s",
+			board.material, material, board.Fen())
+	}
+	return nil
+}
 
+// gamePhase returns the game phase based on the non-pawn material on the board,
+// from TotalPhase in the opening to 0 when only kings and pawns are left
+func (board *Board) gamePhase() int {
+	// promotions can push the phase above the starting position
+	if board.phase > TotalPhase {
+		return TotalPhase
+	}
+	return board.phase
+}
 
-// EvalPosition evaluate position and return value
+// EvalPosition evaluate position and return value from the side to move's perspective.
+// All positional terms are tapered between their middlegame and endgame values by the game phase
 func (board *Board) EvalPosition() int {
-   score := board.material[White] - board.material[Black] // get current score
-
-   // !!! FIX THIS !!!!
-   // if MaterialDraw(pos) == true && pos.pieceNum[WhitePawn] == 0 && pos.pieceNum[BlackPawn] == 0 {
-   //    return 0
-   // }
-
-   // for sq := 0; sq < BoardSquareNum; sq++ {
-   //    switch pos.Pieces[sq] {
-   //    case OffBoard, Empty:
-   //       continue
-   //    case WhitePawn:
-   //       evalWhitePawn(pos, sq, &score)
-   //    case BlackPawn:
-   //       evalBlackPawn(pos, sq, &score)
-   //    case WhiteKnight:
-   //       score += KnightTable[Sq120ToSq64[sq]]
-   //    case BlackKnight:
-   //       score -= KnightTable[Mirror64[Sq120ToSq64[sq]]]
-   //    case WhiteBishop:
-   //       score += BishopTable[Sq120ToSq64[sq]]
-   //    case BlackBishop:
-   //       score -= BishopTable[Mirror64[Sq120ToSq64[sq]]]
-   //    case WhiteRook:
-   //       evalWhiteRook(pos, sq, &score)
-   //    case BlackRook:
-   //       evalBlackRook(pos, sq, &score)
-   //    case WhiteQueen:
-   //       evalWhiteQueen(pos, sq, &score)
-   //    case BlackQueen:
-   //       evalBlackQueen(pos, sq, &score)
-   //    case WhiteKing:
-   //       evalWhiteKing(pos, sq, &score)
-   //    case BlackKing:
-   //       evalBlackKing(pos, sq, &score)
-   //    default:
-   //       panic("Unknown square type")
-   //    }
-   // }
-
-   // if pos.pieceNum[WhiteBishop] >= 2 {
-   //    score += BishopPair
-   // }
-   // if pos.pieceNum[BlackBishop] >= 2 {
-   //    score -= BishopPair
-   // }
-
-   // if pos.side == White {
-   //    return score
-   // }
-   // return -score
-
-   // todo remove this
-   return score
+	if debugEval {
+		if err := board.checkIncrementalEval(); err != nil {
+			panic(err)
+		}
+	}
+
+	score := board.evaluate(nil)
+
+	if board.Side == White {
+		return score
+	}
+	return -score
+}
+
+// evaluate returns the score from white's perspective. If trace is not nil the
+// individual evaluation terms are recorded in it
+func (board *Board) evaluate(trace *EvalTrace) int {
+	if score, name, found := board.probeEndgame(); found {
+		if trace != nil {
+			trace.Endgame = name
+		}
+		return score
+	}
+
+	positional := board.psqt
+	positional.Add(board.evalPawnStructure(trace).Score)
+	positional.Add(board.evalPieces(White, trace))
+	positional.Sub(board.evalPieces(Black, trace))
+	positional.Add(board.evalKingSafety(White, trace))
+	positional.Sub(board.evalKingSafety(Black, trace))
+	positional.Add(board.evalMobility(White, trace))
+	positional.Sub(board.evalMobility(Black, trace))
+
+	score := board.material[White] - board.material[Black] + positional.Taper(board.gamePhase())
+
+	scale := board.evalScale()
+	if trace != nil {
+		trace.Scale = scale
+	}
+	return score * scale / ScaleNormal
+}
+
+// evalPawnStructure evaluates the pawn structure of both sides. The result is looked up in
+// and stored to the pawn hash table if the board has one. Tracing always evaluates the pawns
+func (board *Board) evalPawnStructure(trace *EvalTrace) *PawnEntry {
+	if board.pawnTable == nil || trace != nil {
+		entry := &PawnEntry{PawnKey: board.pawnKey}
+		board.evalPawnEntry(entry, trace)
+		return entry
+	}
+
+	entry, found := board.pawnTable.Probe(board.pawnKey)
+	if !found {
+		// the entry is replaced in place
+		*entry = PawnEntry{PawnKey: board.pawnKey}
+		board.evalPawnEntry(entry, nil)
+	}
+	return entry
+}
+
+// evalPawnEntry fills in the evaluation of the pawn structure
+func (board *Board) evalPawnEntry(entry *PawnEntry, trace *EvalTrace) {
+	entry.Score = board.evalPawns(White, trace)
+	entry.Score.Sub(board.evalPawns(Black, trace))
+}
+
+// evalPawns evaluates the pawn structure of a side
+func (board *Board) evalPawns(side int, trace *EvalTrace) (score EvalScore) {
+	params := board.params
+	pawns := board.bitboards[side*6+WP]
+	enemyPawns := board.bitboards[(side^1)*6+WP]
+
+	var isolated, passed, doubled EvalScore
+	for bitboard := pawns; bitboard != 0; bitboard &= bitboard - 1 {
+		sq := bits.TrailingZeros64(bitboard)
+
+		if IsolatedMask[sq]&pawns == 0 {
+			isolated.Add(params.PawnIsolated)
+		}
+		if PawnPassedMask[side][sq]&enemyPawns == 0 {
+			// rank from the side's perspective
+			rank := tableSquare(side, sq) / 8
+			passed.Add(params.PawnPassed[rank])
+		}
+	}
+
+	for file := 0; file < 8; file++ {
+		for count := bits.OnesCount64(pawns & FileMasks8[file]); count > 1; count-- {
+			doubled.Add(params.PawnDoubled)
+		}
+	}
+
+	if trace != nil {
+		trace.Terms[TermIsolatedPawns][side] = isolated
+		trace.Terms[TermPassedPawns][side] = passed
+		trace.Terms[TermDoubledPawns][side] = doubled
+	}
+	score.Add(isolated)
+	score.Add(passed)
+	score.Add(doubled)
+	return score
+}
+
+// evalPieces evaluates the pieces (everything except pawns) of a side. The piece-square
+// table values are updated incrementally and not part of this
+func (board *Board) evalPieces(side int, trace *EvalTrace) (score EvalScore) {
+	params := board.params
+	pawns := board.bitboards[side*6+WP]
+	allPawns := board.bitboards[WP] | board.bitboards[BP]
+
+	var bishopPair, rookFiles, queenFiles EvalScore
+	if bits.OnesCount64(board.bitboards[side*6+WB]) >= 2 {
+		bishopPair.Add(params.BishopPair)
+	}
+
+	for bitboard := board.bitboards[side*6+WR]; bitboard != 0; bitboard &= bitboard - 1 {
+		sq := bits.TrailingZeros64(bitboard)
+
+		if allPawns&FileMasks8[sq%8] == 0 {
+			rookFiles.Add(params.RookOpenFile)
+		} else if pawns&FileMasks8[sq%8] == 0 {
+			rookFiles.Add(params.RookSemiOpenFile)
+		}
+	}
+
+	for bitboard := board.bitboards[side*6+WQ]; bitboard != 0; bitboard &= bitboard - 1 {
+		sq := bits.TrailingZeros64(bitboard)
+
+		if allPawns&FileMasks8[sq%8] == 0 {
+			queenFiles.Add(params.QueenOpenFile)
+		} else if pawns&FileMasks8[sq%8] == 0 {
+			queenFiles.Add(params.QueenSemiOpenFile)
+		}
+	}
+
+	if trace != nil {
+		trace.Terms[TermBishopPair][side] = bishopPair
+		trace.Terms[TermRookFiles][side] = rookFiles
+		trace.Terms[TermQueenFiles][side] = queenFiles
+	}
+	score.Add(bishopPair)
+	score.Add(rookFiles)
+	score.Add(queenFiles)
+	return score
 }
 
 // FlipVertical Flip a bitboard vertically about the centre ranks. 
@@ -203,7 +521,6 @@ func FlipVertical(bb uint64) uint64 {
 // Only used for testing.
 func MirrorBoard(board *Board) {
    var swapPiece = [14]int{NoPiece, BP, BN, BB, BR, BQ, BK, WP, WN, WB, WR, WQ, WK, EP }
-   var tempMaterial = [2]int{0, 0}
    var tempPosition [64]int
    var tempBitboards [14]uint64
 
@@ -223,7 +540,7 @@ func MirrorBoard(board *Board) {
    }
    board.bitboards = tempBitboards
 
-   // Mirror pieces in position variable & update material score
+   // Mirror pieces in position variable
    // Invert pieces' positions
    for sq := range board.position {
       tempPosition[sq] = board.position[Mirror64[sq]]
@@ -238,14 +555,9 @@ func MirrorBoard(board *Board) {
    for sq := range board.position {
       mirroredPiece := swapPiece[tempPosition[sq]]
       board.position[sq] = mirroredPiece
-
-      if mirroredPiece < BP {
-         tempMaterial[White] += PieceValue[mirroredPiece]
-      } else {
-         tempMaterial[Black] += PieceValue[mirroredPiece]
-      }
    }
-   board.material = tempMaterial
+   board.material = board.computeMaterial()
+   board.psqt, board.phase = board.computePieceSquareScores()
    
    // Mirror stateBoards
    board.UpdateBitMasks()
@@ -270,4 +582,5 @@ func MirrorBoard(board *Board) {
    board.castlePermissions = tempCastlePerm
 
    board.positionKey = GeneratePositionKey(board)
+   board.pawnKey = GeneratePawnKey(board)
 }
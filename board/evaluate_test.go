@@ -1,6 +1,8 @@
 package board
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"testing"
 )
 
@@ -107,3 +109,319 @@ func TestGeneratePositionKey(t *testing.T) {
 		)
 	}
 }
+
+func TestMaterialAfterCapture(t *testing.T) {
+	// Capture a pawn. Expect that only the material of the side
+	// that lost the pawn is reduced
+
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+
+	whiteMaterial := board.material[White]
+	blackMaterial := board.material[Black]
+
+	board.MakeMoves("e4d5")
+
+	if board.material[White] != whiteMaterial || board.material[Black] != blackMaterial-PieceValue[BP] {
+		t.Errorf(
+			"Incorrect material after capture: White: %d (expected %d) Black %d (expected %d)\n",
+			board.material[White], whiteMaterial, board.material[Black], blackMaterial-PieceValue[BP],
+		)
+	}
+}
+
+func TestEvalSymmetry(t *testing.T) {
+	// The evaluation is from the side to move's perspective, so a position and
+	// its mirrored version (with the side to move swapped) have the same evaluation
+	InitHashKeys()
+
+	var positions []PerftPosition
+	dat, err := ioutil.ReadFile("../test_positions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(dat, &positions); err != nil {
+		t.Fatal(err)
+	}
+
+	fens := []string{
+		StartingPosition,
+		"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"4k3/pp4pp/8/3P4/8/8/PP3PPP/4K3 w - - 0 1",
+	}
+	for _, position := range positions {
+		fens = append(fens, position.Fen)
+	}
+
+	for _, fen := range fens {
+		board := Board{}
+		board.ParseFen(fen)
+		score := board.EvalPosition()
+
+		MirrorBoard(&board)
+		if mirroredScore := board.EvalPosition(); mirroredScore != score {
+			t.Errorf("Evaluation is not symmetric for %s: %d != %d (mirrored)", fen, score, mirroredScore)
+		}
+	}
+}
+
+func TestEvalSideToMove(t *testing.T) {
+	InitHashKeys()
+	white, black := Board{}, Board{}
+	white.ParseFen("r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10")
+	black.ParseFen("r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 b - - 0 10")
+
+	if white.EvalPosition() != -black.EvalPosition() {
+		t.Errorf("Expected opposite scores for both sides: %d vs %d", white.EvalPosition(), black.EvalPosition())
+	}
+
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	if board.EvalPosition() != 0 {
+		t.Errorf("Expected 0 for the starting position, got %d", board.EvalPosition())
+	}
+}
+
+func TestEvalTerms(t *testing.T) {
+	InitHashKeys()
+
+	// pairs of positions (white to move) that only differ in one evaluation term,
+	// the first position has to be better for white. The h-pawns keep the positions
+	// out of the specialised endgame evaluations
+	tests := []struct {
+		name   string
+		better string
+		worse  string
+	}{
+		{"passed pawn", "4k3/7p/8/3P4/8/8/7P/4K3 w - - 0 1", "4k3/2p4p/8/3P4/8/8/7P/4K3 w - - 0 1"},
+		{"advanced passed pawn", "4k3/7p/3P4/8/8/8/7P/4K3 w - - 0 1", "4k3/7p/8/8/3P4/8/7P/4K3 w - - 0 1"},
+		{"isolated pawn", "4k3/8/8/8/8/8/3PP3/4K3 w - - 0 1", "4k3/8/8/8/8/8/3P1P2/4K3 w - - 0 1"},
+		{"doubled isolated pawns", "4k3/8/8/8/8/8/2PP4/4K3 w - - 0 1", "4k3/8/8/8/8/3P4/3P4/4K3 w - - 0 1"},
+		{"rook on open file", "4k3/p7/8/8/8/8/P7/3RK3 w - - 0 1", "4k3/3p4/8/8/8/8/3P4/R3K3 w - - 0 1"},
+		{"bishop pair", "4k3/7p/8/8/8/8/7P/2B1KB2 w - - 0 1", "4k3/7p/8/8/8/8/7P/2B1KN2 w - - 0 1"},
+	}
+
+	for _, test := range tests {
+		better, worse := Board{}, Board{}
+		better.ParseFen(test.better)
+		worse.ParseFen(test.worse)
+
+		if better.EvalPosition() <= worse.EvalPosition() {
+			t.Errorf("%s: expected %d > %d", test.name, better.EvalPosition(), worse.EvalPosition())
+		}
+	}
+}
+
+func TestGamePhase(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen   string
+		phase int
+	}{
+		{StartingPosition, TotalPhase},
+		{"4k3/pppppppp/8/8/8/8/PPPPPPPP/4K3 w - - 0 1", 0},
+		{"r3k3/8/8/8/8/8/8/2B1K1N1 w - - 0 1", PhaseRook + PhaseBishop + PhaseKnight},
+		// promoted queens don't push the phase past the starting position
+		{"4k3/8/8/8/8/8/8/QQQQKQQQ w - - 0 1", TotalPhase},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		if err := board.ParseFen(test.fen); err != nil {
+			t.Fatal(err)
+		}
+		if phase := board.gamePhase(); phase != test.phase {
+			t.Errorf("Expected phase %d for %s, got %d", test.phase, test.fen, phase)
+		}
+	}
+}
+
+func TestEvalScoreTaper(t *testing.T) {
+	score := EvalScore{100, -60}
+
+	if score.Taper(TotalPhase) != 100 || score.Taper(0) != -60 || score.Taper(TotalPhase/2) != 20 {
+		t.Errorf("Incorrect tapering: %d %d %d", score.Taper(TotalPhase), score.Taper(0), score.Taper(TotalPhase/2))
+	}
+
+	// tapering is symmetric so the evaluation stays symmetric
+	negated := EvalScore{-101, 61}
+	for phase := 0; phase <= TotalPhase; phase++ {
+		if (EvalScore{101, -61}).Taper(phase) != -negated.Taper(phase) {
+			t.Errorf("Tapering is not symmetric for phase %d", phase)
+		}
+	}
+}
+
+// checkIncrementalEvalTree makes and takes back all moves up to the given depth and
+// checks the incremental evaluation in every position
+func checkIncrementalEvalTree(t *testing.T, board *Board, depth int) {
+	if err := board.checkIncrementalEval(); err != nil {
+		t.Fatal(err)
+	}
+	if depth == 0 {
+		return
+	}
+
+	moveList := board.GetMoves()
+	for i := 0; i < moveList.Count; i++ {
+		board.MakeMove(moveList.Moves[i].Move)
+		checkIncrementalEvalTree(t, board, depth-1)
+		board.TakeMove()
+
+		if err := board.checkIncrementalEval(); err != nil {
+			t.Fatalf("After taking back %s: %v", GetMoveString(moveList.Moves[i].Move), err)
+		}
+	}
+}
+
+func TestIncrementalEval(t *testing.T) {
+	InitHashKeys()
+
+	fens := []string{
+		StartingPosition,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+	}
+
+	for _, fen := range fens {
+		board := Board{}
+		board.ParseFen(fen)
+		checkIncrementalEvalTree(t, &board, 3)
+
+		MirrorBoard(&board)
+		if err := board.checkIncrementalEval(); err != nil {
+			t.Errorf("After MirrorBoard: %v", err)
+		}
+	}
+}
+
+func TestEvalTrace(t *testing.T) {
+	InitHashKeys()
+
+	for _, fen := range []string{
+		StartingPosition,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 b - - 0 1",
+		"2r3k1/1q3ppp/p3p3/1p1bP3/3Q4/P4N2/1P3PPP/2R3K1 b - - 0 1",
+	} {
+		board := Board{}
+		if err := board.ParseFen(fen); err != nil {
+			t.Fatal(err)
+		}
+		trace := board.EvalTrace()
+
+		score := board.EvalPosition()
+		if board.Side == Black {
+			score = -score
+		}
+		if trace.Score != score {
+			t.Errorf("%s: trace score %d != evaluation %d", fen, trace.Score, score)
+		}
+
+		// the terms are tapered separately so rounding may differ by a point per term
+		sum := 0
+		for term := 0; term < termCount; term++ {
+			sum += trace.Total(term)
+		}
+		if diff := sum - trace.Score; diff > termCount || diff < -termCount {
+			t.Errorf("%s: sum of the terms %d != score %d", fen, sum, trace.Score)
+		}
+
+		if _, err := json.Marshal(trace); err != nil {
+			t.Errorf("%s: %v", fen, err)
+		}
+	}
+}
+
+func TestKingSafety(t *testing.T) {
+	InitHashKeys()
+
+	board := Board{}
+	// the queen and the knight attack the castled black king, the h-pawn has advanced to h5.
+	// The white king is on an open file
+	board.ParseFen("r1bq1rk1/ppp2pp1/2n5/3p3p/3P2QN/2PB4/P4PPP/R4K1R w - - 0 1")
+	trace := board.EvalTrace()
+
+	if attacks := trace.Terms[TermKingAttacks][Black]; attacks.Mg >= 0 {
+		t.Errorf("Expected a penalty for the attacks on the black king, got %+v", attacks)
+	}
+	if attacks := trace.Terms[TermKingAttacks][White]; attacks != (EvalScore{}) {
+		t.Errorf("Expected no penalty for the white king, got %+v", attacks)
+	}
+	// f7 and g7 shield the black king, e2 is missing and f2, g2 shield the white king
+	if shield := trace.Terms[TermPawnShield][Black]; shield != (EvalScore{2 * KingShield[0].Mg, 0}) {
+		t.Errorf("Incorrect black pawn shield %+v", shield)
+	}
+	if shield := trace.Terms[TermPawnShield][White]; shield != (EvalScore{2 * KingShield[0].Mg, 0}) {
+		t.Errorf("Incorrect white pawn shield %+v", shield)
+	}
+	if files := trace.Terms[TermKingFiles][White]; files != KingNearOpenFile {
+		t.Errorf("Expected a penalty for the open e-file next to the white king, got %+v", files)
+	}
+
+	// a single attacker is not dangerous
+	board.ParseFen("r1bq1rk1/ppp2ppp/2n5/3p4/3P2Q1/2P5/P4PPP/R4K1R w - - 0 1")
+	if attacks := board.EvalTrace().Terms[TermKingAttacks][Black]; attacks != (EvalScore{}) {
+		t.Errorf("Expected no penalty for a single attacker, got %+v", attacks)
+	}
+
+	// black pawns storming the white king
+	board.ParseFen("4k3/8/8/8/8/6pp/5PPP/6K1 w - - 0 1")
+	storm := KingPawnStorm[2]
+	storm.Add(KingPawnStorm[2])
+	if trace := board.EvalTrace(); trace.Terms[TermPawnStorm][White] != storm {
+		t.Errorf("Expected pawn storm %+v, got %+v", storm, trace.Terms[TermPawnStorm][White])
+	}
+}
+
+func TestMobility(t *testing.T) {
+	InitHashKeys()
+
+	// the knight can't go to b5 which is attacked by the c6 pawn, it can capture on c6.
+	// The rook is blocked by its own king and can move along the first rank and the h-file
+	for _, fen := range []string{
+		"4k3/8/2p5/8/3N4/8/8/4K2R w - - 0 1",
+		"4k3/8/2p5/8/3N4/8/8/4K2R b - - 0 1",
+	} {
+		board := Board{}
+		if err := board.ParseFen(fen); err != nil {
+			t.Fatal(err)
+		}
+		trace := board.EvalTrace()
+
+		if knight := trace.Terms[TermKnightMobility][White]; knight != KnightMobility[7] {
+			t.Errorf("%s: expected knight mobility %+v, got %+v", fen, KnightMobility[7], knight)
+		}
+		if rook := trace.Terms[TermRookMobility][White]; rook != RookMobility[9] {
+			t.Errorf("%s: expected rook mobility %+v, got %+v", fen, RookMobility[9], rook)
+		}
+		if bishop := trace.Terms[TermBishopMobility][Black]; bishop != (EvalScore{}) {
+			t.Errorf("%s: expected no bishop mobility, got %+v", fen, bishop)
+		}
+	}
+}
+
+func TestEvalAfterMakeMove(t *testing.T) {
+	InitHashKeys()
+
+	// MakeMove doesn't update the state boards, the evaluation must not depend on them
+	board := Board{}
+	board.ParseFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err := board.MakeMoves("f1c4"); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := Board{}
+	fresh.ParseFen(board.Fen())
+	if score, expected := board.EvalPosition(), fresh.EvalPosition(); score != expected {
+		t.Errorf("Expected %d after f1c4, got %d", expected, score)
+	}
+	if trace, expected := board.EvalTrace(), fresh.EvalTrace(); trace.Terms != expected.Terms {
+		t.Errorf("Expected the terms %+v after f1c4, got %+v", expected.Terms, trace.Terms)
+	}
+}
@@ -0,0 +1,198 @@
+package board
+
+import (
+	"unsafe"
+)
+
+// DefaultHashSize default size of the transposition table in megabytes
+const DefaultHashSize int = 16
+
+// Bound types of scores stored in the transposition table
+const (
+	// HashNone no score is stored in the entry
+	HashNone int = iota
+	// HashUpperBound the real score is at most the stored score (fail low)
+	HashUpperBound
+	// HashLowerBound the real score is at least the stored score (fail high)
+	HashLowerBound
+	// HashExact the stored score is the real score of the position
+	HashExact
+)
+
+// HashEntry single position stored in the transposition table
+type HashEntry struct {
+	PositionKey uint64
+	Move        int32
+	Score       int16
+	Depth       int8
+	Flag        uint8 // bound type of Score i.e. HashExact
+	Age         uint8 // value of HashTable.age when the entry was written
+}
+
+// hashBucketSize number of entries that share the same index in the table
+const hashBucketSize int = 4
+
+// hashBucket group of entries that a position key can be stored in
+type hashBucket [hashBucketSize]HashEntry
+
+// HashTable fixed size transposition table indexed by the position key
+type HashTable struct {
+	buckets []hashBucket
+	mask    uint64 // len(buckets)-1, number of buckets is always a power of 2
+	age     uint8  // incremented for every new search, used to replace old entries
+
+	// statistics
+	Hits       uint64
+	Writes     uint64
+	Overwrites uint64
+}
+
+// NewHashTable creates a transposition table that uses (at most) the given number of megabytes
+func NewHashTable(megabytes int) *HashTable {
+	table := &HashTable{}
+	table.Resize(megabytes)
+	return table
+}
+
+// Resize reallocates the table to use (at most) the given number of megabytes. All entries are lost
+func (table *HashTable) Resize(megabytes int) {
+	if megabytes < 1 {
+		megabytes = 1
+	}
+
+	bucketNum := uint64(megabytes) * 1024 * 1024 / uint64(unsafe.Sizeof(hashBucket{}))
+	// round down to a power of 2 so the index can be computed with a mask
+	size := uint64(1)
+	for size*2 <= bucketNum {
+		size *= 2
+	}
+
+	table.buckets = make([]hashBucket, size)
+	table.mask = size - 1
+	table.age = 0
+	table.resetStats()
+}
+
+// Clear removes all entries from the table
+func (table *HashTable) Clear() {
+	for i := range table.buckets {
+		table.buckets[i] = hashBucket{}
+	}
+	table.age = 0
+	table.resetStats()
+}
+
+func (table *HashTable) resetStats() {
+	table.Hits = 0
+	table.Writes = 0
+	table.Overwrites = 0
+}
+
+// NewSearch marks all existing entries as coming from a previous search
+func (table *HashTable) NewSearch() {
+	table.age++
+}
+
+// Entries number of entries the table can hold
+func (table *HashTable) Entries() int {
+	return len(table.buckets) * hashBucketSize
+}
+
+// HashFull returns how full the table is in permille, only entries written during
+// the current search are counted (as reported by the UCI `info hashfull`)
+func (table *HashTable) HashFull() int {
+	sampled, used := 0, 0
+	for i := 0; i < len(table.buckets) && sampled < 1000; i++ {
+		for j := 0; j < hashBucketSize; j++ {
+			entry := &table.buckets[i][j]
+			if entry.Flag != uint8(HashNone) && entry.Age == table.age {
+				used++
+			}
+			sampled++
+		}
+	}
+	return used * 1000 / sampled
+}
+
+// scoreToHash converts a mate score relative to the root into one relative to the current position,
+// so it stays correct when the position is reached through a different path
+func scoreToHash(score, ply int) int {
+	if score > IsMate {
+		return score + ply
+	} else if score < -IsMate {
+		return score - ply
+	}
+	return score
+}
+
+// scoreFromHash reverses scoreToHash
+func scoreFromHash(score, ply int) int {
+	if score > IsMate {
+		return score - ply
+	} else if score < -IsMate {
+		return score + ply
+	}
+	return score
+}
+
+// Store saves the search result for a position. Within a bucket the entry for the same position,
+// an entry from an older search or the shallowest entry is replaced (in that order)
+func (table *HashTable) Store(positionKey uint64, move, score, depth, flag, ply int) {
+	bucket := &table.buckets[positionKey&table.mask]
+
+	replace := &bucket[0]
+	for i := 0; i < hashBucketSize; i++ {
+		entry := &bucket[i]
+		if entry.PositionKey == positionKey || entry.Flag == uint8(HashNone) {
+			replace = entry
+			break
+		}
+		if table.replaceValue(entry) < table.replaceValue(replace) {
+			replace = entry
+		}
+	}
+
+	if replace.PositionKey == positionKey {
+		// keep the deeper result of the same search, but never lose the best move
+		if replace.Age == table.age && int(replace.Depth) > depth && flag != HashExact {
+			if move != 0 && replace.Move == 0 {
+				replace.Move = int32(move)
+			}
+			return
+		}
+		if move == 0 {
+			move = int(replace.Move)
+		}
+	} else if replace.Flag != uint8(HashNone) {
+		table.Overwrites++
+	}
+	table.Writes++
+
+	replace.PositionKey = positionKey
+	replace.Move = int32(move)
+	replace.Score = int16(scoreToHash(score, ply))
+	replace.Depth = int8(depth)
+	replace.Flag = uint8(flag)
+	replace.Age = table.age
+}
+
+// replaceValue entries with lower values are replaced first. Entries of previous
+// searches are worth less than entries of the current search
+func (table *HashTable) replaceValue(entry *HashEntry) int {
+	ageDiff := int(table.age - entry.Age) // wraps around correctly since both are uint8
+	return int(entry.Depth) - 8*ageDiff
+}
+
+// Probe looks up a position. Mate scores are adjusted to be relative to the root (given the current ply)
+func (table *HashTable) Probe(positionKey uint64, ply int) (entry HashEntry, found bool) {
+	bucket := &table.buckets[positionKey&table.mask]
+	for i := 0; i < hashBucketSize; i++ {
+		if bucket[i].PositionKey == positionKey && bucket[i].Flag != uint8(HashNone) {
+			table.Hits++
+			entry = bucket[i]
+			entry.Score = int16(scoreFromHash(int(entry.Score), ply))
+			return entry, true
+		}
+	}
+	return entry, false
+}
@@ -0,0 +1,116 @@
+package board
+
+import (
+	"testing"
+)
+
+func TestHashTableStoreProbe(t *testing.T) {
+	table := NewHashTable(1)
+
+	table.Store(12345, 678, -50, 7, HashLowerBound, 3)
+	entry, found := table.Probe(12345, 3)
+
+	if !found {
+		t.Fatalf("Stored entry not found")
+	}
+	if entry.Move != 678 || entry.Score != -50 || entry.Depth != 7 || int(entry.Flag) != HashLowerBound {
+		t.Errorf("Incorrect entry: %+v", entry)
+	}
+
+	if _, found := table.Probe(54321, 3); found {
+		t.Errorf("Found an entry that was never stored")
+	}
+}
+
+func TestHashTableMateScores(t *testing.T) {
+	// A mate found 5 plies from the root at ply 3 is a mate in 2 plies from the stored position.
+	// When the position is reached at ply 7 it has to be reported as a mate 9 plies from the root
+	table := NewHashTable(1)
+	mateScore := Infinite - 5
+
+	table.Store(1, 0, mateScore, 2, HashExact, 3)
+	entry, _ := table.Probe(1, 7)
+	if int(entry.Score) != Infinite-9 {
+		t.Errorf("Expected mate score %d, got %d", Infinite-9, entry.Score)
+	}
+
+	table.Store(2, 0, -mateScore, 2, HashExact, 3)
+	entry, _ = table.Probe(2, 7)
+	if int(entry.Score) != -(Infinite - 9) {
+		t.Errorf("Expected mated score %d, got %d", -(Infinite - 9), entry.Score)
+	}
+}
+
+func TestHashTableReplacement(t *testing.T) {
+	table := NewHashTable(1)
+	bucketNum := table.mask + 1
+
+	// all keys map to the same bucket
+	for i := 0; i < hashBucketSize; i++ {
+		table.Store(uint64(i+1)*bucketNum, i+1, 0, 10-i, HashExact, 0)
+	}
+
+	// the bucket is full, the shallowest entry is replaced
+	table.Store(uint64(hashBucketSize+1)*bucketNum, 99, 0, 20, HashExact, 0)
+	if _, found := table.Probe(uint64(hashBucketSize)*bucketNum, 0); found {
+		t.Errorf("Shallowest entry was not replaced")
+	}
+	if _, found := table.Probe(bucketNum, 0); !found {
+		t.Errorf("Deepest entry was replaced")
+	}
+
+	// entries of an older search are replaced before deeper entries of the current search
+	table.NewSearch()
+	table.Store(uint64(hashBucketSize+2)*bucketNum, 100, 0, 1, HashExact, 0)
+	table.Store(uint64(hashBucketSize+3)*bucketNum, 101, 0, 1, HashExact, 0)
+	if _, found := table.Probe(uint64(hashBucketSize+2)*bucketNum, 0); !found {
+		t.Errorf("Entry of the current search was replaced instead of an old one")
+	}
+	if table.Overwrites != 3 {
+		t.Errorf("Expected 3 overwrites, got %d", table.Overwrites)
+	}
+}
+
+func TestHashTableHashFull(t *testing.T) {
+	table := NewHashTable(1)
+	if table.HashFull() != 0 {
+		t.Errorf("Expected empty table, got hashfull %d", table.HashFull())
+	}
+
+	for key := uint64(0); key < uint64(table.Entries()); key++ {
+		table.Store(key*0x9E3779B97F4A7C15+1, 0, 0, 1, HashExact, 0)
+	}
+	if table.HashFull() < 500 {
+		t.Errorf("Expected a mostly full table, got hashfull %d", table.HashFull())
+	}
+
+	table.NewSearch()
+	if table.HashFull() != 0 {
+		t.Errorf("Entries of a previous search should not count, got hashfull %d", table.HashFull())
+	}
+
+	table.Clear()
+	if _, found := table.Probe(1, 0); found {
+		t.Errorf("Entry found after Clear")
+	}
+}
+
+func TestSearchUsesHashTable(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10")
+
+	table := NewHashTable(4)
+	info := SearchInfo{Depth: 4, HashTable: table}
+	first := board.Search(&info)
+
+	info = SearchInfo{Depth: 4, HashTable: table}
+	second := board.Search(&info)
+
+	if second.Nodes >= first.Nodes {
+		t.Errorf("Expected the second search to profit from the hash table: %d >= %d nodes", second.Nodes, first.Nodes)
+	}
+	if first.Score != second.Score || len(second.PV) != second.Depth {
+		t.Errorf("Inconsistent results: %+v vs %+v", first, second)
+	}
+}
@@ -20,18 +20,24 @@ var KnightMoves [BoardSquareNum]uint64
 // KingMoves an array of bitboards indicating every square the king can go to from a given board index
 var KingMoves [BoardSquareNum]uint64
 
+// hashKeySeed seed of the hashkeys, fixed so that keys are the same in every process
+const hashKeySeed int64 = 0x706c61747970
+
 // InitHashKeys initializes hashkeys for all pieces and possible positions, for castling rights, for side to move
 func InitHashKeys() {
-	for i := 0; i < 13; i++ {
+	random := rand.New(rand.NewSource(hashKeySeed))
+
+	// keys for all pieces and the en passant file (EP)
+	for i := 0; i < 14; i++ {
 		for j := 0; j < BoardSquareNum; j++ {
-			PieceKeys[i][j] = rand.Uint64() // returns a random 64 bit number
+			PieceKeys[i][j] = random.Uint64() // returns a random 64 bit number
 		}
 	}
 
-	SideKey = rand.Uint64()
+	SideKey = random.Uint64()
 
 	for i := 0; i < 16; i++ {
-		CastleKeys[i] = rand.Uint64()
+		CastleKeys[i] = random.Uint64()
 	}
 
 	// Pregeneration of possible knight moves
@@ -92,3 +98,13 @@ func GeneratePositionKey(board *Board) (hashKey uint64) {
 
 	return hashKey
 }
+
+// GeneratePawnKey takes a position and calculates the hashkey of its pawn placement
+func GeneratePawnKey(board *Board) (pawnKey uint64) {
+	for _, piece := range []int{WP, BP} {
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			pawnKey ^= PieceKeys[piece][bits.TrailingZeros64(bitboard)]
+		}
+	}
+	return pawnKey
+}
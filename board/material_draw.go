@@ -0,0 +1,111 @@
+package board
+
+import (
+	"math/bits"
+)
+
+// Scale factors applied to the evaluation, ScaleNormal leaves the evaluation unchanged
+const (
+	ScaleNormal = 16
+	// ScaleDrawish scale of material signatures that are drawn with correct play
+	ScaleDrawish = 2
+	// ScaleOppositeBishops scale of endgames with opposite coloured bishops and pawns only
+	ScaleOppositeBishops = 8
+	// ScaleOppositeBishopsPieces scale of endgames with opposite coloured bishops and other pieces
+	ScaleOppositeBishopsPieces = 12
+)
+
+// pieceCounts returns the number of pieces of every piece type
+func (board *Board) pieceCounts() (counts [13]int) {
+	for piece := WP; piece <= BK; piece++ {
+		counts[piece] = bits.OnesCount64(board.bitboards[piece])
+	}
+	return counts
+}
+
+// IsInsufficientMaterial returns true if neither side can possibly checkmate:
+// KvK, KvK+minor piece or only bishops on squares of the same colour are left
+func (board *Board) IsInsufficientMaterial() bool {
+	counts := board.pieceCounts()
+	if counts[WP]+counts[BP]+counts[WR]+counts[BR]+counts[WQ]+counts[BQ] != 0 {
+		return false
+	}
+
+	knights := counts[WN] + counts[BN]
+	if knights+counts[WB]+counts[BB] <= 1 {
+		return true
+	}
+	bishops := board.bitboards[WB] | board.bitboards[BB]
+	return knights == 0 && (bishops&LightSquares == 0 || bishops&DarkSquares == 0)
+}
+
+// MaterialDraw returns true if the material signature is a draw with correct play, i.e. neither
+// side can force a checkmate (although it may still be possible after a blunder). This covers
+// the insufficient material draws and signatures like KNNvK, KBvKN, KBvKB and KRvKR
+func (board *Board) MaterialDraw() bool {
+	counts := board.pieceCounts()
+	if counts[WP]+counts[BP]+counts[WQ]+counts[BQ] != 0 {
+		return false
+	}
+	if board.IsInsufficientMaterial() {
+		return true
+	}
+
+	whiteMinors := counts[WN] + counts[WB]
+	blackMinors := counts[BN] + counts[BB]
+
+	switch {
+	case counts[WR] == 0 && counts[BR] == 0:
+		if counts[WB] == 0 && counts[BB] == 0 {
+			// two knights can't force mate
+			return counts[WN] < 3 && counts[BN] < 3
+		}
+		if counts[WN] == 0 && counts[BN] == 0 {
+			// the bishop pair wins against a lone king but not against a bishop
+			return abs(counts[WB]-counts[BB]) < 2
+		}
+		// at most two knights or a single bishop on each side
+		return (counts[WN] < 3 && counts[WB] == 0 || counts[WB] == 1 && counts[WN] == 0) &&
+			(counts[BN] < 3 && counts[BB] == 0 || counts[BB] == 1 && counts[BN] == 0)
+	case counts[WR] == 1 && counts[BR] == 1:
+		return whiteMinors < 2 && blackMinors < 2
+	case counts[WR] == 1 && counts[BR] == 0:
+		return whiteMinors == 0 && (blackMinors == 1 || blackMinors == 2)
+	case counts[WR] == 0 && counts[BR] == 1:
+		return blackMinors == 0 && (whiteMinors == 1 || whiteMinors == 2)
+	}
+	return false
+}
+
+// evalScale returns the factor (out of ScaleNormal) the evaluation is scaled with,
+// drawn material signatures are scaled towards 0
+func (board *Board) evalScale() int {
+	// all drawn signatures are without pawns and queens
+	if board.bitboards[WP]|board.bitboards[BP]|board.bitboards[WQ]|board.bitboards[BQ] != 0 {
+		return board.oppositeBishopsScale()
+	}
+	if board.IsInsufficientMaterial() {
+		return 0
+	}
+	if board.MaterialDraw() {
+		return ScaleDrawish
+	}
+	return ScaleNormal
+}
+
+// oppositeBishopsScale scales endgames in which each side has a single bishop and the bishops are
+// on squares of different colours. Even a pawn or two up these endgames are often drawn
+func (board *Board) oppositeBishopsScale() int {
+	whiteBishop, blackBishop := board.bitboards[WB], board.bitboards[BB]
+	if bits.OnesCount64(whiteBishop) != 1 || bits.OnesCount64(blackBishop) != 1 ||
+		(whiteBishop&LightSquares == 0) == (blackBishop&LightSquares == 0) {
+		return ScaleNormal
+	}
+
+	otherPieces := board.bitboards[WN] | board.bitboards[BN] | board.bitboards[WR] | board.bitboards[BR] |
+		board.bitboards[WQ] | board.bitboards[BQ]
+	if otherPieces == 0 {
+		return ScaleOppositeBishops
+	}
+	return ScaleOppositeBishopsPieces
+}
@@ -0,0 +1,73 @@
+package board
+
+import (
+	"testing"
+)
+
+func TestMaterialDraw(t *testing.T) {
+	InitHashKeys()
+
+	positions := map[string]bool{
+		"8/8/4k3/8/8/3K4/8/8 w - - 0 1":      true,  // KvK
+		"8/8/4k3/8/8/3K4/8/6N1 w - - 0 1":    true,  // KNvK
+		"8/8/4k3/2b5/8/3K4/8/6B1 w - - 0 1":  true,  // KBvKB same coloured bishops
+		"8/8/4k3/1b6/8/3K4/8/6B1 w - - 0 1":  true,  // KBvKB opposite coloured bishops
+		"8/8/4k3/8/8/3K4/8/5NN1 w - - 0 1":   true,  // KNNvK
+		"8/8/4k3/8/8/3K4/8/5Bn1 w - - 0 1":   true,  // KBvKN
+		"8/8/4k3/8/8/3K4/8/5nnN w - - 0 1":   true,  // KNvKNN
+		"8/8/4k3/8/8/3K4/8/4BBb1 w - - 0 1":  true,  // KBBvKB
+		"8/8/4k3/8/8/3K4/8/4r2R w - - 0 1":   true,  // KRvKR
+		"8/8/4k3/8/8/3K4/8/4n2R w - - 0 1":   true,  // KRvKN
+		"8/8/4k3/8/8/3K4/8/3bn2R w - - 0 1":  true,  // KRvKBN
+		"8/8/4k3/8/8/3K4/8/4rN1R w - - 0 1":  true,  // KRNvKR
+		"8/8/4k3/8/8/3K4/8/4BB2 w - - 0 1":   false, // KBBvK bishops on both colours
+		"8/8/4k3/8/8/3K4/8/4BN2 w - - 0 1":   false, // KBNvK
+		"8/8/4k3/8/8/3K4/8/3NNN2 w - - 0 1":  false, // KNNNvK
+		"8/8/4k3/8/8/3K4/8/7R w - - 0 1":     false, // KRvK
+		"8/8/4k3/8/8/3K4/8/3NBr1R w - - 0 1": false, // KRBNvKR
+		"8/8/4k3/8/8/3K4/4P3/8 w - - 0 1":    false, // KPvK
+		"8/8/4k3/8/8/3K4/8/4q2Q w - - 0 1":   false, // KQvKQ
+	}
+
+	for fen, expected := range positions {
+		board := Board{}
+		if err := board.ParseFen(fen); err != nil {
+			t.Fatal(err)
+		}
+		if board.MaterialDraw() != expected {
+			t.Errorf("Expected material draw %v for %s", expected, fen)
+		}
+		// insufficient material is always a material draw
+		if board.IsInsufficientMaterial() && !board.MaterialDraw() {
+			t.Errorf("Insufficient material is not a material draw for %s", fen)
+		}
+	}
+}
+
+func TestEvalScale(t *testing.T) {
+	InitHashKeys()
+
+	board := Board{}
+	// a bishop up but insufficient material
+	board.ParseFen("8/8/4k3/8/8/3K4/8/6B1 w - - 0 1")
+	if score := board.EvalPosition(); score != 0 {
+		t.Errorf("Expected a draw score with insufficient material, got %d", score)
+	}
+
+	// KRvKN is scaled towards 0 but still favours the rook
+	board.ParseFen("8/8/4k3/8/8/3K4/8/4n2R w - - 0 1")
+	trace := board.EvalTrace()
+	unscaled := 0
+	for term := 0; term < termCount; term++ {
+		unscaled += trace.Total(term)
+	}
+	if trace.Scale != ScaleDrawish || trace.Score <= 0 || trace.Score >= unscaled/4 {
+		t.Errorf("Expected a scaled score of about %d, got %d (scale %d)",
+			unscaled*ScaleDrawish/ScaleNormal, trace.Score, trace.Scale)
+	}
+
+	board.ParseFen(StartingPosition)
+	if trace := board.EvalTrace(); trace.Scale != ScaleNormal {
+		t.Errorf("Expected the starting position not to be scaled, got %d", trace.Scale)
+	}
+}
@@ -49,6 +49,12 @@ const KnightSpan uint64 = 43234889994
 // KingSpan Bitmask for selecting all king moves
 const KingSpan uint64 = 460039
 
+// LightSquares Bitmask for selecting all light squares (A8, C8 ... H1)
+const LightSquares uint64 = 0xAA55AA55AA55AA55
+
+// DarkSquares Bitmask for selecting all dark squares (B8, D8 ... A1)
+const DarkSquares uint64 = ^LightSquares
+
 // FileMasks8 Array that holds bitmasks that select a given file based on the index of
 // the element i.e. index 0 selects File A, 1- FileB etc.
 var FileMasks8 [8]uint64 = [8]uint64{
@@ -196,6 +202,16 @@ func (moveList *MoveList) AddMove(move int) {
 	moveList.Count++
 }
 
+// contains Returns true if the move is in the move list
+func (moveList *MoveList) contains(move int) bool {
+	for i := 0; i < moveList.Count; i++ {
+		if moveList.Moves[i].Move == move {
+			return true
+		}
+	}
+	return false
+}
+
 // PinRays Struct to hold all generated pin rays for a given position
 type PinRays struct {
 	Rays  [8]uint64 // an array with max possible pinned rays
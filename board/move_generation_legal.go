@@ -24,14 +24,21 @@ func (board *Board) getCheckers(king uint64) uint64 {
 		direction = 1
 	}
 
+	// the pawn on the +7 offset is on the left of the king (for black) and on the right (for white)
+	// so it can't attack a king on the edge of the board on that side (and vice versa for +9)
+	edge7, edge9 := 0, 7
+	if board.Side == White {
+		edge7, edge9 = 7, 0
+	}
+
 	var sidePawnPossibility uint64
-	if kingIdx+direction*7 > 0 {
+	if kingIdx+direction*7 >= 0 && kingIdx%8 != edge7 {
 		sidePawnPossibility = (1 << (kingIdx + direction*7))
 		if sidePawnPossibility&board.stateBoards[EnemyPawns] != 0 {
 			checkers |= sidePawnPossibility
 		}
 	}
-	if kingIdx+direction*9 > 0 {
+	if kingIdx+direction*9 >= 0 && kingIdx%8 != edge9 {
 		sidePawnPossibility = (1 << (kingIdx + direction*9))
 		if sidePawnPossibility&board.stateBoards[EnemyPawns] != 0 {
 			checkers |= sidePawnPossibility
@@ -322,9 +329,24 @@ func (board *Board) getPinnedPieceRays(kingBitboard uint64, pinRays *PinRays) {
 // LegalMovesWhite Generates all legal moves for white
 // todo unify legal moves white and black into 1 method
 func (board *Board) LegalMovesWhite(moveList *MoveList) {
+	board.legalMovesWhite(moveList, false)
+}
+
+// LegalCapturesWhite Generates all legal captures and promotions for white
+func (board *Board) LegalCapturesWhite(moveList *MoveList) {
+	board.legalMovesWhite(moveList, true)
+}
+
+// legalMovesWhite Generates legal moves for white. If capturesOnly is set
+// only captures and promotions are generated
+func (board *Board) legalMovesWhite(moveList *MoveList, capturesOnly bool) {
 	board.UpdateBitMasks()
 
-	board.possibleKingMoves(moveList, board.bitboards[WK])
+	kingTargets := board.stateBoards[NotMyPieces]
+	if capturesOnly {
+		kingTargets = board.stateBoards[EnemyPieces]
+	}
+	board.possibleKingMoves(moveList, board.bitboards[WK], kingTargets)
 
 	checkers := board.getCheckers(board.bitboards[WK])
 
@@ -358,13 +380,22 @@ func (board *Board) LegalMovesWhite(moveList *MoveList) {
 		if pushMask == ^uint64(0) {
 			pushMask = 0
 		}
-	} else {
+	} else if !capturesOnly {
 		board.possibleCastleWhite(moveList)
 	}
 
 	board.getPinnedPieceRays(board.bitboards[WK], &pinRays)
 
-	board.possibleWhitePawn(moveList, pushMask, captureMask, &pinRays)
+	pawnPushMask := pushMask
+	if capturesOnly {
+		// pawns may only push to the promotion rank or (by en passant) to the en passant square,
+		// all other pieces may only move to squares occupied by enemy pieces
+		pawnPushMask &= Rank8 | (board.bitboards[EP] & RankMasks8[2])
+		pushMask = 0
+		captureMask &= board.stateBoards[EnemyPieces]
+	}
+
+	board.possibleWhitePawn(moveList, pawnPushMask, captureMask, &pinRays)
 	board.possibleKnightMoves(moveList, board.bitboards[WN], pushMask, captureMask, &pinRays)
 	board.possibleBishopMoves(moveList, board.bitboards[WB], pushMask, captureMask, &pinRays)
 	board.possibleRookMoves(moveList, board.bitboards[WR], pushMask, captureMask, &pinRays)
@@ -373,9 +404,24 @@ func (board *Board) LegalMovesWhite(moveList *MoveList) {
 
 // LegalMovesBlack Generates all legal moves for black
 func (board *Board) LegalMovesBlack(moveList *MoveList) {
+	board.legalMovesBlack(moveList, false)
+}
+
+// LegalCapturesBlack Generates all legal captures and promotions for black
+func (board *Board) LegalCapturesBlack(moveList *MoveList) {
+	board.legalMovesBlack(moveList, true)
+}
+
+// legalMovesBlack Generates legal moves for black. If capturesOnly is set
+// only captures and promotions are generated
+func (board *Board) legalMovesBlack(moveList *MoveList, capturesOnly bool) {
 	board.UpdateBitMasks()
 
-	board.possibleKingMoves(moveList, board.bitboards[BK])
+	kingTargets := board.stateBoards[NotMyPieces]
+	if capturesOnly {
+		kingTargets = board.stateBoards[EnemyPieces]
+	}
+	board.possibleKingMoves(moveList, board.bitboards[BK], kingTargets)
 
 	checkers := board.getCheckers(board.bitboards[BK])
 
@@ -407,13 +453,22 @@ func (board *Board) LegalMovesBlack(moveList *MoveList) {
 		if pushMask == ^uint64(0) {
 			pushMask = 0
 		}
-	} else {
+	} else if !capturesOnly {
 		board.possibleCastleBlack(moveList)
 	}
 
 	board.getPinnedPieceRays(board.bitboards[BK], &pinRays)
 
-	board.possibleBlackPawn(moveList, pushMask, captureMask, &pinRays)
+	pawnPushMask := pushMask
+	if capturesOnly {
+		// pawns may only push to the promotion rank or (by en passant) to the en passant square,
+		// all other pieces may only move to squares occupied by enemy pieces
+		pawnPushMask &= Rank1 | (board.bitboards[EP] & RankMasks8[5])
+		pushMask = 0
+		captureMask &= board.stateBoards[EnemyPieces]
+	}
+
+	board.possibleBlackPawn(moveList, pawnPushMask, captureMask, &pinRays)
 	board.possibleKnightMoves(moveList, board.bitboards[BN], pushMask, captureMask, &pinRays)
 	board.possibleBishopMoves(moveList, board.bitboards[BB], pushMask, captureMask, &pinRays)
 	board.possibleRookMoves(moveList, board.bitboards[BR], pushMask, captureMask, &pinRays)
@@ -563,11 +618,11 @@ func (board *Board) possibleWhitePawn(moveList *MoveList, pushMask, captureMask
 	if possibility != 0 && ((possibility&captureMask) != 0 || (possibility>>8&pushMask) != 0) {
 		index = bits.TrailingZeros64(possibility)
 
-		// Remove the capturing and captured pawn from the board and check
-		// if the king is attacked by a rook or queen i.e this en passant capture is
-		// illegal: example - 8/8/8/K2pP2q/8/8/8/3k4 w - d6 0 2
+		// Remove the capturing and captured pawn from the board, place the capturing
+		// pawn on its destination and check if the king is attacked by a rook or queen
+		// i.e this en passant capture is illegal: example - 8/8/8/K2pP2q/8/8/8/3k4 w - d6 0 2
 		occupied := board.stateBoards[Occupied]
-		occupied ^= (1 << (index - 1)) | (1 << index)
+		occupied ^= (1 << (index - 1)) | (1 << index) | (1 << (index - 8))
 		kingIdx := bits.TrailingZeros64(board.bitboards[WK])
 		horizontalMoves := board.HorizontalAndVerticalMoves(kingIdx, occupied)
 		diagonalMoves := board.DiagonalAndAntiDiagonalMoves(kingIdx, occupied)
@@ -584,7 +639,7 @@ func (board *Board) possibleWhitePawn(moveList *MoveList, pushMask, captureMask
 		index = bits.TrailingZeros64(possibility)
 
 		occupied := board.stateBoards[Occupied]
-		occupied ^= (1 << (index + 1)) | (1 << index)
+		occupied ^= (1 << (index + 1)) | (1 << index) | (1 << (index - 8))
 		kingIdx := bits.TrailingZeros64(board.bitboards[WK])
 		horizontalMoves := board.HorizontalAndVerticalMoves(kingIdx, occupied)
 		diagonalMoves := board.DiagonalAndAntiDiagonalMoves(kingIdx, occupied)
@@ -720,11 +775,11 @@ func (board *Board) possibleBlackPawn(moveList *MoveList, pushMask, captureMask
 	if possibility != 0 && ((possibility&captureMask) != 0 || (possibility<<8&pushMask) != 0) {
 		index = bits.TrailingZeros64(possibility)
 
-		// Remove the capturing and captured pawn from the board and check
-		// if the king is attacked by a rook or queen i.e this en passant capture is
-		// illegal: example - 8/8/8/K2pP2q/8/8/8/3k4 w - d6 0 2
+		// Remove the capturing and captured pawn from the board, place the capturing
+		// pawn on its destination and check if the king is attacked by a rook or queen
+		// i.e this en passant capture is illegal: example - 8/8/8/K2pP2q/8/8/8/3k4 w - d6 0 2
 		occupied := board.stateBoards[Occupied]
-		occupied ^= (1 << (index + 1)) | (1 << index)
+		occupied ^= (1 << (index + 1)) | (1 << index) | (1 << (index + 8))
 		kingIdx := bits.TrailingZeros64(board.bitboards[BK])
 		horizontalMoves := board.HorizontalAndVerticalMoves(kingIdx, occupied)
 		diagonalMoves := board.DiagonalAndAntiDiagonalMoves(kingIdx, occupied)
@@ -741,7 +796,7 @@ func (board *Board) possibleBlackPawn(moveList *MoveList, pushMask, captureMask
 		index = bits.TrailingZeros64(possibility)
 
 		occupied := board.stateBoards[Occupied]
-		occupied ^= (1 << (index - 1)) | (1 << index)
+		occupied ^= (1 << (index - 1)) | (1 << index) | (1 << (index + 8))
 		kingIdx := bits.TrailingZeros64(board.bitboards[BK])
 		horizontalMoves := board.HorizontalAndVerticalMoves(kingIdx, occupied)
 		diagonalMoves := board.DiagonalAndAntiDiagonalMoves(kingIdx, occupied)
@@ -866,14 +921,15 @@ func (board *Board) possibleQueenMoves(moveList *MoveList, queen uint64, pushMas
 	}
 }
 
-func (board *Board) possibleKingMoves(moveList *MoveList, king uint64) {
+// possibleKingMoves Generates king moves to all safe squares from the targets bitboard
+func (board *Board) possibleKingMoves(moveList *MoveList, king uint64, targets uint64) {
 	var possibility uint64
 	var capturedPiece int
 
 	// Current king index (in bitmask)
 	kingIdx := bits.TrailingZeros64(king)
 
-	possibility = KingMoves[kingIdx] & board.stateBoards[NotMyPieces] & ^board.stateBoards[Unsafe]
+	possibility = KingMoves[kingIdx] & targets & ^board.stateBoards[Unsafe]
 
 	// choose move
 	movePossibility := possibility & (^(possibility - 1))
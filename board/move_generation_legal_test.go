@@ -83,6 +83,14 @@ func TestGetMoves(t *testing.T) {
 		PrintMoveList(&moveList)
 		t.Errorf("Expected 45 possible moves, got %d instead. Board: \n%s", moveList.Count, board.String())
 	}
+
+	// en passant capture that blocks a check
+	board.ParseFen("8/8/8/1k6/3Pp3/8/8/5B1K b - d3 0 1")
+	moveList = board.GetMoves()
+	if moveList.Count != 6 {
+		PrintMoveList(&moveList)
+		t.Errorf("Expected 6 possible moves, got %d instead. Board: \n%s", moveList.Count, board.String())
+	}
 }
 
 func TestLegalMovesWhite(t *testing.T) {
@@ -146,6 +154,34 @@ func TestGetCheckerSliderRaysToKing(t *testing.T) {
 	// t.Errorf("Error")
 }
 
+func TestGetCheckersBoardEdge(t *testing.T) {
+	InitHashKeys()
+
+	// a pawn on the other edge of the board (one rank closer to the king's index) doesn't give check
+	tests := []struct {
+		fen      string
+		checkers uint64
+		moves    int
+	}{
+		{"4k3/8/8/8/p6K/8/8/R7 w - - 0 1", 0, 15},
+		{"4k3/8/8/6p1/p6K/8/8/R7 w - - 0 1", 1 << 30, 5},
+		{"r7/8/8/k6P/8/8/8/4K3 b - - 0 1", 0, 14},
+		{"r7/8/8/k6P/1P6/8/8/4K3 b - - 0 1", 1 << 33, 5},
+	}
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+		board.UpdateBitMasks()
+
+		if checkers := board.getCheckers(board.bitboards[board.Side*6+WK]); checkers != test.checkers {
+			t.Errorf("%s: expected checkers %x, got %x", test.fen, test.checkers, checkers)
+		}
+		if moveList := board.GetMoves(); moveList.Count != test.moves {
+			t.Errorf("%s: expected %d moves, got %d", test.fen, test.moves, moveList.Count)
+		}
+	}
+}
+
 func BenchmarkGetCheckers(b *testing.B) {
 	InitHashKeys()
 	board := Board{}
@@ -184,3 +220,58 @@ func BenchmarkUpdateBitMasks(b *testing.B) {
 	}
 	b.StopTimer()
 }
+
+// checkCapturesMatchMoves walks the game tree to the given depth and checks that
+// GetCaptures returns exactly the captures and promotions from GetMoves
+func checkCapturesMatchMoves(t *testing.T, board *Board, depth int) {
+	moveList := board.GetMoves()
+	captureList := board.GetCaptures()
+
+	expected := make(map[int]bool)
+	for i := 0; i < moveList.Count; i++ {
+		move := moveList.Moves[i].Move
+		if Captured(move) != NoPiece || Promoted(move) != NoPiece {
+			expected[move] = true
+		}
+	}
+
+	if len(expected) != captureList.Count {
+		t.Errorf("Expected %d captures, got %d. Board: \n%s", len(expected), captureList.Count, board.String())
+	}
+	for i := 0; i < captureList.Count; i++ {
+		if !expected[captureList.Moves[i].Move] {
+			t.Errorf("Unexpected capture %s. Board: \n%s", GetMoveString(captureList.Moves[i].Move), board.String())
+		}
+	}
+
+	if depth == 0 {
+		return
+	}
+	for i := 0; i < moveList.Count; i++ {
+		board.MakeMove(moveList.Moves[i].Move)
+		checkCapturesMatchMoves(t, board, depth-1)
+		board.TakeMove()
+	}
+}
+
+func TestGetCaptures(t *testing.T) {
+	InitHashKeys()
+
+	positions := []string{
+		StartingPosition,
+		"r3k2r/p1pp1pb1/bn2Qnp1/2qPN3/1p2P3/2N5/PPPBBPPP/R3K2R b KQkq - 3 2",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/8/1k6/2b5/2pP4/8/5K2/8 b - d3 0 1",
+		"8/1k5b/8/4pP2/8/3K4/8/8 w - e6 0 1",
+		// en passant capture blocking a check from a bishop
+		"8/8/8/1k6/3Pp3/8/8/5B1K b - d3 0 1",
+		"2K2r2/4P3/8/8/8/8/8/3k4 w - - 0 1",
+		"8/1P6/8/8/8/8/K5p1/2k4R b - - 0 1",
+	}
+
+	for _, position := range positions {
+		board := Board{}
+		board.ParseFen(position)
+		checkCapturesMatchMoves(t, &board, 2)
+	}
+}
@@ -10,14 +10,24 @@ import (
 func (board *Board) removePieceFromSq(pieceType, sq int) {
 	board.bitboards[pieceType] &= (^(1 << sq))
 	board.positionKey ^= PieceKeys[pieceType][sq]
-	board.material[board.Side] -= PieceValue[pieceType]
+	if pieceType == WP || pieceType == BP {
+		board.pawnKey ^= PieceKeys[pieceType][sq]
+	}
+	board.material[PieceColour[pieceType]] -= board.params.pieceValue[pieceType]
+	board.psqt.Sub(board.params.pieceSquareScores[pieceType][sq])
+	board.phase -= PiecePhase[pieceType]
 	// fmt.Printf("-Unhashing piece %c from sq %s\n", PieceChar[pieceType], GetSquareString(sq))
 }
 
 func (board *Board) addPieceToSq(pieceType, sq int) {
 	board.bitboards[pieceType] |= 1 << sq
 	board.positionKey ^= PieceKeys[pieceType][sq]
-	board.material[board.Side] += PieceValue[pieceType]
+	if pieceType == WP || pieceType == BP {
+		board.pawnKey ^= PieceKeys[pieceType][sq]
+	}
+	board.material[PieceColour[pieceType]] += board.params.pieceValue[pieceType]
+	board.psqt.Add(board.params.pieceSquareScores[pieceType][sq])
+	board.phase += PiecePhase[pieceType]
 	// fmt.Printf("+Hashing piece %c from sq %s\n", PieceChar[pieceType], GetSquareString(sq))
 }
 
@@ -29,8 +39,14 @@ func (board *Board) MakeMove(move int) {
 
 	// Store hash value before we do any hashing in/out of pieces etc
 	board.history[board.ply].positionKey = board.positionKey
+	// Store fifty move counter & en passant file before they are modified by this move
+	board.history[board.ply].fiftyMove = board.fiftyMove
+	board.history[board.ply].enPassantFile = board.bitboards[EP]
 
 	board.fiftyMove++ // increment fifty move rule
+	if pieceType == WP || pieceType == BP {
+		board.fiftyMove = 0 // pawn moves are irreversible -> reset 50 move rule counter
+	}
 
 	// Remove piece from start sq in piece's bitboard
 	board.removePieceFromSq(pieceType, fromSq)
@@ -41,7 +57,6 @@ func (board *Board) MakeMove(move int) {
 
 	// handle rook moves if castling is performed
 	if CastleFlag(move) == 1 {
-		// PerftCastles++
 
 		if pieceType == WK && toSq == G1 {
 			board.removePieceFromSq(WR, H1)
@@ -82,7 +97,6 @@ func (board *Board) MakeMove(move int) {
 	} else if (capturedPiece == WP || capturedPiece == BP) && EnPassantFlag(move) == 1 {
 		board.fiftyMove = 0 // reset 50 move rule counter
 
-		// PerftEnPassant++
 		// Otherwise if destination piece is empty but the move is enpassant -> remove captured piece
 		if board.Side == White {
 			board.removePieceFromSq(BP, toSq+8)
@@ -102,7 +116,6 @@ func (board *Board) MakeMove(move int) {
 		enPassantFile := bits.TrailingZeros64(board.bitboards[EP])
 		board.positionKey ^= PieceKeys[EP][enPassantFile]
 		// fmt.Printf("-Unhashing enpass file %d\n", enPassantFile)
-		board.history[board.ply].enPassantFile = board.bitboards[EP]
 		board.bitboards[EP] = 0
 	}
 
@@ -112,7 +125,6 @@ func (board *Board) MakeMove(move int) {
 
 	// store history variables
 	board.history[board.ply].move = move
-	board.history[board.ply].fiftyMove = board.fiftyMove
 	board.history[board.ply].castlePermissions = board.castlePermissions
 
 	// if a rook or king has moved then remove the respective castling permission from castlePerm
@@ -131,7 +143,6 @@ func (board *Board) MakeMove(move int) {
 	}
 
 	if promoted := Promoted(move); promoted > 0 {
-		// PerftPromotions++
 		board.addPieceToSq(promoted, toSq)
 		board.position[toSq] = promoted
 		// we already move the pawn to the 8th rank and since it is a promotion
@@ -139,6 +150,9 @@ func (board *Board) MakeMove(move int) {
 		// from the 8th rank
 		board.removePieceFromSq(pieceType, toSq)
 	}
+	if board.Side == Black {
+		board.fullMove++
+	}
 	board.ply++     // increase halfmove counter
 	board.Side ^= 1 // change side to move
 	board.positionKey ^= SideKey
@@ -184,6 +198,9 @@ func (board *Board) TakeMove() {
 	// change side and hash in the side key
 	board.Side ^= 1
 	board.positionKey ^= SideKey
+	if board.Side == Black {
+		board.fullMove--
+	}
 	// fmt.Printf("+Hashing side key %d\n", SideKey)
 
 	// Remove piece from to sq in piece's bitboard
@@ -145,3 +145,42 @@ func TestMakeMovePromotion(t *testing.T) {
 		t.Errorf("PosKey mismatch: %d != %d\n", board.positionKey, originalKey)
 	}
 }
+
+func TestTakeMoveRestoresHistoryState(t *testing.T) {
+	// Make a quiet move in a position with an en passant file.
+	// Take the move back. Expect that the en passant file and
+	// the fifty move counter are restored to their original values
+
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	board.MakeMoves("g1f3 g8f6 e2e4")
+
+	fiftyMove := board.fiftyMove
+	enPassant := board.bitboards[EP]
+	if enPassant == 0 {
+		t.Fatalf("Expected an en passant file after e2e4\n")
+	}
+
+	board.MakeMoves("f6g4")
+	board.TakeMove()
+
+	if board.fiftyMove != fiftyMove {
+		t.Errorf("Fifty move counter mismatch: %d != %d\n", board.fiftyMove, fiftyMove)
+	}
+	if board.bitboards[EP] != enPassant {
+		t.Errorf("En passant mismatch: %d != %d\n", board.bitboards[EP], enPassant)
+	}
+
+	// Reach the same ply through moves that do not set en passant and
+	// take back a move. Expect that no stale en passant file is restored
+	board.TakeMove()
+	board.TakeMove()
+	board.TakeMove()
+	board.MakeMoves("b1c3 b8c6 g1f3 g8f6")
+	board.TakeMove()
+
+	if board.bitboards[EP] != 0 {
+		t.Errorf("Stale en passant file restored after TakeMove\n")
+	}
+}
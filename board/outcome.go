@@ -0,0 +1,109 @@
+package board
+
+import (
+	"fmt"
+)
+
+// Reasons for the end of a game
+const (
+	// NoOutcome the game is not over yet
+	NoOutcome int = iota
+	// Checkmate the side to move is checkmated
+	Checkmate
+	// Stalemate the side to move has no legal moves and is not in check
+	Stalemate
+	// FiftyMoveRule no capture or pawn move has been made in the last fifty moves
+	FiftyMoveRule
+	// ThreefoldRepetition the same position occurred three times
+	ThreefoldRepetition
+	// InsufficientMaterial neither side has enough material to checkmate
+	InsufficientMaterial
+)
+
+// OutcomeReasons human readable names of the game end reasons
+var OutcomeReasons = [...]string{
+	NoOutcome:            "none",
+	Checkmate:            "checkmate",
+	Stalemate:            "stalemate",
+	FiftyMoveRule:        "fifty move rule",
+	ThreefoldRepetition:  "threefold repetition",
+	InsufficientMaterial: "insufficient material",
+}
+
+// Outcome describes if and how a game has ended
+type Outcome struct {
+	Reason int // one of the game end reasons i.e. Checkmate
+	Winner int // White or Black. Both for draws or if the game is not over
+}
+
+// IsOver returns true if the game has ended
+func (outcome Outcome) IsOver() bool {
+	return outcome.Reason != NoOutcome
+}
+
+// IsDraw returns true if the game ended in a draw
+func (outcome Outcome) IsDraw() bool {
+	return outcome.IsOver() && outcome.Winner == Both
+}
+
+// Result returns the game result as used in PGN: "1-0", "0-1", "1/2-1/2" or "*"
+func (outcome Outcome) Result() string {
+	switch {
+	case !outcome.IsOver():
+		return "*"
+	case outcome.Winner == White:
+		return "1-0"
+	case outcome.Winner == Black:
+		return "0-1"
+	default:
+		return "1/2-1/2"
+	}
+}
+
+func (outcome Outcome) String() string {
+	if !outcome.IsOver() {
+		return outcome.Result()
+	}
+	return fmt.Sprintf("%s (%s)", outcome.Result(), OutcomeReasons[outcome.Reason])
+}
+
+// Outcome returns whether the game is over and why. Checkmate and stalemate take precedence
+// over the draw rules, i.e. a mate delivered with the 100th half move still wins
+func (board *Board) Outcome() Outcome {
+	moveList := board.GetMoves()
+	if moveList.Count == 0 {
+		if board.kingAttacked() {
+			return Outcome{Reason: Checkmate, Winner: board.Side ^ 1}
+		}
+		return Outcome{Reason: Stalemate, Winner: Both}
+	}
+
+	if board.IsInsufficientMaterial() {
+		return Outcome{Reason: InsufficientMaterial, Winner: Both}
+	}
+	if board.fiftyMove >= 100 {
+		return Outcome{Reason: FiftyMoveRule, Winner: Both}
+	}
+	if board.RepetitionCount() >= 3 {
+		return Outcome{Reason: ThreefoldRepetition, Winner: Both}
+	}
+	return Outcome{Reason: NoOutcome, Winner: Both}
+}
+
+// RepetitionCount returns how many times the current position occurred since the
+// last irreversible move (including the current occurrence)
+func (board *Board) RepetitionCount() int {
+	start := board.ply - board.fiftyMove
+	if start < 0 {
+		start = 0
+	}
+
+	count := 1
+	// only positions with the same side to move can be equal
+	for i := board.ply - 2; i >= start; i -= 2 {
+		if board.history[i].positionKey == board.positionKey {
+			count++
+		}
+	}
+	return count
+}
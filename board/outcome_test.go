@@ -0,0 +1,120 @@
+package board
+
+import (
+	"testing"
+)
+
+func TestOutcomeCheckmate(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	board.MakeMoves("f2f3 e7e5 g2g4 d8h4")
+
+	outcome := board.Outcome()
+	if outcome.Reason != Checkmate || outcome.Winner != Black || outcome.Result() != "0-1" {
+		t.Errorf("Expected black to win by checkmate, got %s", outcome)
+	}
+}
+
+func TestOutcomeStalemate(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+
+	outcome := board.Outcome()
+	if outcome.Reason != Stalemate || !outcome.IsDraw() || outcome.Result() != "1/2-1/2" {
+		t.Errorf("Expected stalemate, got %s", outcome)
+	}
+}
+
+func TestOutcomeNotOver(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	board.MakeMoves("e2e4 e7e5")
+
+	outcome := board.Outcome()
+	if outcome.IsOver() || outcome.Result() != "*" {
+		t.Errorf("Expected the game not to be over, got %s", outcome)
+	}
+}
+
+func TestOutcomeFiftyMoveRule(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("4k3/8/8/8/8/8/4P3/R3K3 w - - 0 1")
+	board.fiftyMove = 99
+
+	board.MakeMoves("a1a2")
+	outcome := board.Outcome()
+	if outcome.Reason != FiftyMoveRule || !outcome.IsDraw() {
+		t.Errorf("Expected draw by fifty move rule, got %s", outcome)
+	}
+
+	// a pawn move resets the counter
+	board.TakeMove()
+	board.MakeMoves("e2e3")
+	if board.Outcome().IsOver() {
+		t.Errorf("Expected the game not to be over after a pawn move, got %s", board.Outcome())
+	}
+}
+
+func TestOutcomeFiftyMoveRuleCheckmate(t *testing.T) {
+	// checkmate on the 100th half move wins
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	board.fiftyMove = 99
+
+	board.MakeMoves("a1a8")
+	outcome := board.Outcome()
+	if outcome.Reason != Checkmate || outcome.Winner != White {
+		t.Errorf("Expected white to win by checkmate, got %s", outcome)
+	}
+}
+
+func TestOutcomeThreefoldRepetition(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+
+	board.MakeMoves("g1f3 g8f6 f3g1 f6g8")
+	if board.RepetitionCount() != 2 || board.Outcome().IsOver() {
+		t.Errorf("Expected the starting position to occur twice, got %d", board.RepetitionCount())
+	}
+
+	board.MakeMoves("g1f3 g8f6 f3g1 f6g8")
+	outcome := board.Outcome()
+	if outcome.Reason != ThreefoldRepetition || !outcome.IsDraw() {
+		t.Errorf("Expected draw by threefold repetition, got %s", outcome)
+	}
+}
+
+func TestOutcomeInsufficientMaterial(t *testing.T) {
+	InitHashKeys()
+
+	positions := map[string]bool{
+		"8/8/4k3/8/8/3K4/8/8 w - - 0 1":      true,  // KvK
+		"8/8/4k3/8/8/3K4/8/6N1 w - - 0 1":    true,  // KNvK
+		"8/8/4k3/8/8/3K4/8/6b1 w - - 0 1":    true,  // KvKB
+		"8/8/4k3/2b5/8/3K4/8/6B1 w - - 0 1":  true,  // KBvKB bishops on dark squares
+		"8/8/2b1k3/8/B7/3K4/8/5B2 w - - 0 1": true,  // bishops all on light squares
+		"8/8/4k3/1b6/8/3K4/8/6B1 w - - 0 1":  false, // KBvKB opposite coloured bishops
+		"8/8/4k3/8/8/3K4/8/5NN1 w - - 0 1":   false, // KNNvK
+		"8/8/4k3/8/8/3K4/8/5Bn1 w - - 0 1":   false, // KBvKN
+		"8/8/4k3/8/8/3K4/4P3/8 w - - 0 1":    false, // KPvK
+		"8/8/4k3/8/8/3K4/8/7R w - - 0 1":     false, // KRvK
+	}
+
+	for fen, expected := range positions {
+		board := Board{}
+		board.ParseFen(fen)
+
+		if board.IsInsufficientMaterial() != expected {
+			t.Errorf("Expected insufficient material %v for %s", expected, fen)
+		}
+		if expected && board.Outcome().Reason != InsufficientMaterial {
+			t.Errorf("Expected draw by insufficient material for %s, got %s", fen, board.Outcome())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package board
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"math/bits"
 )
 
@@ -22,103 +23,267 @@ var PieceNotationMap = map[string]int{
 	"Q": WQ,
 }
 
-// ParseFen parse fen position string and setup a position accordingly
-func (board *Board) ParseFen(fen string) {
-	piece := 0
-	count := 0 // number of empty squares declared inside fen string
+// castlingChars maps the castling characters of a FEN string to castling permissions
+var castlingChars = map[byte]int{
+	'K': WhiteKingCastling,
+	'Q': WhiteQueenCastling,
+	'k': BlackKingCastling,
+	'q': BlackQueenCastling,
+}
+
+// castlingSquares king and rook squares that are required for each castling permission
+var castlingSquares = map[int][2]int{
+	WhiteKingCastling:  {60, 63}, // e1, h1
+	WhiteQueenCastling: {60, 56}, // e1, a1
+	BlackKingCastling:  {4, 7},   // e8, h8
+	BlackQueenCastling: {4, 0},   // e8, a8
+}
 
+// ParseFen parse fen position string and setup a position accordingly.
+// The halfmove clock and fullmove number are optional and default to 0 and 1.
+// An error is returned if the FEN is malformed or describes an illegal position,
+// in that case the board is left in an undefined state and has to be set up again
+func (board *Board) ParseFen(fen string) error {
 	board.Reset()
-	char := 0
-
-	for (count < 64) && char < len(fen) {
-		switch t := string(fen[char]); t {
-		case "p", "r", "n", "b", "k", "q", "P", "R", "N", "B", "K", "Q":
-			// If we have a piece related char -> set the piece to corresponding value, i.e p -> BlackPawn
-			piece = PieceNotationMap[t]
-		case "1", "2", "3", "4", "5", "6", "7", "8":
-			// otherwise it must be a count of a number of empty squares
-			empty, _ := strconv.Atoi(t) // get number of empty squares and store in count
-			count += empty
-			char++
-			continue
-		case "/", " ":
-			// if we have / or space then we are either at the end of the rank or at the end of the piece list
-			// -> reset variables and continue the while loop
-			char++
-			continue
-		default:
-			panic("FEN error")
-		}
-		// compute piece color based on piece type
-		color := Black
-		if piece < BP {
-			color = White
-		}
 
-		board.bitboards[piece] |= (1 << count)
-		board.position[count] = piece
-		board.material[color] += PieceValue[piece]
-		board.positionKey ^= PieceKeys[piece][count]
-		char++
-		count++
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return fmt.Errorf("FEN has %d fields, expected at least 4 (pieces, side, castling, en passant): %q", len(fields), fen)
+	}
+	if len(fields) > 6 {
+		return fmt.Errorf("FEN has %d fields, expected at most 6: %q", len(fields), fen)
+	}
+
+	if err := board.parsePiecePlacement(fields[0]); err != nil {
+		return err
 	}
 
-	newChar := ""
-	char++ // move char from empty space to the w/b part of FEN
-	// newChar should be set to the side to move part of the FEN string here
-	newChar = string(fen[char])
-	if newChar == "w" {
+	switch fields[1] {
+	case "w":
 		board.Side = White
 		// hash side (side key is only added for one side)
-		board.positionKey ^= SideKey 
-	} else if newChar == "b" {
+		board.positionKey ^= SideKey
+	case "b":
 		board.Side = Black
-	} else {
-		panic(fmt.Sprintf("Unknown side to move: %s", newChar))
+	default:
+		return fmt.Errorf("Unknown side to move: %q", fields[1])
+	}
+
+	if err := board.parseCastling(fields[2]); err != nil {
+		return err
+	}
+	if err := board.parseEnPassant(fields[3]); err != nil {
+		return err
+	}
+
+	if len(fields) > 4 {
+		halfMove, err := strconv.Atoi(fields[4])
+		if err != nil || halfMove < 0 {
+			return fmt.Errorf("Invalid halfmove clock: %q", fields[4])
+		}
+		board.fiftyMove = halfMove
+	}
+	if len(fields) > 5 {
+		fullMove, err := strconv.Atoi(fields[5])
+		if err != nil || fullMove < 1 {
+			return fmt.Errorf("Invalid fullmove number: %q", fields[5])
+		}
+		board.fullMove = fullMove
+	}
+
+	return board.validatePosition()
+}
+
+// parsePiecePlacement places the pieces from the first field of a FEN string on the board
+func (board *Board) parsePiecePlacement(placement string) error {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return fmt.Errorf("Piece placement has %d ranks, expected 8: %q", len(ranks), placement)
 	}
 
-	// move char pointer 2 chars further and it should now point to the start of the castling permissions part of FEN
-	char += 2
+	for rankIdx, rank := range ranks {
+		file := 0
+		afterDigit := false
+		for _, char := range rank {
+			if char >= '1' && char <= '8' {
+				// the empty squares between two pieces are a single digit
+				if afterDigit {
+					return fmt.Errorf("Consecutive digits on rank %d: %q", 8-rankIdx, rank)
+				}
+				file += int(char - '0')
+				afterDigit = true
+				continue
+			}
+			afterDigit = false
 
-	// Iterate over the next 4 chars - they show if white is allowed to castle king or quenside and the same for black
-	for i := 0; i < 4; i++ {
-		newChar = string(fen[char])
-		if newChar == " " {
-			// when we hit a space, it means there are no more castling permissions => break
-			break
+			piece, ok := PieceNotationMap[string(char)]
+			if !ok {
+				return fmt.Errorf("Unknown piece %q on rank %d", char, 8-rankIdx)
+			}
+			if file < 8 {
+				sq := rankIdx*8 + file
+				board.bitboards[piece] |= (1 << sq)
+				board.position[sq] = piece
+				board.material[PieceColour[piece]] += board.params.pieceValue[piece]
+				board.psqt.Add(board.params.pieceSquareScores[piece][sq])
+				board.phase += PiecePhase[piece]
+				board.positionKey ^= PieceKeys[piece][sq]
+				if piece == WP || piece == BP {
+					board.pawnKey ^= PieceKeys[piece][sq]
+				}
+			}
+			file++
 		}
-		switch newChar { // Depending on the char, enable the corresponding castling permission related bit
-		case "K":
-			board.castlePermissions |= WhiteKingCastling
-		case "Q":
-			board.castlePermissions |= WhiteQueenCastling
-		case "k":
-			board.castlePermissions |= BlackKingCastling
-		case "q":
-			board.castlePermissions |= BlackQueenCastling
-		default:
-			break
+
+		if file != 8 {
+			return fmt.Errorf("Rank %d describes %d squares, expected 8: %q", 8-rankIdx, file, rank)
+		}
+	}
+	return nil
+}
+
+// parseCastling sets the castling permissions from the third field of a FEN string
+func (board *Board) parseCastling(castling string) error {
+	if castling != "-" {
+		for i := 0; i < len(castling); i++ {
+			permission, ok := castlingChars[castling[i]]
+			if !ok || board.castlePermissions&permission != 0 {
+				return fmt.Errorf("Invalid castling permissions: %q", castling)
+			}
+
+			squares := castlingSquares[permission]
+			king, rook := WK, WR
+			if permission == BlackKingCastling || permission == BlackQueenCastling {
+				king, rook = BK, BR
+			}
+			if board.position[squares[0]] != king || board.position[squares[1]] != rook {
+				return fmt.Errorf("Castling permission %c without king on %s and rook on %s",
+					castling[i], GetSquareString(squares[0]), GetSquareString(squares[1]))
+			}
+			board.castlePermissions |= permission
 		}
-		char++
 	}
 	// hash castle permissions
 	board.positionKey ^= CastleKeys[board.castlePermissions]
+	return nil
+}
 
-	// AssertTrue(pos.castlePerm >= 0 && pos.castlePerm <= 15)
-	// move to the en passant square related part of FEN
-	char++
-	newChar = string(fen[char])
+// parseEnPassant sets the en passant file from the fourth field of a FEN string. Requires the side to move
+func (board *Board) parseEnPassant(enPassant string) error {
+	if enPassant == "-" {
+		return nil
+	}
+
+	// the en passant square is behind the pawn that just moved 2 squares forward
+	rank, pawn, pawnRankIdx := byte('6'), BP, 3
+	if board.Side == Black {
+		rank, pawn, pawnRankIdx = '3', WP, 4
+	}
+	if len(enPassant) != 2 || enPassant[0] < 'a' || enPassant[0] > 'h' || enPassant[1] != rank {
+		return fmt.Errorf("Invalid en passant square: %q", enPassant)
+	}
+
+	file := int(enPassant[0] - 'a')
+	pawnSq := pawnRankIdx*8 + file
+	// the square the pawn moved from and the one it skipped have to be empty
+	fromSq := pawnSq - 16
+	if pawn == WP {
+		fromSq = pawnSq + 16
+	}
+	if board.position[pawnSq] != pawn || board.position[(pawnSq+fromSq)/2] != NoPiece || board.position[fromSq] != NoPiece {
+		return fmt.Errorf("En passant square %s without a pawn that just moved 2 squares", enPassant)
+	}
+
+	board.bitboards[EP] = FileMasks8[file]
+	// hash en passant
+	board.positionKey ^= PieceKeys[EP][bits.TrailingZeros64(board.bitboards[EP])]
+	return nil
+}
+
+// validatePosition checks that a parsed position is legal: exactly one king per side,
+// no pawns on the first or last rank and the side not to move is not in check
+func (board *Board) validatePosition() error {
+	if bits.OnesCount64(board.bitboards[WK]) != 1 || bits.OnesCount64(board.bitboards[BK]) != 1 {
+		return fmt.Errorf("Expected exactly one king per side, found %d white and %d black kings",
+			bits.OnesCount64(board.bitboards[WK]), bits.OnesCount64(board.bitboards[BK]))
+	}
+	if (board.bitboards[WP]|board.bitboards[BP])&(Rank1|Rank8) != 0 {
+		return fmt.Errorf("Pawns are not allowed on the first or last rank")
+	}
+
+	// the side not to move can't be in check, otherwise its king could be captured
+	board.Side ^= 1
+	board.UpdateBitMasks()
+	inCheck := board.kingAttacked()
+	board.Side ^= 1
+	board.UpdateBitMasks()
+	if inCheck {
+		return fmt.Errorf("The side not to move (%c) is in check", SideChar[board.Side^1])
+	}
+	return nil
+}
 
-	if newChar != "-" {
-		file := newChar[0] - "a"[0]
-		char++
+// Fen returns the FEN string of the current position
+func (board *Board) Fen() string {
+	var fen strings.Builder
 
-		if file < 0 || file > 7 {
-			panic(fmt.Sprintf("File out of bounds: file(%d)", file))
+	for rank := 0; rank < 8; rank++ {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece := board.position[rank*8+file]
+			if piece == NoPiece {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			fen.WriteByte(PieceChar[piece])
+		}
+		if empty > 0 {
+			fen.WriteString(strconv.Itoa(empty))
 		}
+		if rank < 7 {
+			fen.WriteByte('/')
+		}
+	}
+
+	fen.WriteByte(' ')
+	fen.WriteByte(SideChar[board.Side])
+	fen.WriteByte(' ')
+
+	castling := ""
+	if board.castlePermissions&WhiteKingCastling != 0 {
+		castling += "K"
+	}
+	if board.castlePermissions&WhiteQueenCastling != 0 {
+		castling += "Q"
+	}
+	if board.castlePermissions&BlackKingCastling != 0 {
+		castling += "k"
+	}
+	if board.castlePermissions&BlackQueenCastling != 0 {
+		castling += "q"
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	fen.WriteString(castling)
+	fen.WriteByte(' ')
 
-		board.bitboards[EP] = FileMasks8[file]
-		// hash en passant
-		board.positionKey ^= PieceKeys[EP][bits.TrailingZeros64(board.bitboards[EP])]
+	if board.bitboards[EP] == 0 {
+		fen.WriteByte('-')
+	} else {
+		// the en passant square is behind the pawn that just moved 2 squares forward
+		file := bits.TrailingZeros64(board.bitboards[EP])
+		rank := "6"
+		if board.Side == Black {
+			rank = "3"
+		}
+		fen.WriteString(GetSquareString(file)[:1] + rank)
 	}
+
+	fen.WriteString(fmt.Sprintf(" %d %d", board.fiftyMove, board.fullMove))
+	return fen.String()
 }
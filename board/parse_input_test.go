@@ -1,6 +1,8 @@
 package board
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"testing"
 )
 
@@ -108,3 +110,155 @@ func TestPerformMovesNegative(t *testing.T) {
 		t.Errorf("There should be an error in move sequence: %s\n", moveSeq)
 	}
 }
+
+func TestFenRoundTrip(t *testing.T) {
+	InitHashKeys()
+
+	var positions []PerftPosition
+	dat, err := ioutil.ReadFile("../test_positions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(dat, &positions); err != nil {
+		t.Fatal(err)
+	}
+
+	fens := []string{StartingPosition}
+	for _, position := range positions {
+		fens = append(fens, position.Fen)
+	}
+
+	for _, fen := range fens {
+		board := Board{}
+		board.ParseFen(fen)
+
+		if board.Fen() != fen {
+			t.Errorf("FEN round trip failed:\nExpected: %s\nActual:   %s\n", fen, board.Fen())
+		}
+	}
+}
+
+func TestFenAfterMoves(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+
+	expectedFens := []string{
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2",
+		"rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2",
+		"rnbqkbnr/pp2pppp/3p4/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 3",
+		"rnbqkbnr/pp2pppp/3p4/2p5/4P3/5N2/PPPPBPPP/RNBQK2R b KQkq - 1 3",
+		"rnbqkb1r/pp2pppp/3p1n2/2p5/4P3/5N2/PPPPBPPP/RNBQK2R w KQkq - 2 4",
+		"rnbqkb1r/pp2pppp/3p1n2/2p5/4P3/5N2/PPPPBPPP/RNBQ1RK1 b kq - 3 4",
+	}
+	moves := []string{"e2e4", "c7c5", "g1f3", "d7d6", "f1e2", "g8f6", "e1g1"}
+
+	for idx, move := range moves {
+		board.MakeMoves(move)
+		if board.Fen() != expectedFens[idx] {
+			t.Errorf("Incorrect FEN after %s:\nExpected: %s\nActual:   %s\n", move, expectedFens[idx], board.Fen())
+		}
+	}
+
+	for idx := len(moves) - 1; idx > 0; idx-- {
+		board.TakeMove()
+		if board.Fen() != expectedFens[idx-1] {
+			t.Errorf("Incorrect FEN after taking back a move:\nExpected: %s\nActual:   %s\n", expectedFens[idx-1], board.Fen())
+		}
+	}
+}
+
+func TestParseFenMoveCounters(t *testing.T) {
+	board := Board{}
+	board.ParseFen("8/8/8/2k5/2pP4/8/B7/4K3 b - d3 5 3")
+
+	if board.fiftyMove != 5 || board.fullMove != 3 {
+		t.Errorf("Expected halfmove clock 5 and fullmove number 3, got %d and %d", board.fiftyMove, board.fullMove)
+	}
+
+	// missing move counters default to 0 and 1
+	board.ParseFen("8/8/8/2k5/2pP4/8/B7/4K3 b - d3")
+	if board.fiftyMove != 0 || board.fullMove != 1 {
+		t.Errorf("Expected default halfmove clock 0 and fullmove number 1, got %d and %d", board.fiftyMove, board.fullMove)
+	}
+}
+
+func TestParseFenErrors(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen string
+		err string
+	}{
+		{"", `FEN has 0 fields, expected at least 4 (pieces, side, castling, en passant): ""`},
+		{"8/8", `FEN has 1 fields, expected at least 4 (pieces, side, castling, en passant): "8/8"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq",
+			`FEN has 3 fields, expected at least 4 (pieces, side, castling, en passant): "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 extra",
+			`FEN has 7 fields, expected at most 6: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 extra"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1",
+			`Piece placement has 7 ranks, expected 8: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/8 w KQkq - 0 1",
+			`Piece placement has 9 ranks, expected 8: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/8"`},
+		{"rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", `Rank 7 describes 7 squares, expected 8: "ppppppp"`},
+		{"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", `Unknown piece '9' on rank 6`},
+		{"rnbqkbnr/pppppppp/44/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", `Consecutive digits on rank 6: "44"`},
+		{"rnbqkbnr/ppp1pppp/8/3p13/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", `Consecutive digits on rank 5: "3p13"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNRR w KQkq - 0 1", `Rank 1 describes 9 squares, expected 8: "RNBQKBNRR"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1", `Unknown piece 'X' on rank 1`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", `Unknown side to move: "x"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1", `Invalid castling permissions: "KQkx"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KKq - 0 1", `Invalid castling permissions: "KKq"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w KQkq - 0 1", `Castling permission K without king on e1 and rook on h1`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1", `Invalid en passant square: "e9"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq i6 0 1", `Invalid en passant square: "i6"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1", `En passant square e6 without a pawn that just moved 2 squares`},
+		{"rnbqkbnr/pppp1ppp/8/4p3/8/8/PPPPPPPP/RNBQKBNR w KQkq e3 0 1", `Invalid en passant square: "e3"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", `Invalid halfmove clock: "-1"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 x", `Invalid fullmove number: "x"`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", `Invalid fullmove number: "0"`},
+		{"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1", `Expected exactly one king per side, found 1 white and 0 black kings`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBKKBNR w kq - 0 1", `Expected exactly one king per side, found 2 white and 1 black kings`},
+		{"rnbqkbnP/pppppppp/8/8/8/8/PPPPPPP1/RNBQKBNR w KQq - 0 1", `Pawns are not allowed on the first or last rank`},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNp w Qkq - 0 1", `Pawns are not allowed on the first or last rank`},
+		{"4k2R/8/8/8/8/8/8/4K3 w - - 0 1", `The side not to move (b) is in check`},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		if err := board.ParseFen(test.fen); err == nil || err.Error() != test.err {
+			t.Errorf("%q: expected the error %q, got %v", test.fen, test.err, err)
+		}
+	}
+}
+
+func TestParseFenValid(t *testing.T) {
+	InitHashKeys()
+
+	validFens := []string{
+		StartingPosition,
+		"4k3/8/8/8/8/8/8/4K2R b K - 0 1",     // side to move is in check
+		"8/8/8/2k5/2pP4/8/B7/4K3 b - d3",     // no move counters
+		"8/8/8/2k5/2pP4/8/B7/4K3  b  -  d3 ", // extra whitespace
+		"rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+	}
+
+	for _, fen := range validFens {
+		board := Board{}
+		if err := board.ParseFen(fen); err != nil {
+			t.Errorf("Unexpected error for %q: %v", fen, err)
+		}
+	}
+}
+
+func TestPositionKeyEnPassant(t *testing.T) {
+	InitHashKeys()
+	withEnPassant, withoutEnPassant := Board{}, Board{}
+	withEnPassant.ParseFen("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3")
+	withoutEnPassant.ParseFen("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 3")
+
+	if withEnPassant.positionKey == withoutEnPassant.positionKey {
+		t.Errorf("Position key does not depend on the en passant square")
+	}
+}
@@ -0,0 +1,63 @@
+package board
+
+import (
+	"unsafe"
+)
+
+// DefaultPawnHashSize default size of the pawn hash table in megabytes
+const DefaultPawnHashSize int = 2
+
+// PawnEntry evaluation of a pawn structure
+type PawnEntry struct {
+	PawnKey uint64
+	// Score pawn structure score from white's perspective
+	Score EvalScore
+}
+
+// PawnHashTable caches the evaluation of pawn structures, indexed by the pawn key. The pawn
+// structure terms only change when a pawn moves, so most evaluations are found in the table.
+// The entries depend on the evaluation parameters, so the table has to be cleared when they change
+type PawnHashTable struct {
+	entries []PawnEntry
+	mask    uint64 // len(entries)-1, number of entries is always a power of 2
+
+	// statistics
+	Hits   uint64
+	Misses uint64
+}
+
+// NewPawnHashTable creates a pawn hash table that uses (at most) the given number of megabytes
+func NewPawnHashTable(megabytes int) *PawnHashTable {
+	if megabytes < 1 {
+		megabytes = 1
+	}
+
+	entryNum := uint64(megabytes) * 1024 * 1024 / uint64(unsafe.Sizeof(PawnEntry{}))
+	// round down to a power of 2 so the index can be computed with a mask
+	size := uint64(1)
+	for size*2 <= entryNum {
+		size *= 2
+	}
+	return &PawnHashTable{entries: make([]PawnEntry, size), mask: size - 1}
+}
+
+// Clear removes all entries from the table
+func (table *PawnHashTable) Clear() {
+	for i := range table.entries {
+		table.entries[i] = PawnEntry{}
+	}
+	table.Hits = 0
+	table.Misses = 0
+}
+
+// Probe returns the entry of the pawn structure if it is stored in the table
+func (table *PawnHashTable) Probe(pawnKey uint64) (entry *PawnEntry, found bool) {
+	entry = &table.entries[pawnKey&table.mask]
+	// empty entries match the pawn key 0 of positions without pawns, which are correctly scored 0
+	if entry.PawnKey == pawnKey {
+		table.Hits++
+		return entry, true
+	}
+	table.Misses++
+	return entry, false
+}
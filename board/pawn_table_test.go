@@ -0,0 +1,86 @@
+package board
+
+import (
+	"testing"
+)
+
+func TestPawnKey(t *testing.T) {
+	InitHashKeys()
+
+	board := Board{}
+	board.ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	initialKey := board.pawnKey
+	if initialKey != GeneratePawnKey(&board) {
+		t.Fatalf("Pawn key of the parsed position is incorrect")
+	}
+
+	// the pawn key has to follow all moves and take backs
+	var walk func(depth int)
+	walk = func(depth int) {
+		if depth == 0 {
+			return
+		}
+		moveList := board.GetMoves()
+		for i := 0; i < moveList.Count; i++ {
+			move := moveList.Moves[i].Move
+			pieceMoved := board.position[FromSq(move)]
+			keyBefore := board.pawnKey
+
+			board.MakeMove(move)
+			if board.pawnKey != GeneratePawnKey(&board) {
+				t.Fatalf("Incorrect pawn key after %s in %s", GetMoveString(move), board.Fen())
+			}
+			// only pawn moves and pawn captures change the pawn structure
+			pawnMove := pieceMoved == WP || pieceMoved == BP || Captured(move) == WP || Captured(move) == BP
+			if !pawnMove && board.pawnKey != keyBefore {
+				t.Fatalf("Pawn key changed after %s", GetMoveString(move))
+			}
+			walk(depth - 1)
+			board.TakeMove()
+		}
+	}
+	walk(3)
+
+	if board.pawnKey != initialKey {
+		t.Errorf("Pawn key was not restored after taking back the moves")
+	}
+}
+
+func TestPawnHashTable(t *testing.T) {
+	InitHashKeys()
+
+	table := NewPawnHashTable(1)
+	for _, fen := range []string{
+		StartingPosition,
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"4k3/8/8/8/8/8/8/4K3 w - - 0 1",
+		"4k3/pp6/8/3P4/8/8/PP6/4K3 b - - 0 1",
+	} {
+		board := Board{}
+		board.ParseFen(fen)
+		expected := board.EvalPosition()
+		structure := board.evalPawnStructure(nil).Score
+
+		board.pawnTable = table
+		hits := table.Hits
+		// the first evaluation stores the entry, the second one uses it
+		for i := 0; i < 2; i++ {
+			if score := board.EvalPosition(); score != expected {
+				t.Errorf("%s: evaluation with pawn hash table %d != %d", fen, score, expected)
+			}
+		}
+		if table.Hits == hits {
+			t.Errorf("%s: pawn hash table was not used", fen)
+		}
+		if entry, found := table.Probe(board.pawnKey); !found || entry.Score != structure {
+			t.Errorf("%s: incorrect pawn structure score in the pawn hash table", fen)
+		}
+	}
+
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	table.Clear()
+	if _, found := table.Probe(board.pawnKey); found || table.Hits != 0 {
+		t.Errorf("Table was not cleared")
+	}
+}
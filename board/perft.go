@@ -1,38 +1,131 @@
 package board
 
-// import "fmt"
-
-var PerftMoveCounter int
-var PerftMaxDepth int
-var PerftCaptures int
-var PerftCastles int
-var PerftEnPassant int
-var PerftPromotions int
-
-func Perft(board *Board, depth int) {
-	if depth < PerftMaxDepth {
-		moveList := board.GetMoves()
-		// PrintMoveList(&moveList)
-
-		for i := 0; i < moveList.Count; i++ {
-			move := moveList.Moves[i].Move
-			
-			board.MakeMove(move)
-			
-			// var currentMoveCount int
-			// if depth == 0 {
-			// 	currentMoveCount = PerftMoveCounter
-			// }
-
-			if (depth + 1) == PerftMaxDepth {
-				PerftMoveCounter++
-			}
-			Perft(board, depth+1)
-			board.TakeMove()
-			// if depth == 0 {
-			// 	currentMoveCount = PerftMoveCounter - currentMoveCount
-			// 	fmt.Printf("%s: %d\n", GetMoveString(move), currentMoveCount)
-			// }
+import (
+	"fmt"
+	"math/bits"
+)
+
+// PerftResult number of leaf nodes of a perft run and how many of them fall into each category.
+// The categories describe the last move that lead to the leaf node
+type PerftResult struct {
+	Nodes            uint64
+	Captures         uint64
+	EnPassant        uint64
+	Castles          uint64
+	Promotions       uint64
+	Checks           uint64
+	DiscoveredChecks uint64
+	DoubleChecks     uint64
+	Checkmates       uint64
+}
+
+// Add adds the counts of another result
+func (result *PerftResult) Add(other PerftResult) {
+	result.Nodes += other.Nodes
+	result.Captures += other.Captures
+	result.EnPassant += other.EnPassant
+	result.Castles += other.Castles
+	result.Promotions += other.Promotions
+	result.Checks += other.Checks
+	result.DiscoveredChecks += other.DiscoveredChecks
+	result.DoubleChecks += other.DoubleChecks
+	result.Checkmates += other.Checkmates
+}
+
+// countMove classifies the move that was just made on the board
+func (result *PerftResult) countMove(board *Board, move int) {
+	result.Nodes++
+	if Captured(move) != NoPiece {
+		result.Captures++
+	}
+	if EnPassantFlag(move) != 0 {
+		result.EnPassant++
+	}
+	if CastleFlag(move) != 0 {
+		result.Castles++
+	}
+	if Promoted(move) != NoPiece {
+		result.Promotions++
+	}
+
+	board.UpdateBitMasks()
+	checkers := board.getCheckers(board.bitboards[board.Side*6+WK])
+	if checkers == 0 {
+		return
+	}
+	result.Checks++
+
+	// squares of the pieces that were moved, a check from any other piece was discovered
+	movedPieces := uint64(1) << ToSq(move)
+	if CastleFlag(move) != 0 {
+		// the rook ends up between the from and to square of the king
+		movedPieces = uint64(1) << ((FromSq(move) + ToSq(move)) / 2)
+	}
+	// double checks are counted separately from discovered checks
+	if bits.OnesCount64(checkers) > 1 {
+		result.DoubleChecks++
+	} else if checkers&^movedPieces != 0 {
+		result.DiscoveredChecks++
+	}
+
+	moveList := board.GetMoves()
+	if moveList.Count == 0 {
+		result.Checkmates++
+	}
+}
+
+// Perft walks the move generation tree of all legal moves up to the given depth and counts the leaf nodes
+func Perft(board *Board, depth int) (result PerftResult) {
+	if depth <= 0 {
+		result.Nodes = 1
+		return result
+	}
+
+	moveList := board.GetMoves()
+	for i := 0; i < moveList.Count; i++ {
+		move := moveList.Moves[i].Move
+
+		board.MakeMove(move)
+		if depth == 1 {
+			result.countMove(board, move)
+		} else {
+			result.Add(Perft(board, depth-1))
+		}
+		board.TakeMove()
+	}
+	return result
+}
+
+// PerftDivideResult perft result for the subtree of a single root move
+type PerftDivideResult struct {
+	Move int
+	PerftResult
+}
+
+// String returns the divide line in the format used by most engines i.e. "e2e4: 20"
+func (result PerftDivideResult) String() string {
+	return fmt.Sprintf("%s: %d", GetMoveString(result.Move), result.Nodes)
+}
+
+// PerftDivide runs perft separately for every legal root move. Comparing the node counts
+// per root move with a reference engine narrows down move generation bugs
+func PerftDivide(board *Board, depth int) []PerftDivideResult {
+	var results []PerftDivideResult
+
+	moveList := board.GetMoves()
+	for i := 0; i < moveList.Count; i++ {
+		move := moveList.Moves[i].Move
+
+		board.MakeMove(move)
+		result := PerftDivideResult{Move: move}
+		if depth <= 1 {
+			result.countMove(board, move)
+		} else {
+			result.PerftResult = Perft(board, depth-1)
 		}
+		board.TakeMove()
+
+		results = append(results, result)
 	}
+	return results
 }
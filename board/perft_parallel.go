@@ -0,0 +1,163 @@
+package board
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// perftHashEntry perft result of a position at a given depth. The check word is the key xored
+// with all counters, so entries that were torn by concurrent writes are detected and ignored
+type perftHashEntry struct {
+	check  uint64
+	result PerftResult
+}
+
+// perftResultWords number of counters in PerftResult
+const perftResultWords int = int(unsafe.Sizeof(PerftResult{}) / 8)
+
+// words returns the counters of the result as an array, PerftResult only consists of uint64 counters
+func (result *PerftResult) words() *[perftResultWords]uint64 {
+	return (*[perftResultWords]uint64)(unsafe.Pointer(result))
+}
+
+// PerftHashTable lock-free hash table of perft results that can be shared between goroutines
+type PerftHashTable struct {
+	entries []perftHashEntry
+	mask    uint64
+}
+
+// NewPerftHashTable creates a perft hash table that uses (at most) the given number of megabytes
+func NewPerftHashTable(megabytes int) *PerftHashTable {
+	if megabytes < 1 {
+		megabytes = 1
+	}
+	entryNum := uint64(megabytes) * 1024 * 1024 / uint64(unsafe.Sizeof(perftHashEntry{}))
+	size := uint64(1)
+	for size*2 <= entryNum {
+		size *= 2
+	}
+	return &PerftHashTable{entries: make([]perftHashEntry, size), mask: size - 1}
+}
+
+// perftKey combines the position key and the remaining depth
+func perftKey(positionKey uint64, depth int) uint64 {
+	return positionKey ^ (uint64(depth) * 0x9E3779B97F4A7C15)
+}
+
+func (table *PerftHashTable) probe(positionKey uint64, depth int) (result PerftResult, found bool) {
+	key := perftKey(positionKey, depth)
+	entry := &table.entries[key&table.mask]
+
+	check := atomic.LoadUint64(&entry.check)
+	words, entryWords := result.words(), entry.result.words()
+	for i := range words {
+		words[i] = atomic.LoadUint64(&entryWords[i])
+		check ^= words[i]
+	}
+	return result, check == key
+}
+
+func (table *PerftHashTable) store(positionKey uint64, depth int, result PerftResult) {
+	key := perftKey(positionKey, depth)
+	entry := &table.entries[key&table.mask]
+
+	check := key
+	words, entryWords := result.words(), entry.result.words()
+	for i := range words {
+		atomic.StoreUint64(&entryWords[i], words[i])
+		check ^= words[i]
+	}
+	atomic.StoreUint64(&entry.check, check)
+}
+
+// perftHashed is Perft that looks up and stores the results of subtrees in the hash table
+func perftHashed(board *Board, depth int, table *PerftHashTable) (result PerftResult) {
+	// the hash table doesn't pay off for the last ply
+	if depth <= 1 || table == nil {
+		return Perft(board, depth)
+	}
+	if result, found := table.probe(board.positionKey, depth); found {
+		return result
+	}
+
+	moveList := board.GetMoves()
+	for i := 0; i < moveList.Count; i++ {
+		board.MakeMove(moveList.Moves[i].Move)
+		result.Add(perftHashed(board, depth-1, table))
+		board.TakeMove()
+	}
+
+	table.store(board.positionKey, depth, result)
+	return result
+}
+
+// perftSplitDepth number of plies of the move tree that are split into tasks for the workers.
+// Splitting at the replies to the root moves gives the workers enough tasks to balance the load
+const perftSplitDepth int = 2
+
+// ParallelPerft is Perft distributed over a pool of workers (runtime.GOMAXPROCS if workers <= 0).
+// The workers share the hash table, which can be nil to disable hashing. The counts are identical to Perft
+func ParallelPerft(board *Board, depth, workers int, table *PerftHashTable) PerftResult {
+	splitDepth := perftSplitDepth
+	if depth-1 < splitDepth {
+		splitDepth = depth - 1
+	}
+	if splitDepth < 1 {
+		return Perft(board, depth)
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	tasks := make(chan []int)
+	results := make(chan PerftResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		// every worker plays on its own copy of the board
+		workerBoard := *board
+		go func() {
+			defer wg.Done()
+			for moves := range tasks {
+				for _, move := range moves {
+					workerBoard.MakeMove(move)
+				}
+				results <- perftHashed(&workerBoard, depth-len(moves), table)
+				for range moves {
+					workerBoard.TakeMove()
+				}
+			}
+		}()
+	}
+
+	go func() {
+		board.generatePerftTasks(splitDepth, nil, tasks)
+		close(tasks)
+		wg.Wait()
+		close(results)
+	}()
+
+	var total PerftResult
+	for result := range results {
+		total.Add(result)
+	}
+	return total
+}
+
+// generatePerftTasks sends all move sequences of the given length to the tasks channel
+func (board *Board) generatePerftTasks(depth int, moves []int, tasks chan<- []int) {
+	if depth == 0 {
+		tasks <- append([]int(nil), moves...)
+		return
+	}
+
+	moveList := board.GetMoves()
+	for i := 0; i < moveList.Count; i++ {
+		move := moveList.Moves[i].Move
+		board.MakeMove(move)
+		board.generatePerftTasks(depth-1, append(moves, move), tasks)
+		board.TakeMove()
+	}
+}
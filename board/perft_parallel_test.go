@@ -0,0 +1,72 @@
+package board
+
+import (
+	"testing"
+)
+
+func TestParallelPerft(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen   string
+		depth int
+	}{
+		{StartingPosition, 4},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 2},
+		{"8/8/8/2k5/2pP4/8/B7/4K3 b - d3 5 3", 1},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+		expected := Perft(&board, test.depth)
+
+		// a tiny table forces a lot of replacements
+		for _, table := range []*PerftHashTable{nil, NewPerftHashTable(1)} {
+			for _, workers := range []int{1, 4} {
+				if result := ParallelPerft(&board, test.depth, workers, table); result != expected {
+					t.Errorf("Parallel perft %d with %d workers differs for %s:\nExpected: %+v\nActual:   %+v",
+						test.depth, workers, test.fen, expected, result)
+				}
+			}
+		}
+
+		if board.Fen() != test.fen {
+			t.Errorf("Board was not restored after parallel perft: %s", board.Fen())
+		}
+	}
+}
+
+func TestPerftHashTable(t *testing.T) {
+	table := NewPerftHashTable(1)
+	result := PerftResult{Nodes: 100, Captures: 10, Checkmates: 1}
+
+	table.store(12345, 3, result)
+	if stored, found := table.probe(12345, 3); !found || stored != result {
+		t.Errorf("Stored result not found: %+v", stored)
+	}
+	if _, found := table.probe(12345, 4); found {
+		t.Errorf("Found a result for a different depth")
+	}
+
+	// a torn entry must not be returned
+	entry := &table.entries[perftKey(12345, 3)&table.mask]
+	entry.result.Nodes++
+	if _, found := table.probe(12345, 3); found {
+		t.Errorf("Found a corrupted entry")
+	}
+}
+
+func BenchmarkParallelPerftStartingPositionDepth5(b *testing.B) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParallelPerft(&board, 5, 0, NewPerftHashTable(64))
+	}
+}
@@ -6,22 +6,17 @@ import (
 	"testing"
 )
 
-// todo add automatic testing of all positions from test_positions.json
-
 func TestPerftStartingPosition(t *testing.T) {
 	InitHashKeys()
 	board := Board{}
 	board.ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
 
-	PerftMoveCounter = 0
-	PerftMaxDepth = 5
-	Perft(&board, 0)
-	// fmt.Println(PerftMoveCounter)
-	// fmt.Println(PerftCaptures)
-	// fmt.Println(PerftEnPassant)
-	// fmt.Println(PerftPromotions)
-	if PerftMoveCounter != 4865609 {
-		t.Errorf("Expected 4865609 moves at depth 5 from starting position, got %d\n", PerftMoveCounter)
+	result := Perft(&board, 5)
+	expected := PerftResult{
+		Nodes: 4865609, Captures: 82719, EnPassant: 258, Checks: 27351, DiscoveredChecks: 6, Checkmates: 347,
+	}
+	if result != expected {
+		t.Errorf("Expected %+v at depth 5 from starting position, got %+v\n", expected, result)
 	}
 }
 
@@ -30,11 +25,9 @@ func TestPerftPosition1(t *testing.T) {
 	board := Board{}
 	board.ParseFen("rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8")
 
-	PerftMoveCounter = 0
-	PerftMaxDepth = 3
-	Perft(&board, 0)
-	if PerftMoveCounter != 62379 {
-		t.Errorf("Expected 62379 possible moves, got %d instead.", PerftMoveCounter)
+	result := Perft(&board, 3)
+	if result.Nodes != 62379 {
+		t.Errorf("Expected 62379 possible moves, got %d instead.", result.Nodes)
 	}
 }
 
@@ -43,11 +36,63 @@ func TestPerftPosition2(t *testing.T) {
 	board := Board{}
 	board.ParseFen("r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10")
 
-	PerftMoveCounter = 0
-	PerftMaxDepth = 3
-	Perft(&board, 0)
-	if PerftMoveCounter != 89890 {
-		t.Errorf("Expected 89890 possible moves, got %d instead.", PerftMoveCounter)
+	result := Perft(&board, 3)
+	if result.Nodes != 89890 {
+		t.Errorf("Expected 89890 possible moves, got %d instead.", result.Nodes)
+	}
+}
+
+func TestPerftCategories(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen      string
+		depth    int
+		expected PerftResult
+	}{
+		{
+			"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3,
+			PerftResult{Nodes: 97862, Captures: 17102, EnPassant: 45, Castles: 3162, Checks: 993, Checkmates: 1},
+		},
+		{
+			"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5,
+			PerftResult{Nodes: 674624, Captures: 52051, EnPassant: 1165, Checks: 52950, DiscoveredChecks: 1292, DoubleChecks: 3},
+		},
+		{
+			"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3,
+			PerftResult{Nodes: 9467, Captures: 1021, EnPassant: 4, Promotions: 120, Checks: 38, DiscoveredChecks: 2, Checkmates: 22},
+		},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+
+		if result := Perft(&board, test.depth); result != test.expected {
+			t.Errorf("Incorrect perft %d result for %s:\nExpected: %+v\nActual:   %+v", test.depth, test.fen, test.expected, result)
+		}
+	}
+}
+
+func TestPerftDivide(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+
+	results := PerftDivide(&board, 3)
+	if len(results) != 20 {
+		t.Fatalf("Expected 20 root moves, got %d", len(results))
+	}
+
+	var total PerftResult
+	for _, result := range results {
+		total.Add(result.PerftResult)
+		if GetMoveString(result.Move) == "e2e4" && result.String() != "e2e4: 600" {
+			t.Errorf("Expected e2e4: 600, got %s", result)
+		}
+	}
+	if total != Perft(&board, 3) {
+		t.Errorf("Divide results don't add up to the perft result: %+v", total)
 	}
 }
 
@@ -77,13 +122,11 @@ func TestPerftPositions(t *testing.T) {
 		board := Board{}
 		board.ParseFen(position.Fen)
 		
-		PerftMoveCounter = 0
-		PerftMaxDepth = position.Depth
-		Perft(&board, 0)
+		result := Perft(&board, position.Depth)
 
-		if PerftMoveCounter != position.Nodes {
+		if result.Nodes != uint64(position.Nodes) {
 			t.Errorf("I=%d: Expected %d possible moves, got %d instead. \nFEN: %s\n", 
-				i, position.Nodes, PerftMoveCounter, position.Fen)
+				i, position.Nodes, result.Nodes, position.Fen)
 		}
 	}
 }
@@ -92,13 +135,10 @@ func BenchmarkPerftStartingPositionDepth3(b *testing.B) {
 	board := Board{}
 	board.ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
 
-	PerftMoveCounter = 0
-	PerftMaxDepth = 3
-
 	// This performs ~54% faster than hugo
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Perft(&board, 0)
+		Perft(&board, 3)
 	}
 	b.StopTimer()
 }
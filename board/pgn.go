@@ -0,0 +1,663 @@
+package board
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// pgnLineLength maximum length of an exported PGN line, the standard requires lines below 80 characters
+const pgnLineLength int = 79
+
+// SevenTagRoster tags that every PGN game has, they are always exported first and in this order
+var SevenTagRoster = [...]string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// suffixNags maps move suffix annotations to their numeric annotation glyphs
+var suffixNags = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// Tag single PGN tag pair i.e. [Event "Casual game"]
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// GameNode a move in a game tree. The root node of a game has no move and holds the starting position
+type GameNode struct {
+	Move            int         // move that leads to this node from the parent node
+	Parent          *GameNode   // nil for the root node
+	Children        []*GameNode // continuations, Children[0] is the main line and the rest are variations
+	Comment         string      // comment after the move (or before the first move for the root node)
+	StartingComment string      // comment before the move, only used for the first move of a variation
+	Nags            []int       // numeric annotation glyphs i.e. 1 for "!"
+}
+
+// AddVariation adds a continuation to the node and returns it. The first continuation is the main line
+func (node *GameNode) AddVariation(move int) *GameNode {
+	child := &GameNode{Move: move, Parent: node}
+	node.Children = append(node.Children, child)
+	return child
+}
+
+// Game a game with its tags and a tree of moves
+type Game struct {
+	Tags []Tag
+	Root *GameNode
+}
+
+// NewGame creates a game from the starting position with the seven tag roster set to unknown values
+func NewGame() *Game {
+	game := &Game{Root: &GameNode{}}
+	for _, name := range SevenTagRoster {
+		game.SetTag(name, "?")
+	}
+	game.SetTag("Result", "*")
+	return game
+}
+
+// Tag returns the value of a tag or an empty string if the game doesn't have the tag
+func (game *Game) Tag(name string) string {
+	for _, tag := range game.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// SetTag sets the value of a tag, new tags are added after the existing ones
+func (game *Game) SetTag(name, value string) {
+	for i := range game.Tags {
+		if game.Tags[i].Name == name {
+			game.Tags[i].Value = value
+			return
+		}
+	}
+	game.Tags = append(game.Tags, Tag{name, value})
+}
+
+// StartFen returns the FEN of the starting position of the game (given by the FEN tag)
+func (game *Game) StartFen() string {
+	if fen := game.Tag("FEN"); fen != "" {
+		return fen
+	}
+	return StartingPosition
+}
+
+// NewBoard returns a board set up with the starting position of the game
+func (game *Game) NewBoard() (*Board, error) {
+	board := &Board{}
+	if err := board.ParseFen(game.StartFen()); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// MainLine returns the nodes of the main line of the game, without the root node
+func (game *Game) MainLine() []*GameNode {
+	var nodes []*GameNode
+	for node := game.Root; len(node.Children) > 0; node = node.Children[0] {
+		nodes = append(nodes, node.Children[0])
+	}
+	return nodes
+}
+
+// pgnToken kinds of tokens in a PGN file
+const (
+	tokenEOF int = iota
+	tokenTagStart
+	tokenTagEnd
+	tokenString
+	tokenSymbol
+	tokenComment
+	tokenNag
+	tokenVariationStart
+	tokenVariationEnd
+)
+
+type pgnToken struct {
+	kind int
+	text string
+	line int
+}
+
+// PGNReader reads games from PGN text one at a time
+type PGNReader struct {
+	reader *bufio.Reader
+	line   int
+	column int       // column of the last character that was read, 0 after a newline
+	unread *pgnToken // token that was read but belongs to the next game
+}
+
+// NewPGNReader creates a reader for PGN text
+func NewPGNReader(reader io.Reader) *PGNReader {
+	return &PGNReader{reader: bufio.NewReader(reader), line: 1}
+}
+
+// ReadPGN reads all games from a PGN string
+func ReadPGN(pgn string) ([]*Game, error) {
+	var games []*Game
+	reader := NewPGNReader(strings.NewReader(pgn))
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+		games = append(games, game)
+	}
+}
+
+func (pr *PGNReader) readByte() (byte, bool) {
+	char, err := pr.reader.ReadByte()
+	if err != nil {
+		return 0, false
+	}
+	if char == '\n' {
+		pr.line++
+		pr.column = 0
+	} else {
+		pr.column++
+	}
+	return char, true
+}
+
+// peekByte returns the next character without consuming it
+func (pr *PGNReader) peekByte() (byte, bool) {
+	chars, err := pr.reader.Peek(1)
+	if err != nil {
+		return 0, false
+	}
+	return chars[0], true
+}
+
+// skipLine skips the rest of the current line
+func (pr *PGNReader) skipLine() {
+	for {
+		char, ok := pr.readByte()
+		if !ok || char == '\n' {
+			return
+		}
+	}
+}
+
+// readUntil returns all characters until the end character, which is consumed
+func (pr *PGNReader) readUntil(end byte) (string, bool) {
+	var text strings.Builder
+	for {
+		char, ok := pr.readByte()
+		if !ok {
+			return text.String(), false
+		}
+		if char == end {
+			return text.String(), true
+		}
+		text.WriteByte(char)
+	}
+}
+
+func isSymbolChar(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') ||
+		strings.IndexByte("_+#=:-/!?*.", char) != -1
+}
+
+// nextToken returns the next token of the PGN text
+func (pr *PGNReader) nextToken() (pgnToken, error) {
+	if pr.unread != nil {
+		token := *pr.unread
+		pr.unread = nil
+		return token, nil
+	}
+
+	for {
+		char, ok := pr.readByte()
+		if !ok {
+			return pgnToken{kind: tokenEOF, line: pr.line}, nil
+		}
+		line := pr.line
+
+		switch {
+		case char == ' ' || char == '\t' || char == '\r' || char == '\n':
+			continue
+		case char == '%' && pr.column == 1:
+			// escape mechanism, the whole line is ignored
+			pr.skipLine()
+			continue
+		case char == ';':
+			comment, _ := pr.readUntil('\n')
+			return pgnToken{tokenComment, strings.TrimSpace(comment), line}, nil
+		case char == '{':
+			comment, ok := pr.readUntil('}')
+			if !ok {
+				return pgnToken{}, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			return pgnToken{tokenComment, strings.Join(strings.Fields(comment), " "), line}, nil
+		case char == '[':
+			return pgnToken{tokenTagStart, "[", line}, nil
+		case char == ']':
+			return pgnToken{tokenTagEnd, "]", line}, nil
+		case char == '(':
+			return pgnToken{tokenVariationStart, "(", line}, nil
+		case char == ')':
+			return pgnToken{tokenVariationEnd, ")", line}, nil
+		case char == '"':
+			return pr.readString(line)
+		case char == '$':
+			nag := pr.readSymbol("")
+			if _, err := strconv.Atoi(nag); err != nil {
+				return pgnToken{}, fmt.Errorf("line %d: invalid NAG $%s", line, nag)
+			}
+			return pgnToken{tokenNag, nag, line}, nil
+		case isSymbolChar(char):
+			return pgnToken{tokenSymbol, pr.readSymbol(string(char)), line}, nil
+		default:
+			return pgnToken{}, fmt.Errorf("line %d: unexpected character %q", line, char)
+		}
+	}
+}
+
+func (pr *PGNReader) readString(line int) (pgnToken, error) {
+	var text strings.Builder
+	for {
+		char, ok := pr.readByte()
+		if !ok || char == '\n' {
+			return pgnToken{}, fmt.Errorf("line %d: unterminated string", line)
+		}
+		switch char {
+		case '"':
+			return pgnToken{tokenString, text.String(), line}, nil
+		case '\\':
+			if next, ok := pr.readByte(); ok {
+				char = next
+			}
+		}
+		text.WriteByte(char)
+	}
+}
+
+// readSymbol reads the rest of a symbol that starts with the given text
+func (pr *PGNReader) readSymbol(start string) string {
+	text := []byte(start)
+	for {
+		char, ok := pr.peekByte()
+		if !ok || !isSymbolChar(char) {
+			return string(text)
+		}
+		pr.readByte()
+		text = append(text, char)
+	}
+}
+
+// pgnParser state of the game that is currently being read
+type pgnParser struct {
+	game        *Game
+	board       *Board
+	node        *GameNode // last node that was added
+	variations  []variationStart
+	preComment  string // comment before the first move of a variation
+	movetext    bool   // true once the first movetext token was read
+	resultFound bool
+	err         error // first error in the game, the rest of the game is skipped
+}
+
+// variationStart state to return to at the end of a variation
+type variationStart struct {
+	node      *GameNode
+	movesMade int
+}
+
+// Next reads the next game. io.EOF is returned when there are no more games. If a game
+// contains an error (i.e. an illegal move) the rest of the game is skipped and the error is returned,
+// reading can continue with the next game
+func (pr *PGNReader) Next() (*Game, error) {
+	parser := pgnParser{game: &Game{Root: &GameNode{}}}
+	started := false
+
+	for {
+		token, err := pr.nextToken()
+		if err != nil {
+			parser.fail(err)
+			continue
+		}
+
+		switch token.kind {
+		case tokenEOF:
+			if !started {
+				return nil, io.EOF
+			}
+			return parser.finish()
+		case tokenTagStart:
+			if parser.movetext {
+				// start of the next game, which doesn't have a result
+				pr.unread = &token
+				return parser.finish()
+			}
+			parser.fail(pr.readTag(parser.game))
+		default:
+			if parser.movetextToken(token) {
+				parser.resultFound = true
+				return parser.finish()
+			}
+		}
+		started = true
+	}
+}
+
+// readTag reads the name and value of a tag pair, the opening bracket is already read
+func (pr *PGNReader) readTag(game *Game) error {
+	name, err := pr.nextToken()
+	if err != nil {
+		return err
+	}
+	value, err := pr.nextToken()
+	if err != nil {
+		return err
+	}
+	end, err := pr.nextToken()
+	if err != nil {
+		return err
+	}
+	if name.kind != tokenSymbol || value.kind != tokenString || end.kind != tokenTagEnd {
+		return fmt.Errorf("line %d: invalid tag pair", name.line)
+	}
+	game.SetTag(name.text, value.text)
+	return nil
+}
+
+func (parser *pgnParser) fail(err error) {
+	if err != nil && parser.err == nil {
+		parser.err = err
+	}
+}
+
+// finish returns the game that was read or the first error in it
+func (parser *pgnParser) finish() (*Game, error) {
+	if parser.err != nil {
+		return nil, parser.err
+	}
+	if len(parser.variations) > 0 {
+		return nil, fmt.Errorf("unterminated variation in game %s", parser.describe())
+	}
+	if !parser.resultFound && parser.game.Tag("Result") == "" {
+		parser.game.SetTag("Result", "*")
+	}
+	return parser.game, nil
+}
+
+// describe returns the players of the game for error messages
+func (parser *pgnParser) describe() string {
+	return fmt.Sprintf("%q vs %q", parser.game.Tag("White"), parser.game.Tag("Black"))
+}
+
+// movetextToken processes a token of the movetext, returns true if the token terminates the game
+func (parser *pgnParser) movetextToken(token pgnToken) bool {
+	if !parser.movetext {
+		parser.movetext = true
+		board, err := parser.game.NewBoard()
+		if err != nil {
+			parser.fail(fmt.Errorf("line %d: invalid FEN tag: %v", token.line, err))
+		}
+		parser.board = board
+		parser.node = parser.game.Root
+	}
+
+	if token.kind == tokenSymbol && isResult(token.text) && (len(parser.variations) == 0 || parser.err != nil) {
+		parser.game.SetTag("Result", token.text)
+		return true
+	}
+	if parser.err != nil {
+		// skip the rest of a broken game
+		return false
+	}
+
+	switch token.kind {
+	case tokenComment:
+		switch {
+		case len(parser.variations) > 0 && parser.variations[len(parser.variations)-1].movesMade == 0:
+			// before the first move of a variation
+			parser.preComment = joinComments(parser.preComment, token.text)
+		default:
+			// after a move or before the first move of the game (stored in the root node)
+			parser.node.Comment = joinComments(parser.node.Comment, token.text)
+		}
+	case tokenNag:
+		nag, _ := strconv.Atoi(token.text)
+		parser.node.Nags = append(parser.node.Nags, nag)
+	case tokenVariationStart:
+		if parser.node == parser.game.Root || parser.preComment != "" {
+			parser.fail(fmt.Errorf("line %d: variation without a move to replace", token.line))
+			return false
+		}
+		parser.variations = append(parser.variations, variationStart{node: parser.node})
+		parser.board.TakeMove()
+		parser.node = parser.node.Parent
+	case tokenVariationEnd:
+		if len(parser.variations) == 0 {
+			parser.fail(fmt.Errorf("line %d: unexpected end of variation", token.line))
+			return false
+		}
+		start := parser.variations[len(parser.variations)-1]
+		parser.variations = parser.variations[:len(parser.variations)-1]
+		for ; start.movesMade > 0; start.movesMade-- {
+			parser.board.TakeMove()
+		}
+		parser.board.MakeMove(start.node.Move)
+		parser.node = start.node
+		parser.preComment = ""
+	case tokenSymbol:
+		parser.fail(parser.moveSymbol(token))
+	default:
+		parser.fail(fmt.Errorf("line %d: unexpected token %q in movetext", token.line, token.text))
+	}
+	return false
+}
+
+// moveSymbol processes a symbol token in the movetext, either a move number or a SAN move
+func (parser *pgnParser) moveSymbol(token pgnToken) error {
+	// strip move number indications i.e. "12." or "12..." (also attached to the move as in "12.e4")
+	text := strings.TrimLeft(token.text, "0123456789")
+	if strings.HasPrefix(text, ".") {
+		text = strings.TrimLeft(text, ".")
+	} else {
+		text = token.text
+	}
+	if text == "" {
+		return nil
+	}
+
+	if isResult(text) {
+		return fmt.Errorf("line %d: result %s inside a variation", token.line, text)
+	}
+
+	// suffix annotations are stored as NAGs
+	nag := suffixNags[text[len(strings.TrimRight(text, "!?")):]]
+
+	if parser.board.ply >= MaxGameMoves-1 {
+		return fmt.Errorf("line %d: game is longer than %d moves", token.line, MaxGameMoves)
+	}
+	move, err := parser.board.ParseSAN(text)
+	if err != nil {
+		return fmt.Errorf("line %d: %v in position %s", token.line, err, parser.board.Fen())
+	}
+
+	parser.node = parser.node.AddVariation(move)
+	parser.node.StartingComment = parser.preComment
+	parser.preComment = ""
+	if nag != 0 {
+		parser.node.Nags = append(parser.node.Nags, nag)
+	}
+	parser.board.MakeMove(move)
+	if len(parser.variations) > 0 {
+		parser.variations[len(parser.variations)-1].movesMade++
+	}
+	return nil
+}
+
+func isResult(text string) bool {
+	return text == "1-0" || text == "0-1" || text == "1/2-1/2" || text == "*"
+}
+
+func joinComments(comment, other string) string {
+	if comment == "" {
+		return other
+	}
+	return comment + " " + other
+}
+
+// pgnWriter collects movetext tokens and wraps them into lines
+type pgnWriter struct {
+	tokens []string
+	prefix string // prepended to the next token i.e. "(" at the start of a variation
+}
+
+func (pw *pgnWriter) add(token string) {
+	pw.tokens = append(pw.tokens, pw.prefix+token)
+	pw.prefix = ""
+}
+
+func (pw *pgnWriter) addComment(comment string) {
+	// comments can't contain the closing brace
+	words := strings.Fields(strings.ReplaceAll(comment, "}", ""))
+	if len(words) == 0 {
+		pw.add("{}")
+		return
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	for _, word := range words {
+		pw.add(word)
+	}
+}
+
+// writeMove writes a single move with its move number, comments and NAGs. The board has to be in the
+// position before the move
+func (pw *pgnWriter) writeMove(board *Board, node *GameNode, forceNumber bool) error {
+	moveList := board.GetMoves()
+	if !moveList.contains(node.Move) {
+		return fmt.Errorf("Illegal move %s in position %s", GetMoveString(node.Move), board.Fen())
+	}
+
+	if node.StartingComment != "" {
+		pw.addComment(node.StartingComment)
+		forceNumber = true
+	}
+	if board.Side == White {
+		pw.add(fmt.Sprintf("%d.", board.fullMove))
+	} else if forceNumber {
+		pw.add(fmt.Sprintf("%d...", board.fullMove))
+	}
+	pw.add(board.MoveToSAN(node.Move))
+	for _, nag := range node.Nags {
+		pw.add(fmt.Sprintf("$%d", nag))
+	}
+	if node.Comment != "" {
+		pw.addComment(node.Comment)
+	}
+	return nil
+}
+
+// writeMoves writes the main line that follows the node and all variations in it. The
+// board has to be in the position of the node and is restored afterwards
+func (pw *pgnWriter) writeMoves(board *Board, node *GameNode, forceNumber bool) error {
+	movesMade := 0
+	defer func() {
+		for ; movesMade > 0; movesMade-- {
+			board.TakeMove()
+		}
+	}()
+
+	for len(node.Children) > 0 {
+		main := node.Children[0]
+		if err := pw.writeMove(board, main, forceNumber); err != nil {
+			return err
+		}
+
+		for _, variation := range node.Children[1:] {
+			pw.prefix += "("
+			if err := pw.writeMove(board, variation, true); err != nil {
+				return err
+			}
+			board.MakeMove(variation.Move)
+			err := pw.writeMoves(board, variation, false)
+			board.TakeMove()
+			if err != nil {
+				return err
+			}
+			pw.tokens[len(pw.tokens)-1] += ")"
+		}
+
+		// the move number has to be repeated after a comment or variation
+		forceNumber = len(node.Children) > 1 || main.Comment != ""
+		board.MakeMove(main.Move)
+		movesMade++
+		node = main
+	}
+	return nil
+}
+
+// WritePGN writes the game in PGN export format
+func (game *Game) WritePGN(writer io.Writer) error {
+	board, err := game.NewBoard()
+	if err != nil {
+		return err
+	}
+
+	var pgn strings.Builder
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	for _, name := range SevenTagRoster {
+		value := game.Tag(name)
+		if value == "" {
+			value = "?"
+		}
+		fmt.Fprintf(&pgn, "[%s \"%s\"]\n", name, escape.Replace(value))
+	}
+	for _, tag := range game.Tags {
+		if !isRosterTag(tag.Name) {
+			fmt.Fprintf(&pgn, "[%s \"%s\"]\n", tag.Name, escape.Replace(tag.Value))
+		}
+	}
+	pgn.WriteString("\n")
+
+	pw := pgnWriter{}
+	if game.Root.Comment != "" {
+		pw.addComment(game.Root.Comment)
+	}
+	if err := pw.writeMoves(board, game.Root, true); err != nil {
+		return err
+	}
+	result := game.Tag("Result")
+	if !isResult(result) {
+		result = "*"
+	}
+	pw.add(result)
+
+	lineLength := 0
+	for _, token := range pw.tokens {
+		if lineLength > 0 && lineLength+1+len(token) > pgnLineLength {
+			pgn.WriteString("\n")
+			lineLength = 0
+		}
+		if lineLength > 0 {
+			pgn.WriteString(" ")
+			lineLength++
+		}
+		pgn.WriteString(token)
+		lineLength += len(token)
+	}
+	pgn.WriteString("\n\n")
+
+	_, err = io.WriteString(writer, pgn.String())
+	return err
+}
+
+func isRosterTag(name string) bool {
+	for _, rosterName := range SevenTagRoster {
+		if name == rosterName {
+			return true
+		}
+	}
+	return false
+}
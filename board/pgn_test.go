@@ -0,0 +1,219 @@
+package board
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testPgn = `[Event "Casual \"blitz\" game"]
+[Site "?"]
+[Date "2021.03.04"]
+[Round "1"]
+[White "Alice"]
+[Black "Bob"]
+[Result "1-0"]
+[ECO "C50"]
+
+{Italian game} 1. e4 e5 2. Nf3 Nc6 3.Bc4 Nf6?! (3... Bc5 {main line} 4. c3 (4. O-O)
+4... Nf6) 4. Ng5 $1 d5 5. exd5 Na5 ; the most common reply
+6. Bb5+ c6 7. dxc6 bxc6 8. Qf3!? 1-0
+
+% an escaped line (ignored)
+[Event "Second game"]
+[White "Carol"]
+[Black "Dave"]
+[SetUp "1"]
+[FEN "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"]
+
+2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0
+`
+
+func TestReadPGN(t *testing.T) {
+	InitHashKeys()
+
+	games, err := ReadPGN(testPgn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("Expected 2 games, got %d", len(games))
+	}
+
+	game := games[0]
+	if game.Tag("Event") != `Casual "blitz" game` || game.Tag("ECO") != "C50" || game.Tag("Result") != "1-0" {
+		t.Errorf("Incorrect tags: %+v", game.Tags)
+	}
+	if game.Root.Comment != "Italian game" {
+		t.Errorf("Expected the game comment before the first move, got %q", game.Root.Comment)
+	}
+
+	mainLine := game.MainLine()
+	if len(mainLine) != 15 {
+		t.Fatalf("Expected 15 moves in the main line, got %d", len(mainLine))
+	}
+
+	nf6 := mainLine[5]
+	if GetMoveString(nf6.Move) != "g8f6" || len(nf6.Nags) != 1 || nf6.Nags[0] != 6 {
+		t.Errorf("Expected Nf6 with NAG 6, got %s %v", GetMoveString(nf6.Move), nf6.Nags)
+	}
+	if len(nf6.Parent.Children) != 2 {
+		t.Fatalf("Expected a variation for the 3rd black move")
+	}
+	bc5 := nf6.Parent.Children[1]
+	if GetMoveString(bc5.Move) != "f8c5" || bc5.Comment != "main line" {
+		t.Errorf("Incorrect variation: %s %q", GetMoveString(bc5.Move), bc5.Comment)
+	}
+	// 4. c3 has a nested variation 4. O-O and continues with 4... Nf6
+	c3 := bc5.Children[0]
+	if len(bc5.Children) != 2 || GetMoveString(bc5.Children[1].Move) != "e1g1" || GetMoveString(c3.Children[0].Move) != "g8f6" {
+		t.Errorf("Incorrect nested variation")
+	}
+
+	if mainLine[6].Nags[0] != 1 || mainLine[9].Comment != "the most common reply" {
+		t.Errorf("Incorrect annotations: %v %q", mainLine[6].Nags, mainLine[9].Comment)
+	}
+	if mainLine[14].Nags[0] != 5 {
+		t.Errorf("Expected NAG 5 for the last move, got %v", mainLine[14].Nags)
+	}
+
+	// the second game starts from a FEN and ends with a checkmate
+	game = games[1]
+	board, err := game.NewBoard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range game.MainLine() {
+		board.MakeMove(node.Move)
+	}
+	if outcome := board.Outcome(); outcome.Reason != Checkmate || outcome.Result() != game.Tag("Result") {
+		t.Errorf("Expected the game to end in checkmate, got %s", outcome)
+	}
+	if game.Tag("Site") != "" {
+		t.Errorf("Tags that are not in the PGN should not be set")
+	}
+}
+
+func TestReadPGNErrors(t *testing.T) {
+	InitHashKeys()
+
+	pgn := `[Event "Illegal move"]
+
+1. e4 e5 2. Ke3 Nc6 1-0
+
+[Event "Unterminated variation"]
+
+1. e4 (1. d4 d5 *
+
+[Event "Valid"]
+
+1. d4 d5 1/2-1/2
+`
+	reader := NewPGNReader(strings.NewReader(pgn))
+
+	if _, err := reader.Next(); err == nil || !strings.Contains(err.Error(), "Ke3") {
+		t.Errorf("Expected an error for the illegal move, got %v", err)
+	}
+	if _, err := reader.Next(); err == nil {
+		t.Errorf("Expected an error for the unterminated variation")
+	}
+
+	game, err := reader.Next()
+	if err != nil || game.Tag("Event") != "Valid" || len(game.MainLine()) != 2 {
+		t.Errorf("Reading did not continue with the next game: %v", err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last game, got %v", err)
+	}
+}
+
+func TestWritePGN(t *testing.T) {
+	InitHashKeys()
+
+	game := NewGame()
+	game.SetTag("White", "Alice")
+	game.SetTag("Result", "1-0")
+	game.SetTag("Annotator", "Carol")
+
+	board, _ := game.NewBoard()
+	node := game.Root
+	for _, san := range []string{"e4", "e5", "Nf3", "Nc6"} {
+		move, err := board.ParseSAN(san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node = node.AddVariation(move)
+		board.MakeMove(move)
+	}
+	node.Comment = "four knights?"
+	board.TakeMove()
+	move, _ := board.ParseSAN("d6")
+	variation := node.Parent.AddVariation(move)
+	variation.Nags = []int{6}
+	variation.StartingComment = "Philidor"
+
+	var out bytes.Buffer
+	if err := game.WritePGN(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[Event "?"]
+[Site "?"]
+[Date "?"]
+[Round "?"]
+[White "Alice"]
+[Black "?"]
+[Result "1-0"]
+[Annotator "Carol"]
+
+1. e4 e5 2. Nf3 Nc6 {four knights?} ({Philidor} 2... d6 $6) 1-0
+
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestPGNRoundTrip(t *testing.T) {
+	InitHashKeys()
+
+	games, err := ReadPGN(testPgn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	for _, game := range games {
+		if err := game.WritePGN(&out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if len(line) > pgnLineLength {
+			t.Errorf("Line is longer than %d characters: %q", pgnLineLength, line)
+		}
+	}
+	if !strings.Contains(out.String(), "[Event \"Casual \\\"blitz\\\" game\"]") {
+		t.Errorf("Tag value was not escaped:\n%s", out.String())
+	}
+	if !strings.Contains(strings.ReplaceAll(out.String(), "\n", " "), "(3... Bc5 {main line} 4. c3 (4. O-O) 4... Nf6) 4. Ng5") {
+		t.Errorf("Incorrect variations:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "\n\n2. Qh5 Nc6") {
+		t.Errorf("Incorrect movetext for a game starting from a FEN:\n%s", out.String())
+	}
+
+	// reading the written games again has to produce the same output
+	rereadGames, err := ReadPGN(out.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rewritten bytes.Buffer
+	for _, game := range rereadGames {
+		game.WritePGN(&rewritten)
+	}
+	if rewritten.String() != out.String() {
+		t.Errorf("Round trip changed the PGN:\n%s\n%s", out.String(), rewritten.String())
+	}
+}
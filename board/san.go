@@ -0,0 +1,211 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sanPieces piece letters used in SAN, pawns don't have a letter
+const sanPieces string = "NBRQK"
+
+// pieceLetter returns the uppercase letter of a piece i.e. 'N' for both WN and BN
+func pieceLetter(piece int) byte {
+	char := PieceChar[piece]
+	if char >= 'a' && char <= 'z' {
+		char -= 'a' - 'A'
+	}
+	return char
+}
+
+// MoveToSAN returns the move in Standard Algebraic Notation (i.e. Nbd7, exd6, O-O-O, e8=Q+, Qxf7#).
+// The move has to be legal in the current position
+func (board *Board) MoveToSAN(move int) string {
+	var san strings.Builder
+
+	fromSq, toSq := FromSq(move), ToSq(move)
+	piece := board.position[fromSq]
+
+	if CastleFlag(move) != 0 {
+		if toSq%8 == 6 {
+			san.WriteString("O-O")
+		} else {
+			san.WriteString("O-O-O")
+		}
+	} else if piece == WP || piece == BP {
+		if Captured(move) != NoPiece {
+			san.WriteByte("abcdefgh"[fromSq%8])
+			san.WriteByte('x')
+		}
+		san.WriteString(GetSquareString(toSq))
+		if promoted := Promoted(move); promoted != NoPiece {
+			san.WriteByte('=')
+			san.WriteByte(pieceLetter(promoted))
+		}
+	} else {
+		san.WriteByte(pieceLetter(piece))
+		san.WriteString(board.disambiguation(move))
+		if Captured(move) != NoPiece {
+			san.WriteByte('x')
+		}
+		san.WriteString(GetSquareString(toSq))
+	}
+
+	board.MakeMove(move)
+	if board.InCheck() {
+		moveList := board.GetMoves()
+		if moveList.Count == 0 {
+			san.WriteByte('#')
+		} else {
+			san.WriteByte('+')
+		}
+	}
+	board.TakeMove()
+
+	return san.String()
+}
+
+// disambiguation returns the file, rank or square of the origin of a piece move if
+// another piece of the same type can move to the same square
+func (board *Board) disambiguation(move int) string {
+	fromSq, toSq := FromSq(move), ToSq(move)
+	piece := board.position[fromSq]
+
+	ambiguous, sameFile, sameRank := false, false, false
+	moveList := board.GetMoves()
+	for i := 0; i < moveList.Count; i++ {
+		other := FromSq(moveList.Moves[i].Move)
+		if other == fromSq || ToSq(moveList.Moves[i].Move) != toSq || board.position[other] != piece {
+			continue
+		}
+		ambiguous = true
+		if other%8 == fromSq%8 {
+			sameFile = true
+		}
+		if other/8 == fromSq/8 {
+			sameRank = true
+		}
+	}
+
+	square := GetSquareString(fromSq)
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return square[:1]
+	case !sameRank:
+		return square[1:]
+	default:
+		return square
+	}
+}
+
+// ParseSAN returns the legal move described by a move in Standard Algebraic Notation.
+// The parser is lenient: check, mate and annotation suffixes (+ # ! ?), the e.p. suffix,
+// 0-0 for castling, missing or superfluous capture signs and disambiguation, and
+// promotions without '=' (e8Q) are accepted. Coordinate notation (e2e4) is accepted as well
+func (board *Board) ParseSAN(san string) (int, error) {
+	moveList := board.GetMoves()
+
+	text := strings.TrimSpace(san)
+	text = strings.TrimSuffix(text, "e.p.")
+	text = strings.TrimSuffix(text, "ep")
+	text = strings.TrimRight(text, "+#!? ")
+	if text == "" {
+		return 0, fmt.Errorf("Empty SAN move: %q", san)
+	}
+
+	if move, err := GetMoveFromString(&moveList, text); err == nil {
+		return move, nil
+	}
+
+	switch strings.ReplaceAll(text, "0", "O") {
+	case "O-O", "OO":
+		return board.findSANMove(&moveList, san, func(move int) bool {
+			return CastleFlag(move) != 0 && ToSq(move)%8 == 6
+		})
+	case "O-O-O", "OOO":
+		return board.findSANMove(&moveList, san, func(move int) bool {
+			return CastleFlag(move) != 0 && ToSq(move)%8 == 2
+		})
+	}
+
+	// moving piece, pawns don't have a letter (a leading P is tolerated)
+	pieceChar := byte('P')
+	if strings.IndexByte(sanPieces+"P", text[0]) != -1 {
+		pieceChar = text[0]
+		text = text[1:]
+	}
+
+	// promotion piece i.e. e8=Q, e8Q or e8(Q)
+	promotionChar := byte(0)
+	text = strings.TrimSuffix(text, ")")
+	if len(text) > 0 && strings.IndexByte("NBRQnbrq", text[len(text)-1]) != -1 {
+		promotionChar = pieceLetter(PieceNotationMap[text[len(text)-1:]])
+		text = strings.TrimRight(text[:len(text)-1], "=(")
+	}
+
+	// the remaining text is [from file][from rank][x]<to square>, capture signs are ignored
+	text = strings.NewReplacer("x", "", "X", "", ":", "", "-", "").Replace(text)
+	if len(text) < 2 || len(text) > 4 {
+		return 0, fmt.Errorf("Invalid SAN move: %q", san)
+	}
+	toSq, ok := parseSquare(text[len(text)-2:])
+	if !ok {
+		return 0, fmt.Errorf("Invalid destination square in SAN move: %q", san)
+	}
+
+	fromFile, fromRank := -1, -1
+	for _, char := range text[:len(text)-2] {
+		switch {
+		case char >= 'a' && char <= 'h':
+			fromFile = int(char - 'a')
+		case char >= '1' && char <= '8':
+			fromRank = 8 - int(char-'0')
+		default:
+			return 0, fmt.Errorf("Invalid disambiguation in SAN move: %q", san)
+		}
+	}
+
+	return board.findSANMove(&moveList, san, func(move int) bool {
+		fromSq := FromSq(move)
+		if ToSq(move) != toSq || pieceLetter(board.position[fromSq]) != pieceChar || CastleFlag(move) != 0 {
+			return false
+		}
+		if (fromFile != -1 && fromSq%8 != fromFile) || (fromRank != -1 && fromSq/8 != fromRank) {
+			return false
+		}
+		if Promoted(move) == NoPiece {
+			return promotionChar == 0
+		}
+		return pieceLetter(Promoted(move)) == promotionChar
+	})
+}
+
+// findSANMove returns the only legal move that matches, otherwise the SAN move is either illegal or ambiguous
+func (board *Board) findSANMove(moveList *MoveList, san string, matches func(move int) bool) (int, error) {
+	found := 0
+	count := 0
+	for i := 0; i < moveList.Count; i++ {
+		if matches(moveList.Moves[i].Move) {
+			found = moveList.Moves[i].Move
+			count++
+		}
+	}
+
+	switch count {
+	case 0:
+		return 0, fmt.Errorf("Illegal SAN move: %q", san)
+	case 1:
+		return found, nil
+	default:
+		return 0, fmt.Errorf("Ambiguous SAN move: %q", san)
+	}
+}
+
+// parseSquare returns the square index of a square in algebraic notation i.e. e4
+func parseSquare(square string) (int, bool) {
+	if len(square) != 2 || square[0] < 'a' || square[0] > 'h' || square[1] < '1' || square[1] > '8' {
+		return 0, false
+	}
+	return int('8'-square[1])*8 + int(square[0]-'a'), true
+}
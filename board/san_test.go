@@ -0,0 +1,151 @@
+package board
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMoveToSAN(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen  string
+		move string
+		san  string
+	}{
+		{StartingPosition, "e2e4", "e4"},
+		{StartingPosition, "g1f3", "Nf3"},
+		{"R6R/8/8/4k3/8/8/8/4K3 w - - 0 1", "a8d8", "Rad8"},
+		{"R7/8/8/8/8/5k2/8/R3K3 w - - 0 1", "a1a4", "R1a4"},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a1b2", "Qa1b2"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e7e8q", "e8=Q+"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e7e8n", "e8=N"},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", "h5f7", "Qxf7#"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", "exf6"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R w KQkq - 0 1", "e1c1", "O-O-O"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R w KQkq - 0 1", "e5f7", "Nxf7"},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+		moveList := board.GetMoves()
+		move, err := GetMoveFromString(&moveList, test.move)
+		if err != nil {
+			t.Fatalf("Test move %s is not legal in %s", test.move, test.fen)
+		}
+
+		if san := board.MoveToSAN(move); san != test.san {
+			t.Errorf("Expected %s for %s in %s, got %s", test.san, test.move, test.fen, san)
+		}
+		if board.Fen() != test.fen {
+			t.Errorf("Board was not restored after MoveToSAN: %s", board.Fen())
+		}
+	}
+}
+
+func TestParseSAN(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen  string
+		san  string
+		move string
+	}{
+		{StartingPosition, "e4", "e2e4"},
+		{StartingPosition, "e4!?", "e2e4"},
+		{StartingPosition, "Pe4", "e2e4"},
+		{StartingPosition, "Nf3", "g1f3"},
+		{StartingPosition, "Ng1f3", "g1f3"},
+		{StartingPosition, "Ng1-f3", "g1f3"},
+		{StartingPosition, " g1f3 ", "g1f3"},
+		{"R6R/8/8/4k3/8/8/8/4K3 w - - 0 1", "Rhd8", "h8d8"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e8=Q+", "e7e8q"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e8Q", "e7e8q"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e8(R)", "e7e8r"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e8=b", "e7e8b"},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", "Qxf7#", "h5f7"},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", "Qf7", "h5f7"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "exf6 e.p.", "e5f6"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "ef6", "e5f6"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R w KQkq - 0 1", "0-0-0", "e1c1"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R b KQkq - 0 1", "O-O-O", "e8c8"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R b KQkq - 0 1", "bxc3", "b4c3"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q2/PPPBBPPP/R3K2R b KQkq - 0 1", "Bxe2", "a6e2"},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+
+		move, err := board.ParseSAN(test.san)
+		if err != nil {
+			t.Errorf("Unexpected error for %q in %s: %v", test.san, test.fen, err)
+			continue
+		}
+		if GetMoveString(move) != test.move {
+			t.Errorf("Expected %s for %q in %s, got %s", test.move, test.san, test.fen, GetMoveString(move))
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	InitHashKeys()
+
+	tests := []struct {
+		fen string
+		san string
+	}{
+		{StartingPosition, ""},
+		{StartingPosition, "+"},
+		{StartingPosition, "e5"},
+		{StartingPosition, "Ke2"},
+		{StartingPosition, "Qz9"},
+		{StartingPosition, "Nf3f3f3"},
+		{StartingPosition, "O-O"},
+		{"R6R/8/8/4k3/8/8/8/4K3 w - - 0 1", "Rd8"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e8"},
+		{"8/4P3/8/8/k7/8/8/4K3 w - - 0 1", "e8=K"},
+	}
+
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+
+		if move, err := board.ParseSAN(test.san); err == nil {
+			t.Errorf("Expected an error for %q in %s, got %s", test.san, test.fen, GetMoveString(move))
+		}
+	}
+}
+
+func TestSANRoundTrip(t *testing.T) {
+	InitHashKeys()
+
+	var positions []PerftPosition
+	dat, err := ioutil.ReadFile("../test_positions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(dat, &positions); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, position := range positions {
+		board := Board{}
+		board.ParseFen(position.Fen)
+
+		moveList := board.GetMoves()
+		for i := 0; i < moveList.Count; i++ {
+			move := moveList.Moves[i].Move
+			san := board.MoveToSAN(move)
+
+			parsed, err := board.ParseSAN(san)
+			if err != nil || parsed != move {
+				t.Errorf("SAN round trip failed for %s (%s) in %s: %v", GetMoveString(move), san, position.Fen, err)
+			}
+		}
+	}
+}
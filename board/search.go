@@ -0,0 +1,569 @@
+package board
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxDepth maximum depth (in plies) the search can reach
+	MaxDepth int = 64
+
+	// Infinite score bound used for alpha-beta windows
+	Infinite int = 30000
+
+	// IsMate any score above this value (or below its negative) is a mate score. Tablebase mates are
+	// found up to MaxDepth plies from the root and can be up to 255 plies long from there
+	IsMate int = Infinite - MaxDepth - 255
+)
+
+// checkUpInterval number of nodes between checks of the time limit
+const checkUpInterval uint64 = 2048
+
+// DeltaMargin safety margin used by delta pruning in the quiescence search.
+// Captures that can't raise the score above alpha even with this bonus are skipped
+const DeltaMargin int = 200
+
+// SearchResult holds the outcome of a (completed) search iteration
+type SearchResult struct {
+	BestMove int           // best move found, 0 if the position has no legal moves
+	Score    int           // score from the side to move's perspective
+	PV       []int         // principal variation starting with BestMove
+	Depth    int           // depth of the last completed iteration
+	Nodes    uint64        // nodes searched so far
+	Time     time.Duration // time spent searching so far
+	HashFull int           // transposition table usage in permille
+	TBHits   uint64        // positions scored by the tablebases
+}
+
+// SearchInfo holds search limits, statistics and the state used by a single search
+type SearchInfo struct {
+	Depth    int       // maximum depth to search to (0 means MaxDepth)
+	StopTime time.Time // time at which the search has to stop
+	TimeSet  bool      // if StopTime should be respected
+
+	// Output if set it is called after every completed iteration
+	Output func(result SearchResult)
+
+	// HashTable transposition table used by the search. If nil a table
+	// of DefaultHashSize is allocated for the duration of the search
+	HashTable *HashTable
+	// PawnHashTable caches the pawn structure evaluation. If nil a table
+	// of DefaultPawnHashSize is allocated for the duration of the search
+	PawnHashTable *PawnHashTable
+
+	StartTime     time.Time
+	Nodes         uint64
+	FailHigh      float64 // number of beta cutoffs
+	FailHighFirst float64 // number of beta cutoffs produced by the first searched move
+	TBHits        uint64  // positions scored by the tablebases
+
+	stopped   int32 // set to 1 once the search has to stop, accessed atomically
+	rootMoves []int // root moves that keep the tablebase result, nil if the root isn't in the tablebases
+	pvTable   [MaxDepth][MaxDepth]int
+	pvLength  [MaxDepth]int
+	killers   [2][MaxDepth]int // quiet moves that produced a beta cutoff at a given ply
+	history   [13][BoardSquareNum]int
+}
+
+// Stop requests the search to stop as soon as possible. Safe to call from another goroutine
+func (info *SearchInfo) Stop() {
+	atomic.StoreInt32(&info.stopped, 1)
+}
+
+// Stopped returns true if the search was requested to stop
+func (info *SearchInfo) Stopped() bool {
+	return atomic.LoadInt32(&info.stopped) == 1
+}
+
+// checkUp stops the search if the time limit was exceeded
+func (info *SearchInfo) checkUp() {
+	if info.TimeSet && time.Now().After(info.StopTime) {
+		info.Stop()
+	}
+}
+
+// clearForSearch resets statistics and move ordering heuristics before a new search
+func (info *SearchInfo) clearForSearch() {
+	info.StartTime = time.Now()
+	info.Nodes = 0
+	info.FailHigh = 0
+	info.FailHighFirst = 0
+	info.TBHits = 0
+	info.rootMoves = nil
+	info.killers = [2][MaxDepth]int{}
+	info.history = [13][BoardSquareNum]int{}
+	info.pvTable = [MaxDepth][MaxDepth]int{}
+	info.pvLength = [MaxDepth]int{}
+
+	if info.HashTable == nil {
+		info.HashTable = NewHashTable(DefaultHashSize)
+	}
+	info.HashTable.NewSearch()
+
+	if info.PawnHashTable == nil {
+		info.PawnHashTable = NewPawnHashTable(DefaultPawnHashSize)
+	}
+}
+
+// MvvLvaScores move ordering scores for captures indexed by [victim][attacker].
+// Most valuable victim / least valuable attacker captures are searched first
+var MvvLvaScores [13][13]int
+
+func init() {
+	victimScore := [13]int{0, 100, 200, 300, 400, 500, 600, 100, 200, 300, 400, 500, 600}
+	for attacker := WP; attacker <= BK; attacker++ {
+		for victim := WP; victim <= BK; victim++ {
+			MvvLvaScores[victim][attacker] = victimScore[victim] + 6 - (victimScore[attacker] / 100)
+		}
+	}
+}
+
+// Move ordering scores, higher values are searched first
+const (
+	pvMoveScore       int = 2000000
+	captureScore      int = 1000000
+	firstKillerScore  int = 900000
+	secondKillerScore int = 800000
+)
+
+// IsRepetition returns true if the current position occurred before since the last irreversible move
+func (board *Board) IsRepetition() bool {
+	start := board.ply - board.fiftyMove
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < board.ply-1; i++ {
+		if board.history[i].positionKey == board.positionKey {
+			return true
+		}
+	}
+	return false
+}
+
+// InCheck returns true if the side to move is in check
+func (board *Board) InCheck() bool {
+	board.UpdateBitMasks()
+	return board.kingAttacked()
+}
+
+// kingAttacked returns true if the king of the side to move is attacked. Requires up to date stateBoards
+func (board *Board) kingAttacked() bool {
+	return board.stateBoards[Unsafe]&board.bitboards[board.Side*6+WK] != 0
+}
+
+// scoreMoves assigns move ordering scores to all moves in the list. pvMove is
+// either the move from the principal variation or the best move from the hash table
+func (board *Board) scoreMoves(moveList *MoveList, info *SearchInfo, pvMove, ply int) {
+	for i := 0; i < moveList.Count; i++ {
+		move := moveList.Moves[i].Move
+
+		switch {
+		case move == pvMove:
+			moveList.Moves[i].score = pvMoveScore
+		case Captured(move) != NoPiece:
+			moveList.Moves[i].score = captureScore + MvvLvaScores[Captured(move)][board.position[FromSq(move)]]
+		case Promoted(move) != NoPiece:
+			moveList.Moves[i].score = captureScore + PieceValue[Promoted(move)]/10
+		case info.killers[0][ply] == move:
+			moveList.Moves[i].score = firstKillerScore
+		case info.killers[1][ply] == move:
+			moveList.Moves[i].score = secondKillerScore
+		default:
+			// history scores must never outrank killer moves
+			moveList.Moves[i].score = info.history[board.position[FromSq(move)]][ToSq(move)]
+			if moveList.Moves[i].score >= secondKillerScore {
+				moveList.Moves[i].score = secondKillerScore - 1
+			}
+		}
+	}
+}
+
+// pickNextMove swaps the best scored move from moveNum onwards into position moveNum
+func pickNextMove(moveList *MoveList, moveNum int) {
+	bestIdx := moveNum
+	for i := moveNum + 1; i < moveList.Count; i++ {
+		if moveList.Moves[i].score > moveList.Moves[bestIdx].score {
+			bestIdx = i
+		}
+	}
+	moveList.Moves[moveNum], moveList.Moves[bestIdx] = moveList.Moves[bestIdx], moveList.Moves[moveNum]
+}
+
+// updatePv stores move as the best move at ply followed by the principal variation of ply+1
+func (info *SearchInfo) updatePv(move, ply int) {
+	info.pvTable[ply][ply] = move
+	next := ply + 1
+	if next < MaxDepth {
+		for i := next; i < info.pvLength[next]; i++ {
+			info.pvTable[ply][i] = info.pvTable[next][i]
+		}
+		info.pvLength[ply] = info.pvLength[next]
+	} else {
+		info.pvLength[ply] = next
+	}
+}
+
+// quiescence extends the search at leaf nodes with captures and promotions only,
+// until a quiet position is reached. This avoids the horizon effect
+func (board *Board) quiescence(alpha, beta, ply int, info *SearchInfo) int {
+	info.pvLength[ply] = ply
+
+	if info.Nodes%checkUpInterval == 0 {
+		info.checkUp()
+	}
+	info.Nodes++
+
+	if ply >= MaxDepth-1 {
+		board.UpdateBitMasks()
+		return board.EvalPosition()
+	}
+
+	if result, found := board.ProbeTB(); found {
+		info.TBHits++
+		return result.Score(ply)
+	}
+
+	moveList := board.GetCaptures()
+	inCheck := board.kingAttacked()
+
+	// standing pat is not an option when in check, all evasions have to be searched
+	standPat := -Infinite + ply
+	if inCheck {
+		moveList = board.GetMoves()
+		if moveList.Count == 0 {
+			return -Infinite + ply
+		}
+	} else {
+		standPat = board.EvalPosition()
+		if standPat >= beta {
+			return beta
+		}
+
+		// delta pruning: even winning a queen (and promoting) can't raise the score to alpha
+		bigDelta := PieceValue[WQ] + DeltaMargin
+		seventhRank := RankMasks8[1]
+		if board.Side == Black {
+			seventhRank = RankMasks8[6]
+		}
+		if board.bitboards[board.Side*6+WP]&seventhRank != 0 {
+			bigDelta += PieceValue[WQ] - PieceValue[WP]
+		}
+		if standPat+bigDelta < alpha {
+			return alpha
+		}
+
+		if standPat > alpha {
+			alpha = standPat
+		}
+	}
+
+	board.scoreMoves(&moveList, info, 0, ply)
+
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		pickNextMove(&moveList, moveNum)
+		move := moveList.Moves[moveNum].Move
+
+		// delta pruning: skip captures that can't raise the score to alpha
+		if !inCheck && Promoted(move) == NoPiece && standPat+PieceValue[Captured(move)]+DeltaMargin < alpha {
+			continue
+		}
+
+		board.MakeMove(move)
+		score := -board.quiescence(-beta, -alpha, ply+1, info)
+		board.TakeMove()
+
+		if info.Stopped() {
+			return 0
+		}
+
+		if score > alpha {
+			if score >= beta {
+				return beta
+			}
+			alpha = score
+			info.updatePv(move, ply)
+		}
+	}
+
+	return alpha
+}
+
+// alphaBeta negamax search with alpha-beta pruning. Score is from the side to move's perspective
+func (board *Board) alphaBeta(alpha, beta, depth, ply int, info *SearchInfo, previousPv []int, followPv bool) int {
+	if depth <= 0 {
+		return board.quiescence(alpha, beta, ply, info)
+	}
+
+	info.pvLength[ply] = ply
+
+	if info.Nodes%checkUpInterval == 0 {
+		info.checkUp()
+	}
+	info.Nodes++
+
+	if ply > 0 && (board.IsRepetition() || board.fiftyMove >= 100 || board.IsInsufficientMaterial()) {
+		return 0
+	}
+
+	if ply >= MaxDepth-1 {
+		board.UpdateBitMasks()
+		return board.EvalPosition()
+	}
+
+	// positions in the tablebases don't have to be searched, their distance to mate is known
+	if ply > 0 {
+		if result, found := board.ProbeTB(); found {
+			info.TBHits++
+			return result.Score(ply)
+		}
+		// the Syzygy tables are probed after captures and pawn moves, the result can't change until the next one
+		if board.fiftyMove == 0 {
+			if wdl, found := board.ProbeWDL(); found {
+				info.TBHits++
+				return syzygyWDLScore(wdl, ply)
+			}
+		}
+	}
+
+	hashMove := 0
+	if entry, found := info.HashTable.Probe(board.positionKey, ply); found {
+		hashMove = int(entry.Move)
+
+		// never cut at the root, we always need a best move and its principal variation
+		if ply > 0 && int(entry.Depth) >= depth {
+			score := int(entry.Score)
+			switch int(entry.Flag) {
+			case HashExact:
+				return score
+			case HashLowerBound:
+				if score >= beta {
+					return beta
+				}
+			case HashUpperBound:
+				if score <= alpha {
+					return alpha
+				}
+			}
+		}
+	}
+
+	moveList := board.GetMoves()
+	inCheck := board.kingAttacked()
+	if ply == 0 && info.rootMoves != nil {
+		moveList = filterMoves(&moveList, info.rootMoves)
+	}
+
+	if moveList.Count == 0 {
+		if inCheck {
+			return -Infinite + ply
+		}
+		return 0 // stalemate
+	}
+
+	// extend the search when in check so we don't miss mates or evasions past the horizon
+	if inCheck {
+		depth++
+	}
+
+	// follow the principal variation of the previous iteration as long as we are on it,
+	// otherwise the best move from the hash table is tried first
+	pvMove := hashMove
+	if followPv && ply < len(previousPv) {
+		pvMove = previousPv[ply]
+	}
+	board.scoreMoves(&moveList, info, pvMove, ply)
+
+	oldAlpha := alpha
+	bestMove := 0
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		pickNextMove(&moveList, moveNum)
+		move := moveList.Moves[moveNum].Move
+
+		board.MakeMove(move)
+		score := -board.alphaBeta(-beta, -alpha, depth-1, ply+1, info, previousPv, followPv && move == pvMove)
+		board.TakeMove()
+
+		if info.Stopped() {
+			return 0
+		}
+
+		if score > alpha {
+			if score >= beta {
+				if moveNum == 0 {
+					info.FailHighFirst++
+				}
+				info.FailHigh++
+
+				if Captured(move) == NoPiece && Promoted(move) == NoPiece {
+					info.killers[1][ply] = info.killers[0][ply]
+					info.killers[0][ply] = move
+				}
+				info.HashTable.Store(board.positionKey, move, beta, depth, HashLowerBound, ply)
+				return beta
+			}
+			alpha = score
+			bestMove = move
+			info.updatePv(move, ply)
+
+			if Captured(move) == NoPiece && Promoted(move) == NoPiece {
+				info.history[board.position[FromSq(move)]][ToSq(move)] += depth * depth
+			}
+		}
+	}
+
+	if alpha != oldAlpha {
+		info.HashTable.Store(board.positionKey, bestMove, alpha, depth, HashExact, ply)
+	} else {
+		info.HashTable.Store(board.positionKey, 0, alpha, depth, HashUpperBound, ply)
+	}
+
+	return alpha
+}
+
+// Search performs an iterative deepening alpha-beta search from the current position
+// and returns the result of the last fully completed iteration
+func (board *Board) Search(info *SearchInfo) SearchResult {
+	info.clearForSearch()
+
+	board.pawnTable = info.PawnHashTable
+	defer func() { board.pawnTable = nil }()
+
+	info.rootMoves = board.tbRootMoves()
+	if info.rootMoves == nil {
+		info.rootMoves = board.syzygyRootMoves()
+	}
+
+	maxDepth := info.Depth
+	if maxDepth <= 0 || maxDepth >= MaxDepth {
+		maxDepth = MaxDepth - 1
+	}
+
+	var result SearchResult
+	for currentDepth := 1; currentDepth <= maxDepth; currentDepth++ {
+		score := board.alphaBeta(-Infinite, Infinite, currentDepth, 0, info, result.PV, true)
+
+		// results of an interrupted iteration are incomplete, only use them
+		// if not even the first iteration finished
+		if info.Stopped() && result.BestMove != 0 {
+			break
+		}
+
+		result.Depth = currentDepth
+		result.Score = score
+		result.PV = board.extendPv(info.pvTable[0][:info.pvLength[0]], info.HashTable, currentDepth)
+		if len(result.PV) > 0 {
+			result.BestMove = result.PV[0]
+		}
+		result.Nodes = info.Nodes
+		result.Time = time.Since(info.StartTime)
+		result.HashFull = info.HashTable.HashFull()
+		result.TBHits = info.TBHits
+
+		if info.Output != nil && !info.Stopped() {
+			info.Output(result)
+		}
+
+		if info.Stopped() || result.BestMove == 0 {
+			break
+		}
+		// a forced mate was found, searching deeper will not find a shorter one
+		if abs(score) > IsMate && Infinite-abs(score) <= currentDepth {
+			break
+		}
+	}
+
+	// the search was stopped before a single root move was searched
+	if result.BestMove == 0 {
+		moveList := board.GetMoves()
+		if moveList.Count > 0 {
+			result.BestMove = moveList.Moves[0].Move
+			result.PV = []int{result.BestMove}
+		}
+	}
+
+	return result
+}
+
+// tbRootMoves returns the root moves that keep the best tablebase result, i.e. win fastest, keep the draw or
+// lose slowest. Returns nil if the position (or one of its successors) is not in the tablebases
+func (board *Board) tbRootMoves() []int {
+	if _, found := board.ProbeTB(); !found {
+		return nil
+	}
+
+	moveList := board.GetMoves()
+	bestScore := -Infinite
+	var moves []int
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		move := moveList.Moves[moveNum].Move
+		board.MakeMove(move)
+		result, found := board.ProbeTB()
+		board.TakeMove()
+		if !found {
+			return nil
+		}
+
+		score := -result.Score(1)
+		if score > bestScore {
+			bestScore = score
+			moves = moves[:0]
+		}
+		if score == bestScore {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// filterMoves returns the moves of the list that are allowed
+func filterMoves(moveList *MoveList, allowed []int) (filtered MoveList) {
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		for _, move := range allowed {
+			if moveList.Moves[moveNum].Move == move {
+				filtered.AddMove(move)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// extendPv returns a copy of the principal variation. If the variation was cut short by a hash table
+// cutoff it is extended with best moves from the hash table, up to the given length
+func (board *Board) extendPv(pv []int, table *HashTable, length int) []int {
+	line := make([]int, 0, length)
+	for _, move := range pv {
+		line = append(line, move)
+		board.MakeMove(move)
+	}
+
+	for len(line) < length {
+		entry, found := table.Probe(board.positionKey, len(line))
+		if !found || entry.Move == 0 || board.IsRepetition() {
+			break
+		}
+		moveList := board.GetMoves()
+		if !moveList.contains(int(entry.Move)) {
+			break // hash collision
+		}
+		line = append(line, int(entry.Move))
+		board.MakeMove(int(entry.Move))
+	}
+
+	for range line {
+		board.TakeMove()
+	}
+	return line
+}
+
+// MateIn converts a mate score to the number of moves to mate (negative if the side to move is being mated).
+// Returns 0 for non mate scores
+func MateIn(score int) int {
+	if score > IsMate {
+		return (Infinite - score + 1) / 2
+	} else if score < -IsMate {
+		return -(Infinite + score) / 2
+	}
+	return 0
+}
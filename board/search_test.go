@@ -0,0 +1,136 @@
+package board
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchMateInOne(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	// back rank mate: Ra8#
+	board.ParseFen("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+
+	info := SearchInfo{Depth: 3}
+	result := board.Search(&info)
+
+	if GetMoveString(result.BestMove) != "a1a8" {
+		t.Errorf("Expected mate in 1 (a1a8), got %s", GetMoveString(result.BestMove))
+	}
+	if MateIn(result.Score) != 1 {
+		t.Errorf("Expected mate in 1 score, got %d (mate in %d)", result.Score, MateIn(result.Score))
+	}
+}
+
+func TestSearchMateInTwo(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	// rook ladder: 1. Ra7 Kg8 2. Rb8#
+	board.ParseFen("7k/8/8/8/8/8/R7/1R4K1 w - - 0 1")
+
+	info := SearchInfo{Depth: 5}
+	result := board.Search(&info)
+
+	if MateIn(result.Score) != 2 {
+		t.Errorf("Expected mate in 2 score, got %d (mate in %d), pv: %v", result.Score, MateIn(result.Score), result.PV)
+	}
+}
+
+func TestSearchWinsHangingQueen(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+
+	info := SearchInfo{Depth: 2}
+	result := board.Search(&info)
+
+	if GetMoveString(result.BestMove) != "d2d5" {
+		t.Errorf("Expected d2d5 capturing the queen, got %s", GetMoveString(result.BestMove))
+	}
+	if result.Depth != 2 || len(result.PV) == 0 || result.Nodes == 0 {
+		t.Errorf("Incomplete search result: %+v", result)
+	}
+}
+
+func TestSearchStalemateIsDraw(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	// black to move has no legal moves and is not in check
+	board.ParseFen("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+
+	info := SearchInfo{Depth: 3}
+	result := board.Search(&info)
+
+	if result.BestMove != 0 || result.Score != 0 {
+		t.Errorf("Expected no move and a draw score for stalemate, got %s (%d)",
+			GetMoveString(result.BestMove), result.Score)
+	}
+}
+
+func TestSearchStop(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen(StartingPosition)
+
+	info := SearchInfo{
+		TimeSet:  true,
+		StopTime: time.Now().Add(50 * time.Millisecond),
+	}
+	start := time.Now()
+	result := board.Search(&info)
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Search did not respect the time limit, took %s", time.Since(start))
+	}
+	if result.BestMove == 0 {
+		t.Errorf("Expected a best move from a stopped search")
+	}
+}
+
+func TestSearchRestoresBoard(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	board.ParseFen("r3k2r/p1pp1pb1/bn2Qnp1/2qPN3/1p2P3/2N5/PPPBBPPP/R3K2R b KQkq - 3 2")
+	originalKey := board.positionKey
+	originalMaterial := board.material
+
+	info := SearchInfo{Depth: 3}
+	board.Search(&info)
+
+	if board.positionKey != originalKey || board.material != originalMaterial {
+		t.Errorf("Board was not restored after search")
+	}
+}
+
+func TestQuiescenceAvoidsDefendedPawn(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	// d5 is defended by c6, a depth 1 search without quiescence would take it with the queen
+	board.ParseFen("4k3/8/2p5/3p4/8/8/3Q4/4K3 w - - 0 1")
+
+	info := SearchInfo{Depth: 1}
+	result := board.Search(&info)
+
+	if GetMoveString(result.BestMove) == "d2d5" {
+		t.Errorf("Search played d2d5 losing the queen for a pawn")
+	}
+	if result.Score < 0 {
+		t.Errorf("Expected a positive score for white, got %d", result.Score)
+	}
+}
+
+func TestQuiescenceSeesThreatenedPiece(t *testing.T) {
+	InitHashKeys()
+	board := Board{}
+	// the black queen attacks the undefended rook on d2. Without quiescence a depth 1
+	// search can't see the rook is lost after a random quiet move
+	board.ParseFen("4k3/8/8/q7/8/8/3R4/5K2 w - - 0 1")
+
+	info := SearchInfo{Depth: 1}
+	result := board.Search(&info)
+
+	// material is rook vs queen, the rook must be saved
+	if result.Score < -PieceValue[WQ]+PieceValue[WR]-100 {
+		t.Errorf("Expected the rook to be saved, got %s with score %d", GetMoveString(result.BestMove), result.Score)
+	}
+}
@@ -0,0 +1,935 @@
+package board
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Syzygy tablebases store the win/draw/loss result (WDL, .rtbw files) and the distance to the next
+// capture or pawn move (DTZ, .rtbz files) of every position with up to 7 pieces. The file format and
+// the indexing scheme are those of the Syzygy generator, the tables are compressed with canonical
+// Huffman codes of symbols that expand into runs of values (recursive pairing).
+
+// SyzygyMaxPieces maximum number of pieces of Syzygy tables
+const SyzygyMaxPieces int = 7
+
+// WDL results of Syzygy probes from the side to move's perspective. Cursed wins and blessed
+// losses are wins and losses that are drawn by the fifty move rule
+const (
+	WDLLoss        int = -2
+	WDLBlessedLoss int = -1
+	WDLDraw        int = 0
+	WDLCursedWin   int = 1
+	WDLWin         int = 2
+)
+
+// syzygyMagic first bytes of WDL and DTZ files
+var syzygyMagic = [2][4]byte{{0x71, 0xE8, 0x23, 0x5D}, {0xD7, 0x66, 0x0C, 0xA5}}
+
+// syzygyExtensions file extensions of WDL and DTZ tables
+var syzygyExtensions = [2]string{".rtbw", ".rtbz"}
+
+// Flags of the compressed data of a table
+const (
+	syzygyFlagSTM         uint8 = 1 // DTZ: the side to move the values are stored for
+	syzygyFlagMapped      uint8 = 2 // DTZ: values are mapped through the DTZ map
+	syzygyFlagWinPlies    uint8 = 4 // DTZ: wins are stored in plies instead of moves
+	syzygyFlagLossPlies   uint8 = 8 // DTZ: losses are stored in plies instead of moves
+	syzygyFlagWide        uint8 = 16
+	syzygyFlagSingleValue uint8 = 128 // all positions have the same value
+)
+
+// Results of probing a table
+const (
+	syzygyOK         = iota
+	syzygyFail       // the table is missing or broken
+	syzygyChangeSide // DTZ tables only store one side to move, the other side has to be probed
+	syzygyZeroing    // the best move is a capture or pawn move, the DTZ value is not stored
+)
+
+// Squares in the Syzygy tables are numbered from a1 (0) to h8 (63), the board's squares
+// start at a8. Pieces are encoded as 1 to 6 for white and 9 to 14 for black
+func syzygySquare(sq int) int { return sq ^ 56 }
+func syzygyPiece(piece int) int {
+	if piece >= BP {
+		return piece + 2
+	}
+	return piece
+}
+
+// offA1H8 returns the position of a (Syzygy) square relative to the a1-h8 diagonal: 0 on the
+// diagonal, negative below it and positive above it
+func offA1H8(sq int) int { return sq>>3 - sq&7 }
+
+// Lookup tables of the Syzygy indexing scheme
+var (
+	syzygyBinomial      [SyzygyMaxPieces][BoardSquareNum]uint64 // [k][n] ways to choose k of n
+	syzygyMapPawns      [BoardSquareNum]int                     // a2-h7 to 0..47, higher values for pawns closer to the edge
+	syzygyLeadPawnIdx   [SyzygyMaxPieces][BoardSquareNum]uint64
+	syzygyLeadPawnsSize [SyzygyMaxPieces][4]uint64
+	syzygyMapB1H1H7     [BoardSquareNum]int     // squares below the a1-h8 diagonal to 0..27
+	syzygyMapA1D1D4     [BoardSquareNum]int     // the a1-d1-d4 triangle to 0..9, the diagonal last
+	syzygyMapKK         [10][BoardSquareNum]int // the 462 legal placements of two kings
+)
+
+func init() {
+	code := 0
+	for sq := 0; sq < BoardSquareNum; sq++ {
+		if offA1H8(sq) < 0 {
+			syzygyMapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	var diagonal []int
+	code = 0
+	for sq := 0; sq <= 27; sq++ { // a1 to d4
+		if offA1H8(sq) < 0 && sq&7 <= 3 {
+			syzygyMapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 && sq&7 <= 3 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		syzygyMapA1D1D4[sq] = code
+		code++
+	}
+
+	// if the first king is on the diagonal the second one is not above it,
+	// placements with both kings on the diagonal come last
+	type placement struct{ idx, sq int }
+	var bothOnDiagonal []placement
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for sq1 := 0; sq1 <= 27; sq1++ {
+			// b1 (the first square of the triangle) is mapped to 0, like all squares outside of it
+			if syzygyMapA1D1D4[sq1] != idx || (idx == 0 && sq1 != 1) {
+				continue
+			}
+			for sq2 := 0; sq2 < BoardSquareNum; sq2++ {
+				switch {
+				case squareDistance(sq1, sq2) <= 1:
+					// illegal
+				case offA1H8(sq1) == 0 && offA1H8(sq2) > 0:
+					// symmetric to a placement below the diagonal
+				case offA1H8(sq1) == 0 && offA1H8(sq2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, placement{idx, sq2})
+				default:
+					syzygyMapKK[idx][sq2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		syzygyMapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	syzygyBinomial[0][0] = 1
+	for n := 1; n < BoardSquareNum; n++ {
+		for k := 0; k < SyzygyMaxPieces && k <= n; k++ {
+			if k > 0 {
+				syzygyBinomial[k][n] += syzygyBinomial[k-1][n-1]
+			}
+			if k < n {
+				syzygyBinomial[k][n] += syzygyBinomial[k][n-1]
+			}
+		}
+	}
+
+	// the leading pawn is the one with the highest value, closest to the edge and on the lowest rank.
+	// If it is on a square, no other pawn can be on the squares with higher values
+	availableSquares := 47
+	for leadPawns := 1; leadPawns < SyzygyMaxPieces; leadPawns++ {
+		for file := 0; file < 4; file++ {
+			// the tables are split by the file of the leading pawn, so the index restarts for every file
+			var idx uint64
+			for rank := 1; rank < 7; rank++ {
+				sq := rank*8 + file
+				if leadPawns == 1 {
+					syzygyMapPawns[sq] = availableSquares
+					availableSquares--
+					syzygyMapPawns[sq^7] = availableSquares
+					availableSquares--
+				}
+				syzygyLeadPawnIdx[leadPawns][sq] = idx
+				idx += syzygyBinomial[leadPawns-1][syzygyMapPawns[sq]]
+			}
+			syzygyLeadPawnsSize[leadPawns][file] = idx
+		}
+	}
+}
+
+// syzygyPairs compressed values of one part of a table: tables with pawns are split by the file
+// of the leading pawn and WDL tables of different material by the side to move
+type syzygyPairs struct {
+	flags    uint8
+	pieces   [SyzygyMaxPieces]int // pieces in the order of the index
+	groupLen [SyzygyMaxPieces + 1]int
+	groupIdx [SyzygyMaxPieces + 1]uint64
+
+	sizeofBlock     uint64
+	span            uint64 // number of values between two entries of the sparse index
+	sparseIndexSize uint64
+	blockLengthSize uint64
+	numBlocks       uint64
+	maxSymLen       int
+	minSymLen       int // the value itself for single value tables
+	base64          []uint64
+	symlen          []int
+
+	// offsets in the file
+	lowestSym   int
+	btree       int
+	sparseIndex int
+	blockLength int
+	data        int
+
+	mapIdx [4]int // DTZ: start of the values of each WDL result in the DTZ map
+}
+
+// syzygyTable WDL or DTZ table of a material signature. The file is mapped when it is probed the first time
+type syzygyTable struct {
+	code     string // e.g. KRPvKR, white's pieces first
+	dtz      bool
+	filename string
+
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // pawns of the leading colour (the one with fewer pawns) and of the other colour
+	symmetric       bool   // both sides have the same pieces
+
+	once   sync.Once
+	data   []byte
+	err    error
+	pairs  [2][4]syzygyPairs // [side][file]
+	dtzMap int
+}
+
+// syzygyTables tables used by the Syzygy probes, indexed by the material code of both colour assignments
+var syzygyTables = struct {
+	sync.RWMutex
+	tables    [2]map[string]*syzygyTable // WDL and DTZ tables
+	maxPieces int32                      // accessed atomically
+}{tables: [2]map[string]*syzygyTable{{}, {}}}
+
+// LoadSyzygy finds the Syzygy tables in the directories of a path list (separated like the PATH
+// environment variable). The files are only read when they are probed. Returns the number of WDL tables
+func LoadSyzygy(path string) (int, error) {
+	ClearSyzygy()
+
+	tableNum := 0
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return tableNum, fmt.Errorf("Invalid Syzygy directory %s", dir)
+		}
+		for kind, extension := range syzygyExtensions {
+			filenames, err := filepath.Glob(filepath.Join(dir, "*"+extension))
+			if err != nil {
+				return tableNum, err
+			}
+			for _, filename := range filenames {
+				code := strings.TrimSuffix(filepath.Base(filename), extension)
+				table, err := newSyzygyTable(code, kind == 1, filename)
+				if err != nil {
+					continue // not a table
+				}
+				if addSyzygyTable(table) && kind == 0 {
+					tableNum++
+				}
+			}
+		}
+	}
+	return tableNum, nil
+}
+
+// ClearSyzygy removes all Syzygy tables
+func ClearSyzygy() {
+	syzygyTables.Lock()
+	defer syzygyTables.Unlock()
+	for _, table := range syzygyTables.tables[0] {
+		table.close()
+	}
+	for _, table := range syzygyTables.tables[1] {
+		table.close()
+	}
+	syzygyTables.tables = [2]map[string]*syzygyTable{{}, {}}
+	atomic.StoreInt32(&syzygyTables.maxPieces, 0)
+}
+
+// SyzygyMaxPiecesLoaded returns the number of pieces of the largest WDL table that was found
+func SyzygyMaxPiecesLoaded() int {
+	return int(atomic.LoadInt32(&syzygyTables.maxPieces))
+}
+
+// addSyzygyTable registers a table unless a table of the same material was found before
+func addSyzygyTable(table *syzygyTable) bool {
+	kind := 0
+	if table.dtz {
+		kind = 1
+	}
+	syzygyTables.Lock()
+	defer syzygyTables.Unlock()
+
+	tables := syzygyTables.tables[kind]
+	if _, found := tables[table.code]; found {
+		return false
+	}
+	tables[table.code] = table
+	tables[swapSyzygyCode(table.code)] = table
+	if pieceNum := int32(table.pieceCount); kind == 0 && pieceNum > atomic.LoadInt32(&syzygyTables.maxPieces) {
+		atomic.StoreInt32(&syzygyTables.maxPieces, pieceNum)
+	}
+	return true
+}
+
+// swapSyzygyCode returns the code with the colours swapped
+func swapSyzygyCode(code string) string {
+	sides := strings.SplitN(code, "v", 2)
+	return sides[1] + "v" + sides[0]
+}
+
+// syzygyCode returns the material code of piece counts, e.g. KRPvKR
+func syzygyCode(counts [13]int) string {
+	var code strings.Builder
+	for side := White; side <= Black; side++ {
+		if side == Black {
+			code.WriteByte('v')
+		}
+		for _, pieceType := range []int{WK, WQ, WR, WB, WN, WP} {
+			for n := 0; n < counts[side*6+pieceType]; n++ {
+				code.WriteByte(PieceChar[pieceType])
+			}
+		}
+	}
+	return code.String()
+}
+
+// newSyzygyTable creates a table from the material code in its file name
+func newSyzygyTable(code string, dtz bool, filename string) (*syzygyTable, error) {
+	sides := strings.Split(code, "v")
+	if len(sides) != 2 {
+		return nil, fmt.Errorf("Invalid Syzygy table name %q", code)
+	}
+
+	var counts [13]int
+	for side, pieces := range sides {
+		for _, char := range pieces {
+			pieceType := strings.IndexRune(PieceChar[:WK+1], char)
+			if pieceType <= 0 {
+				return nil, fmt.Errorf("Invalid Syzygy table name %q", code)
+			}
+			counts[side*6+pieceType]++
+		}
+	}
+	if counts[WK] != 1 || counts[BK] != 1 || len(code)-1 > SyzygyMaxPieces || syzygyCode(counts) != code {
+		return nil, fmt.Errorf("Invalid Syzygy table name %q", code)
+	}
+
+	table := &syzygyTable{code: code, dtz: dtz, filename: filename, pieceCount: len(code) - 1}
+	for piece := WP; piece <= BK; piece++ {
+		if piece != WK && piece != BK && counts[piece] == 1 {
+			table.hasUniquePieces = true
+		}
+	}
+	table.hasPawns = counts[WP]+counts[BP] > 0
+	table.symmetric = code == swapSyzygyCode(code)
+
+	// the leading colour is the one with fewer pawns (but at least one), which compresses better
+	whiteLeads := counts[BP] == 0 || (counts[WP] > 0 && counts[BP] >= counts[WP])
+	table.pawnCount = [2]int{counts[WP], counts[BP]}
+	if !whiteLeads {
+		table.pawnCount = [2]int{counts[BP], counts[WP]}
+	}
+	return table, nil
+}
+
+// mapped returns true if the table's file could be mapped and its header was read
+func (table *syzygyTable) mapped() bool {
+	table.once.Do(func() {
+		data, err := mapFile(table.filename)
+		if err == nil {
+			err = table.init(data)
+		}
+		if err != nil {
+			unmapFile(data)
+			table.err = err
+			return
+		}
+		table.data = data
+	})
+	return table.err == nil
+}
+
+// close unmaps the table's file
+func (table *syzygyTable) close() {
+	if table.data != nil {
+		unmapFile(table.data)
+		table.data = nil
+	}
+}
+
+// sides returns the number of sides to move the table stores values for
+func (table *syzygyTable) sides() int {
+	if table.dtz || table.symmetric {
+		return 1
+	}
+	return 2
+}
+
+// get returns the compressed values of a side to move and a file of the leading pawn
+func (table *syzygyTable) get(side, file int) *syzygyPairs {
+	if !table.hasPawns {
+		file = 0
+	}
+	return &table.pairs[side%table.sides()][file]
+}
+
+// init reads the header of a table file
+func (table *syzygyTable) init(data []byte) (err error) {
+	// the offsets are read from the file, broken files must not crash the engine
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%s: invalid Syzygy file", table.filename)
+		}
+	}()
+
+	kind := 0
+	if table.dtz {
+		kind = 1
+	}
+	if len(data) < 5 || [4]byte{data[0], data[1], data[2], data[3]} != syzygyMagic[kind] {
+		return fmt.Errorf("%s: not a Syzygy file", table.filename)
+	}
+	const split, hasPawns = 1, 2
+	if (data[4]&hasPawns != 0) != table.hasPawns || (!table.dtz && (data[4]&split != 0) == table.symmetric) {
+		return fmt.Errorf("%s: the file doesn't match the material of its name", table.filename)
+	}
+
+	offset := 5
+	sides := table.sides()
+	maxFile := 0
+	if table.hasPawns {
+		maxFile = 3
+	}
+	bothPawns := table.hasPawns && table.pawnCount[1] > 0
+
+	for file := 0; file <= maxFile; file++ {
+		order := [2][2]int{{int(data[offset] & 0xF), 0xF}, {int(data[offset] >> 4), 0xF}}
+		if bothPawns {
+			order[0][1], order[1][1] = int(data[offset+1]&0xF), int(data[offset+1]>>4)
+		}
+		offset++
+		if bothPawns {
+			offset++
+		}
+
+		for k := 0; k < table.pieceCount; k++ {
+			for side := 0; side < sides; side++ {
+				piece := data[offset] & 0xF
+				if side == 1 {
+					piece = data[offset] >> 4
+				}
+				table.pairs[side][file].pieces[k] = int(piece)
+			}
+			offset++
+		}
+		for side := 0; side < sides; side++ {
+			table.setGroups(&table.pairs[side][file], order[side], file)
+		}
+	}
+	offset += offset & 1
+
+	for file := 0; file <= maxFile; file++ {
+		for side := 0; side < sides; side++ {
+			offset = table.pairs[side][file].setSizes(data, offset)
+		}
+	}
+
+	if table.dtz {
+		table.dtzMap = offset
+		for file := 0; file <= maxFile; file++ {
+			pairs := &table.pairs[0][file]
+			if pairs.flags&syzygyFlagMapped == 0 {
+				continue
+			}
+			if pairs.flags&syzygyFlagWide != 0 {
+				offset += offset & 1
+				for i := 0; i < 4; i++ {
+					pairs.mapIdx[i] = (offset-table.dtzMap)/2 + 1
+					offset += 2*int(binary.LittleEndian.Uint16(data[offset:])) + 2
+				}
+			} else {
+				for i := 0; i < 4; i++ {
+					pairs.mapIdx[i] = offset - table.dtzMap + 1
+					offset += int(data[offset]) + 1
+				}
+			}
+		}
+		offset += offset & 1
+	}
+
+	for file := 0; file <= maxFile; file++ {
+		for side := 0; side < sides; side++ {
+			pairs := &table.pairs[side][file]
+			pairs.sparseIndex = offset
+			offset += int(pairs.sparseIndexSize) * 6
+		}
+	}
+	for file := 0; file <= maxFile; file++ {
+		for side := 0; side < sides; side++ {
+			pairs := &table.pairs[side][file]
+			pairs.blockLength = offset
+			offset += int(pairs.blockLengthSize) * 2
+		}
+	}
+	for file := 0; file <= maxFile; file++ {
+		for side := 0; side < sides; side++ {
+			pairs := &table.pairs[side][file]
+			offset = (offset + 0x3F) &^ 0x3F
+			pairs.data = offset
+			offset += int(pairs.numBlocks * pairs.sizeofBlock)
+		}
+	}
+	if offset > len(data) {
+		return fmt.Errorf("%s: truncated Syzygy file", table.filename)
+	}
+	return nil
+}
+
+// setGroups splits the pieces into the groups of the index. Pieces of the same kind form a group,
+// except for the leading group: the leading pawns or the first 2 or 3 pieces (kings and a unique
+// piece). The order of the groups in the index is stored per table
+func (table *syzygyTable) setGroups(pairs *syzygyPairs, order [2]int, file int) {
+	n := 0
+	firstLen := 2
+	if table.hasPawns {
+		firstLen = 0
+	} else if table.hasUniquePieces {
+		firstLen = 3
+	}
+	pairs.groupLen[n] = 1
+	for i := 1; i < table.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || pairs.pieces[i] == pairs.pieces[i-1] {
+			pairs.groupLen[n]++
+		} else {
+			n++
+			pairs.groupLen[n] = 1
+		}
+	}
+	n++
+	pairs.groupLen[n] = 0
+
+	bothPawns := table.hasPawns && table.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - pairs.groupLen[0]
+	if bothPawns {
+		next = 2
+		freeSquares -= pairs.groupLen[1]
+	}
+
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]: // the leading group
+			pairs.groupIdx[0] = idx
+			switch {
+			case table.hasPawns:
+				idx *= syzygyLeadPawnsSize[pairs.groupLen[0]][file]
+			case table.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]: // the pawns of the other colour
+			pairs.groupIdx[1] = idx
+			idx *= syzygyBinomial[pairs.groupLen[1]][48-pairs.groupLen[0]]
+		default: // the other pieces
+			pairs.groupIdx[next] = idx
+			idx *= syzygyBinomial[pairs.groupLen[next]][freeSquares]
+			freeSquares -= pairs.groupLen[next]
+			next++
+		}
+	}
+	pairs.groupIdx[n] = idx
+}
+
+// setSizes reads the sizes and the Huffman code of the compressed values. Returns the offset after them
+func (pairs *syzygyPairs) setSizes(data []byte, offset int) int {
+	pairs.flags = data[offset]
+	offset++
+	if pairs.flags&syzygyFlagSingleValue != 0 {
+		pairs.minSymLen = int(data[offset])
+		return offset + 1
+	}
+
+	groups := 0
+	for pairs.groupLen[groups] != 0 {
+		groups++
+	}
+	tableSize := pairs.groupIdx[groups]
+
+	pairs.sizeofBlock = 1 << data[offset]
+	pairs.span = 1 << data[offset+1]
+	pairs.sparseIndexSize = (tableSize + pairs.span - 1) / pairs.span
+	padding := uint64(data[offset+2])
+	pairs.numBlocks = uint64(binary.LittleEndian.Uint32(data[offset+3:]))
+	pairs.blockLengthSize = pairs.numBlocks + padding
+	pairs.maxSymLen = int(data[offset+7])
+	pairs.minSymLen = int(data[offset+8])
+	offset += 9
+	pairs.lowestSym = offset
+
+	// symbols with longer codes have lower values, base64[i] is the lowest code of length
+	// minSymLen+i left aligned in 64 bits
+	pairs.base64 = make([]uint64, pairs.maxSymLen-pairs.minSymLen+1)
+	for i := len(pairs.base64) - 2; i >= 0; i-- {
+		pairs.base64[i] = (pairs.base64[i+1] + uint64(pairs.lowest(data, i)) - uint64(pairs.lowest(data, i+1))) / 2
+	}
+	for i := range pairs.base64 {
+		pairs.base64[i] <<= uint(64 - i - pairs.minSymLen)
+	}
+	offset += len(pairs.base64) * 2
+
+	pairs.symlen = make([]int, binary.LittleEndian.Uint16(data[offset:]))
+	offset += 2
+	pairs.btree = offset
+
+	visited := make([]bool, len(pairs.symlen))
+	for sym := range pairs.symlen {
+		if !visited[sym] {
+			pairs.symlen[sym] = pairs.setSymlen(data, sym, visited)
+		}
+	}
+	return offset + len(pairs.symlen)*3 + len(pairs.symlen)&1
+}
+
+// lowest returns the lowest symbol with a code of length minSymLen+i
+func (pairs *syzygyPairs) lowest(data []byte, i int) uint16 {
+	return binary.LittleEndian.Uint16(data[pairs.lowestSym+2*i:])
+}
+
+// left and right return the symbols a symbol expands into. Symbols that don't expand store their value in left
+func (pairs *syzygyPairs) left(data []byte, sym int) int {
+	lr := data[pairs.btree+3*sym:]
+	return int(lr[1]&0xF)<<8 | int(lr[0])
+}
+
+func (pairs *syzygyPairs) right(data []byte, sym int) int {
+	lr := data[pairs.btree+3*sym:]
+	return int(lr[2])<<4 | int(lr[1]>>4)
+}
+
+// setSymlen returns the number of values a symbol expands into minus 1
+func (pairs *syzygyPairs) setSymlen(data []byte, sym int, visited []bool) int {
+	visited[sym] = true
+	right := pairs.right(data, sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := pairs.left(data, sym)
+	if !visited[left] {
+		pairs.symlen[left] = pairs.setSymlen(data, left, visited)
+	}
+	if !visited[right] {
+		pairs.symlen[right] = pairs.setSymlen(data, right, visited)
+	}
+	return pairs.symlen[left] + pairs.symlen[right] + 1
+}
+
+// decompress returns the value at an index
+func (pairs *syzygyPairs) decompress(data []byte, idx uint64) int {
+	if pairs.flags&syzygyFlagSingleValue != 0 {
+		return pairs.minSymLen
+	}
+
+	// the sparse index stores the block and the offset in it of every span-th value (from the middle of the span)
+	k := idx / pairs.span
+	entry := data[pairs.sparseIndex+6*int(k):]
+	block := int(binary.LittleEndian.Uint32(entry))
+	offset := int(binary.LittleEndian.Uint16(entry[4:]))
+	offset += int(idx%pairs.span) - int(pairs.span/2)
+
+	blockLength := func(block int) int {
+		return int(binary.LittleEndian.Uint16(data[pairs.blockLength+2*block:]))
+	}
+	for offset < 0 {
+		block--
+		offset += blockLength(block) + 1
+	}
+	for offset > blockLength(block) {
+		offset -= blockLength(block) + 1
+		block++
+	}
+
+	// the last block may end before the buffer is filled, bytes after the end of the file read as 0
+	read := func(ptr int) uint64 {
+		var buffer [4]byte
+		if ptr < len(data) {
+			copy(buffer[:], data[ptr:])
+		}
+		return uint64(binary.BigEndian.Uint32(buffer[:]))
+	}
+	ptr := pairs.data + block*int(pairs.sizeofBlock)
+	buffer := read(ptr)<<32 | read(ptr+4)
+	ptr += 8
+	bufferSize := 64
+
+	var sym int
+	for {
+		length := 0
+		for buffer < pairs.base64[length] {
+			length++
+		}
+		sym = int((buffer-pairs.base64[length])>>uint(64-length-pairs.minSymLen)) + int(pairs.lowest(data, length))
+		if offset < pairs.symlen[sym]+1 {
+			break
+		}
+
+		offset -= pairs.symlen[sym] + 1
+		length += pairs.minSymLen
+		buffer <<= uint(length)
+		bufferSize -= length
+		if bufferSize <= 32 {
+			bufferSize += 32
+			buffer |= read(ptr) << uint(64-bufferSize)
+			ptr += 4
+		}
+	}
+
+	// the symbol expands into symlen+1 values, find the one at the offset
+	for pairs.symlen[sym] != 0 {
+		left := pairs.left(data, sym)
+		if offset < pairs.symlen[left]+1 {
+			sym = left
+		} else {
+			offset -= pairs.symlen[left] + 1
+			sym = pairs.right(data, sym)
+		}
+	}
+	return pairs.left(data, sym)
+}
+
+// mapScore converts a decompressed value: WDL values are stored from 0 (loss) to 4 (win),
+// DTZ values are mapped and converted to plies
+func (table *syzygyTable) mapScore(pairs *syzygyPairs, value, wdl int) int {
+	if !table.dtz {
+		return value - 2
+	}
+
+	if pairs.flags&syzygyFlagMapped != 0 {
+		mapIdx := pairs.mapIdx[[5]int{1, 3, 0, 2, 0}[wdl+2]]
+		if pairs.flags&syzygyFlagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(table.data[table.dtzMap+2*(mapIdx+value):]))
+		} else {
+			value = int(table.data[table.dtzMap+mapIdx+value])
+		}
+	}
+
+	if (wdl == WDLWin && pairs.flags&syzygyFlagWinPlies == 0) ||
+		(wdl == WDLLoss && pairs.flags&syzygyFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+// probe returns the value of the position from the table: the WDL result or the DTZ for the given WDL result
+func (table *syzygyTable) probe(board *Board, code string, wdl int) (value, state int) {
+	if !table.mapped() {
+		return 0, syzygyFail
+	}
+	pairs, idx, state := table.encode(board, code)
+	if state != syzygyOK {
+		return 0, state
+	}
+	return table.mapScore(pairs, pairs.decompress(table.data, idx), wdl), syzygyOK
+}
+
+// encode returns the part of the table and the index of the position. The pieces and groups of the table
+// have to be read from its file
+func (table *syzygyTable) encode(board *Board, code string) (*syzygyPairs, uint64, int) {
+	// the tables only store positions where the pieces of the first part of the code are white, and
+	// symmetric tables only positions with white to move. Otherwise the colours are swapped
+	flip := code != table.code || (table.symmetric && board.Side == Black)
+	flipColour, flipSquares, side := 0, 0, board.Side
+	if flip {
+		flipColour, flipSquares, side = 8, 56, board.Side^1
+	}
+
+	var squares, pieces [SyzygyMaxPieces]int
+	size, leadPawnNum, file := 0, 0, 0
+	var leadPawns uint64
+
+	if table.hasPawns {
+		// the leading pawns are the first pieces of all parts of the table
+		piece := table.pairs[0][0].pieces[0] ^ flipColour
+		leadPawns = board.bitboards[WP]
+		if piece>>3 == 1 {
+			leadPawns = board.bitboards[BP]
+		}
+		for bitboard := leadPawns; bitboard != 0; bitboard &= bitboard - 1 {
+			squares[size] = syzygySquare(bits.TrailingZeros64(bitboard)) ^ flipSquares
+			size++
+		}
+		leadPawnNum = size
+
+		// the leading pawn is the one closest to the edge
+		lead := 0
+		for i := 1; i < leadPawnNum; i++ {
+			if syzygyMapPawns[squares[i]] > syzygyMapPawns[squares[lead]] {
+				lead = i
+			}
+		}
+		squares[0], squares[lead] = squares[lead], squares[0]
+
+		file = squares[0] & 7
+		if file > 3 {
+			file = (squares[0] ^ 7) & 7
+		}
+	}
+
+	// DTZ tables only store one side to move
+	if table.dtz {
+		if table.get(side, file).flags&syzygyFlagSTM != uint8(side) && (!table.symmetric || table.hasPawns) {
+			return nil, 0, syzygyChangeSide
+		}
+	}
+
+	for piece := WP; piece <= BK; piece++ {
+		bitboard := board.bitboards[piece]
+		if piece == WP || piece == BP {
+			bitboard &^= leadPawns
+		}
+		for ; bitboard != 0; bitboard &= bitboard - 1 {
+			squares[size] = syzygySquare(bits.TrailingZeros64(bitboard)) ^ flipSquares
+			pieces[size] = syzygyPiece(piece) ^ flipColour
+			size++
+		}
+	}
+
+	pairs := table.get(side, file)
+
+	// order the pieces like the table
+	for i := leadPawnNum; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if pairs.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// the leading piece is on the files a to d
+	if squares[0]&7 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if table.hasPawns {
+		idx = syzygyLeadPawnIdx[leadPawnNum][squares[0]]
+		others := squares[1:leadPawnNum]
+		sort.SliceStable(others, func(i, j int) bool {
+			return syzygyMapPawns[others[i]] < syzygyMapPawns[others[j]]
+		})
+		for i := 1; i < leadPawnNum; i++ {
+			idx += syzygyBinomial[i][syzygyMapPawns[squares[i]]]
+		}
+	} else {
+		idx = pairs.encodeLeadingPieces(&squares, size, table.hasUniquePieces)
+	}
+
+	idx *= pairs.groupIdx[0]
+	groupStart := pairs.groupLen[0]
+	remainingPawns := table.hasPawns && table.pawnCount[1] > 0
+	for next := 1; pairs.groupLen[next] != 0; next++ {
+		group := squares[groupStart : groupStart+pairs.groupLen[next]]
+		sort.Ints(group)
+
+		// squares after the squares of the previous groups are moved down
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, previous := range squares[:groupStart] {
+				if sq > previous {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += syzygyBinomial[i+1][sq-adjust]
+		}
+		remainingPawns = false
+		idx += n * pairs.groupIdx[next]
+		groupStart += pairs.groupLen[next]
+	}
+
+	return pairs, idx, syzygyOK
+}
+
+// encodeLeadingPieces returns the index of the leading group of pawnless tables: the kings and for
+// tables with unique pieces one more piece. The squares are mirrored so the first piece is in the a1-d1-d4
+// triangle and the first piece that is not on the a1-h8 diagonal is below it
+func (pairs *syzygyPairs) encodeLeadingPieces(squares *[SyzygyMaxPieces]int, size int, unique bool) uint64 {
+	if squares[0]>>3 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 56
+		}
+	}
+	for i := 0; i < pairs.groupLen[0]; i++ {
+		if offA1H8(squares[i]) == 0 {
+			continue
+		}
+		if offA1H8(squares[i]) > 0 {
+			for j := i; j < size; j++ {
+				squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+			}
+		}
+		break
+	}
+
+	if !unique {
+		return uint64(syzygyMapKK[syzygyMapA1D1D4[squares[0]]][squares[1]])
+	}
+
+	s0, s1, s2 := squares[0], squares[1], squares[2]
+	adjust1, adjust2 := 0, 0
+	if s1 > s0 {
+		adjust1++
+	}
+	if s2 > s0 {
+		adjust2++
+	}
+	if s2 > s1 {
+		adjust2++
+	}
+	rank := func(sq int) int { return sq >> 3 }
+
+	switch {
+	case offA1H8(s0) != 0:
+		return uint64((syzygyMapA1D1D4[s0]*63+s1-adjust1)*62 + s2 - adjust2)
+	case offA1H8(s1) != 0:
+		return uint64((6*63+rank(s0)*28+syzygyMapB1H1H7[s1])*62 + s2 - adjust2)
+	case offA1H8(s2) != 0:
+		return uint64(6*63*62 + 4*28*62 + rank(s0)*7*28 + (rank(s1)-adjust1)*28 + syzygyMapB1H1H7[s2])
+	}
+	return uint64(6*63*62 + 4*28*62 + 4*7*28 + rank(s0)*7*6 + (rank(s1)-adjust1)*6 + rank(s2) - adjust2)
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package board
+
+import "io/ioutil"
+
+// mapFile reads a file into memory, memory mapping is only used on unix systems
+func mapFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
+
+// unmapFile releases the memory of a file
+func unmapFile(data []byte) {}
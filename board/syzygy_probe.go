@@ -0,0 +1,312 @@
+package board
+
+import "math/bits"
+
+// SyzygyWin score of positions the Syzygy tables report as won. Wins are scored below the mate scores,
+// the tables only know the distance to the next capture or pawn move, not to mate
+const SyzygyWin int = IsMate - MaxDepth
+
+// syzygyWDLScore returns the search score of a WDL result. Cursed wins and blessed losses are drawn
+// by the fifty move rule
+func syzygyWDLScore(wdl, ply int) int {
+	switch wdl {
+	case WDLWin:
+		return SyzygyWin - ply
+	case WDLLoss:
+		return -SyzygyWin + ply
+	}
+	return 0
+}
+
+// pieceCount returns the number of pieces on the board, kings included
+func (board *Board) pieceCount() int {
+	count := 0
+	for piece := WP; piece <= BK; piece++ {
+		count += bits.OnesCount64(board.bitboards[piece])
+	}
+	return count
+}
+
+// inSyzygyTables returns true if the position can be in the loaded Syzygy tables. The tables
+// don't store positions with castling rights
+func (board *Board) inSyzygyTables() bool {
+	return board.castlePermissions == 0 && board.pieceCount() <= SyzygyMaxPiecesLoaded()
+}
+
+// isZeroing returns true if the move is a capture or a pawn move, which resets the fifty move counter
+func (board *Board) isZeroing(move int) bool {
+	piece := board.position[FromSq(move)]
+	return Captured(move) != 0 || piece == WP || piece == BP
+}
+
+// probeSyzygyTable probes the WDL or DTZ table of the position's material
+func (board *Board) probeSyzygyTable(dtz bool, wdl int) (value, state int) {
+	var counts [13]int
+	for piece := WP; piece <= BK; piece++ {
+		counts[piece] = bits.OnesCount64(board.bitboards[piece])
+	}
+	code := syzygyCode(counts)
+	if code == "KvK" {
+		return 0, syzygyOK
+	}
+
+	kind := 0
+	if dtz {
+		kind = 1
+	}
+	syzygyTables.RLock()
+	table, found := syzygyTables.tables[kind][code]
+	syzygyTables.RUnlock()
+	if !found {
+		return 0, syzygyFail
+	}
+	return table.probe(board, code, wdl)
+}
+
+// searchSyzygy returns the WDL result of the position. The tables store arbitrary values for positions
+// where a capture is best (and for positions with an en passant square), so the captures (and with
+// zeroingMoves the pawn moves too) are searched first. The state is syzygyZeroing if the best move is one of them
+func (board *Board) searchSyzygy(zeroingMoves bool) (wdl, state int) {
+	moveList := board.GetMoves()
+	bestWDL := WDLLoss
+	searched := 0
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		move := moveList.Moves[moveNum].Move
+		if Captured(move) == 0 && (!zeroingMoves || !board.isZeroing(move)) {
+			continue
+		}
+		searched++
+
+		board.MakeMove(move)
+		value, state := board.searchSyzygy(false)
+		board.TakeMove()
+		if state == syzygyFail {
+			return WDLDraw, syzygyFail
+		}
+
+		if -value > bestWDL {
+			bestWDL = -value
+			if bestWDL >= WDLWin {
+				return bestWDL, syzygyZeroing
+			}
+		}
+	}
+
+	// if all moves were searched the stored value isn't needed (and might be wrong)
+	allSearched := searched > 0 && searched == moveList.Count
+	wdl = bestWDL
+	if !allSearched {
+		var state int
+		if wdl, state = board.probeSyzygyTable(false, 0); state == syzygyFail {
+			return WDLDraw, syzygyFail
+		}
+	}
+
+	// DTZ tables store arbitrary values if a zeroing move is at least as good as the stored result
+	if bestWDL >= wdl {
+		if bestWDL > WDLDraw || allSearched {
+			return bestWDL, syzygyZeroing
+		}
+		return bestWDL, syzygyOK
+	}
+	return wdl, syzygyOK
+}
+
+// ProbeWDL returns the win/draw/loss result of the position from the Syzygy tables
+func (board *Board) ProbeWDL() (wdl int, found bool) {
+	if !board.inSyzygyTables() {
+		return WDLDraw, false
+	}
+	wdl, state := board.searchSyzygy(false)
+	return wdl, state != syzygyFail
+}
+
+// dtzBeforeZeroing returns the DTZ of a position whose best move is a capture or pawn move
+func dtzBeforeZeroing(wdl int) int {
+	switch wdl {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+// signOf returns -1, 0 or 1
+func signOf(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+// ProbeDTZ returns the distance in plies to the next capture or pawn move that keeps the result of
+// the position: positive if the side to move wins, negative if it loses and 0 if the position is drawn.
+// The distance of cursed wins and blessed losses is increased by 100. The distance can be off by one ply
+func (board *Board) ProbeDTZ() (dtz int, found bool) {
+	if !board.inSyzygyTables() {
+		return 0, false
+	}
+	dtz, state := board.probeDTZ()
+	return dtz, state != syzygyFail
+}
+
+func (board *Board) probeDTZ() (int, int) {
+	wdl, state := board.searchSyzygy(true)
+	if state == syzygyFail || wdl == WDLDraw {
+		return 0, state
+	}
+	if state == syzygyZeroing {
+		return dtzBeforeZeroing(wdl), syzygyOK
+	}
+
+	dtz, state := board.probeSyzygyTable(true, wdl)
+	if state == syzygyFail {
+		return 0, state
+	}
+	if state != syzygyChangeSide {
+		if wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+			dtz += 100
+		}
+		return dtz * signOf(wdl), syzygyOK
+	}
+
+	// the table stores the other side to move, search one ply
+	minDTZ := 0xFFFF
+	moveList := board.GetMoves()
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		move := moveList.Moves[moveNum].Move
+		zeroing := board.isZeroing(move)
+
+		board.MakeMove(move)
+		if zeroing {
+			var childWDL int
+			childWDL, state = board.searchSyzygy(false)
+			dtz = -dtzBeforeZeroing(childWDL)
+		} else {
+			dtz, state = board.probeDTZ()
+			dtz = -dtz
+		}
+		// a mate is the fastest way to win
+		if dtz == 1 && board.InCheck() && board.GetMoves().Count == 0 {
+			minDTZ = 1
+		}
+		board.TakeMove()
+		if state == syzygyFail {
+			return 0, state
+		}
+
+		if !zeroing {
+			dtz += signOf(dtz)
+		}
+		if dtz < minDTZ && signOf(dtz) == signOf(wdl) {
+			minDTZ = dtz
+		}
+	}
+	if minDTZ == 0xFFFF {
+		return -1, syzygyOK // all moves lose, i.e. the position is mate
+	}
+	return minDTZ, syzygyOK
+}
+
+// hasRepeated returns true if a position repeated since the last capture or pawn move
+func (board *Board) hasRepeated() bool {
+	start := board.ply - board.fiftyMove
+	if start < 0 {
+		start = 0
+	}
+	for i := board.ply - 1; i > start; i-- {
+		for j := i - 2; j >= start; j -= 2 {
+			if board.history[i].positionKey == board.history[j].positionKey {
+				return true
+			}
+		}
+	}
+	return board.IsRepetition()
+}
+
+// syzygyRootMoves returns the root moves that keep the Syzygy result with the fifty move rule: winning moves
+// that win within the remaining moves (the fastest one after a repetition), all moves if the loss is far enough
+// from the fifty move rule (otherwise the slowest ones) and the moves that keep the draw.
+// Returns nil if the position is not in the tables
+func (board *Board) syzygyRootMoves() []int {
+	if !board.inSyzygyTables() {
+		return nil
+	}
+	dtz, state := board.probeDTZ()
+	if state == syzygyFail {
+		return nil
+	}
+
+	moveList := board.GetMoves()
+	scores := make([]int, moveList.Count)
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		move := moveList.Moves[moveNum].Move
+		board.MakeMove(move)
+
+		score := 0
+		if dtz > 0 && board.InCheck() && board.GetMoves().Count == 0 {
+			score = 1 // mate
+		} else if board.fiftyMove != 0 {
+			score, state = board.probeDTZ()
+			score = -score
+			score += signOf(score)
+		} else {
+			var wdl int
+			wdl, state = board.searchSyzygy(false)
+			score = dtzBeforeZeroing(-wdl)
+		}
+		board.TakeMove()
+		if state == syzygyFail {
+			return nil
+		}
+		scores[moveNum] = score
+	}
+
+	var moves []int
+	keep := func(keep func(score int) bool) {
+		for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+			if keep(scores[moveNum]) {
+				moves = append(moves, moveList.Moves[moveNum].Move)
+			}
+		}
+	}
+	switch {
+	case dtz > 0:
+		best := 0xFFFF
+		for _, score := range scores {
+			if score > 0 && score < best {
+				best = score
+			}
+		}
+		// without repetitions any move that wins within the fifty move rule is fine
+		limit := best
+		if !board.hasRepeated() && best+board.fiftyMove <= 99 {
+			limit = 99 - board.fiftyMove
+		}
+		keep(func(score int) bool { return score > 0 && score <= limit })
+	case dtz < 0:
+		best := 0
+		for _, score := range scores {
+			if score < best {
+				best = score
+			}
+		}
+		// if the opponent zeroes within the fifty move rule anyway every losing move is fine
+		if -best+board.fiftyMove <= 100 {
+			keep(func(score int) bool { return true })
+		} else {
+			keep(func(score int) bool { return score == best })
+		}
+	default:
+		keep(func(score int) bool { return score == 0 })
+	}
+	return moves
+}
@@ -0,0 +1,904 @@
+package board
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSyzygyIndexTables(t *testing.T) {
+	max := 0
+	for idx := range syzygyMapKK {
+		for _, code := range syzygyMapKK[idx] {
+			if code > max {
+				max = code
+			}
+		}
+	}
+	if max != 461 {
+		t.Errorf("Expected 462 king placements, got %d", max+1)
+	}
+	square := func(name string) int {
+		sq, _ := parseSquare(name)
+		return syzygySquare(sq)
+	}
+	if syzygyMapA1D1D4[square("d4")] != 9 || syzygyMapB1H1H7[square("h7")] != 27 {
+		t.Errorf("The triangle and the lower half of the board are mapped incorrectly")
+	}
+	if syzygyBinomial[2][5] != 10 || syzygyBinomial[5][63] != 7028847 {
+		t.Errorf("Expected binomial coefficients 10 and 7028847, got %d and %d", syzygyBinomial[2][5], syzygyBinomial[5][63])
+	}
+	if syzygyMapPawns[square("a2")] != 47 || syzygyLeadPawnsSize[1][0] != 6 {
+		t.Errorf("Expected the leading pawn on a2 first and 6 squares on a file")
+	}
+
+	var counts [13]int
+	counts[WK], counts[BK], counts[WR], counts[WP], counts[BR] = 1, 1, 1, 1, 1
+	if code := syzygyCode(counts); code != "KRPvKR" {
+		t.Errorf("Expected KRPvKR, got %s", code)
+	}
+	for _, invalid := range []string{"KRK", "RvK", "KXvK", "KPRvK", "KQRBNPPvK"} {
+		if _, err := newSyzygyTable(invalid, false, ""); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+// symmetries returns the 8 symmetric squares of a pawnless position
+func symmetries(squares []int) (transformed [8][]int) {
+	for symmetry := range transformed {
+		for _, sq := range squares {
+			if symmetry&1 != 0 {
+				sq ^= 7
+			}
+			if symmetry&2 != 0 {
+				sq ^= 56
+			}
+			if symmetry&4 != 0 {
+				sq = (sq>>3 | sq<<3) & 63
+			}
+			transformed[symmetry] = append(transformed[symmetry], sq)
+		}
+	}
+	return transformed
+}
+
+// setTestSyzygyBoard sets only the pieces and the side to move of a board, which is all the index needs
+func setTestSyzygyBoard(board *Board, pieces, squares []int, side int) *Board {
+	board.Side = side
+	board.bitboards = [14]uint64{}
+	for i, piece := range pieces {
+		board.bitboards[piece] |= 1 << uint(squares[i])
+	}
+	return board
+}
+
+// testSyzygyTable returns a table whose parts all store the pieces in the given order
+func testSyzygyTable(code string, dtz bool, pieces []int, order [2]int) *syzygyTable {
+	table, _ := newSyzygyTable(code, dtz, "")
+	for side := range table.pairs {
+		for file := range table.pairs[side] {
+			pairs := &table.pairs[side][file]
+			for k, piece := range pieces {
+				pairs.pieces[k] = syzygyPiece(piece)
+			}
+			table.setGroups(pairs, order, file)
+		}
+	}
+	return table
+}
+
+// testSyzygyValues returns the values of all parts of a table for the positions of a generated tablebase
+// and their symmetric positions. DTZ tables only get the values of their side to move, which has to be set
+// in the flags before
+func testSyzygyValues(t *testing.T, table *syzygyTable, tablebase *Tablebase, value func(idx int) int) (values [2][4][]int) {
+	var set [2][4][]bool
+	for side := range values {
+		for file := range values[side] {
+			pairs := &table.pairs[side][file]
+			groups := 0
+			for pairs.groupLen[groups] != 0 {
+				groups++
+			}
+			values[side][file] = make([]int, pairs.groupIdx[groups])
+			set[side][file] = make([]bool, pairs.groupIdx[groups])
+		}
+	}
+
+	var board Board
+	pieceNum := len(tablebase.pieces)
+	for idx, stored := range tablebase.values {
+		if stored == tbIllegal {
+			continue
+		}
+		squares, side := tablebase.decode(idx)
+		transformations := symmetries(squares[:pieceNum])
+		symmetryNum := len(transformations)
+		if table.hasPawns {
+			symmetryNum = 2 // only the files are mirrored
+		}
+		for _, transformed := range transformations[:symmetryNum] {
+			setTestSyzygyBoard(&board, tablebase.pieces, transformed, side)
+			pairs, index, state := table.encode(&board, table.code)
+			if state == syzygyChangeSide {
+				continue
+			}
+			part := pairs.part(table)
+			if index >= uint64(len(values[part[0]][part[1]])) {
+				t.Fatalf("%s: index %d out of range for %v", table.code, index, transformed)
+			}
+			v := value(idx)
+			if set[part[0]][part[1]][index] && values[part[0]][part[1]][index] != v {
+				t.Fatalf("%s: index %d of %v (side %d) is also used by a position with another value", table.code, index, transformed, side)
+			}
+			values[part[0]][part[1]][index], set[part[0]][part[1]][index] = v, true
+		}
+	}
+	return values
+}
+
+// part returns the side and the file of compressed values of a table
+func (pairs *syzygyPairs) part(table *syzygyTable) [2]int {
+	for side := range table.pairs {
+		for file := range table.pairs[side] {
+			if pairs == &table.pairs[side][file] {
+				return [2]int{side, file}
+			}
+		}
+	}
+	return [2]int{}
+}
+
+// writeTestSyzygy writes a table file. The values of every part are stored with codes of the same length
+// (symbols that don't expand), parts with the single value flag store their minSymLen. DTZ tables with
+// the mapped flag store the index of the value in the map of their WDL result (dtzMaps[file][map])
+func writeTestSyzygy(t *testing.T, filename string, table *syzygyTable, order [2]int, values [2][4][]int, dtzMaps [4][4][]int) {
+	const blockBits, spanBits = 5, 4
+	const span = 1 << spanBits
+	kind, files, sides := 0, 1, table.sides()
+	if table.dtz {
+		kind = 1
+	}
+	if table.hasPawns {
+		files = 4
+	}
+	bothPawns := table.hasPawns && table.pawnCount[1] > 0
+
+	var file bytes.Buffer
+	file.Write(syzygyMagic[kind][:])
+	flags := byte(0)
+	if !table.dtz && !table.symmetric {
+		flags |= 1 // split: the sides to move are stored separately
+	}
+	if table.hasPawns {
+		flags |= 2
+	}
+	file.WriteByte(flags)
+	for f := 0; f < files; f++ {
+		file.WriteByte(byte(order[0] | order[0]<<4))
+		if bothPawns {
+			file.WriteByte(byte(order[1] | order[1]<<4))
+		}
+		for k := 0; k < table.pieceCount; k++ {
+			file.WriteByte(byte(table.pairs[0][f].pieces[k] | table.pairs[sides-1][f].pieces[k]<<4))
+		}
+	}
+	if file.Len()%2 == 1 {
+		file.WriteByte(0)
+	}
+
+	// the code length, the number of values in a block and the number of blocks of each part
+	var codeLen, blockValues, blocks [4][2]int
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			pairs := &table.pairs[side][f]
+			if pairs.flags&syzygyFlagSingleValue != 0 {
+				file.Write([]byte{pairs.flags, byte(pairs.minSymLen)})
+				continue
+			}
+			codeLen[f][side] = 1
+			for _, value := range values[side][f] {
+				for value >= 1<<uint(codeLen[f][side]) {
+					codeLen[f][side]++
+				}
+			}
+			blockValues[f][side] = (8 << blockBits) / codeLen[f][side]
+			blocks[f][side] = (len(values[side][f]) + blockValues[f][side] - 1) / blockValues[f][side]
+
+			file.Write([]byte{pairs.flags, blockBits, spanBits, 0})
+			binary.Write(&file, binary.LittleEndian, uint32(blocks[f][side]))
+			file.Write([]byte{byte(codeLen[f][side]), byte(codeLen[f][side])}) // maximum and minimum code length
+			symbols := 1 << uint(codeLen[f][side])
+			binary.Write(&file, binary.LittleEndian, []uint16{0, uint16(symbols)})
+			for sym := 0; sym < symbols; sym++ {
+				file.Write([]byte{byte(sym), byte(sym>>8) | 0xF0, 0xFF}) // symbols that don't expand
+			}
+		}
+	}
+
+	if table.dtz {
+		for f := 0; f < files; f++ {
+			if table.pairs[0][f].flags&syzygyFlagMapped == 0 {
+				continue
+			}
+			for _, dtzMap := range dtzMaps[f] {
+				file.WriteByte(byte(len(dtzMap)))
+				for _, value := range dtzMap {
+					file.WriteByte(byte(value))
+				}
+			}
+		}
+		if file.Len()%2 == 1 {
+			file.WriteByte(0)
+		}
+	}
+
+	// the sparse index stores the block and the offset in it of the middle value of each span
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			for k := 0; k*span < len(values[side][f]) && blocks[f][side] > 0; k++ {
+				middle := k*span + span/2
+				block := middle / blockValues[f][side]
+				if block >= blocks[f][side] {
+					block = blocks[f][side] - 1
+				}
+				binary.Write(&file, binary.LittleEndian, uint32(block))
+				binary.Write(&file, binary.LittleEndian, uint16(middle-block*blockValues[f][side]))
+			}
+		}
+	}
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			for block := 0; block < blocks[f][side]; block++ {
+				length := blockValues[f][side]
+				if block == blocks[f][side]-1 {
+					length = len(values[side][f]) - block*blockValues[f][side]
+				}
+				binary.Write(&file, binary.LittleEndian, uint16(length-1))
+			}
+		}
+	}
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			file.Write(make([]byte, (64-file.Len()%64)%64))
+			data := make([]byte, blocks[f][side]<<blockBits)
+			length := codeLen[f][side]
+			for i, value := range values[side][f] {
+				for b := 0; b < length; b++ {
+					bit := (i/blockValues[f][side])<<(blockBits+3) + length*(i%blockValues[f][side]) + b
+					if value>>uint(length-1-b)&1 != 0 {
+						data[bit/8] |= 0x80 >> uint(bit%8)
+					}
+				}
+			}
+			file.Write(data)
+		}
+	}
+
+	if err := ioutil.WriteFile(filename, file.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestSyzygyWDL writes the WDL table of a generated tablebase
+func writeTestSyzygyWDL(t *testing.T, dir string, tablebase *Tablebase, pieces []int) {
+	table := testSyzygyTable(tablebase.Signature, false, pieces, [2]int{0, 0xF})
+	values := testSyzygyValues(t, table, tablebase, func(idx int) int {
+		return 2 + 2*tbResult(tablebase.values[idx]).WDL
+	})
+	writeTestSyzygy(t, filepath.Join(dir, tablebase.Signature+".rtbw"), table, [2]int{0, 0xF}, values, [4][4][]int{})
+}
+
+// writeTestSyzygyDTZ writes the DTZ table of a generated tablebase for the side to move of the flags. Wins and
+// losses are stored in moves or plies like the flags say, through the maps of their WDL results if they are mapped
+func writeTestSyzygyDTZ(t *testing.T, dir string, tablebase *Tablebase, pieces []int, flags uint8, dtz []int) {
+	table := testSyzygyTable(tablebase.Signature, true, pieces, [2]int{0, 0xF})
+	for file := range table.pairs[0] {
+		table.pairs[0][file].flags = flags
+	}
+
+	// the stored value and the map of a DTZ
+	stored := func(dtz int) (value, dtzMap int) {
+		switch {
+		case dtz > 0 && flags&syzygyFlagWinPlies == 0:
+			return (dtz - 1) / 2, 0
+		case dtz > 0:
+			return dtz - 1, 0
+		case dtz < 0 && flags&syzygyFlagLossPlies == 0:
+			return (-dtz - 1) / 2, 1
+		case dtz < 0:
+			return -dtz - 1, 1
+		}
+		return 0, 0
+	}
+	var dtzMaps [4][4][]int
+	var symbols [4]map[int]int
+	for i := range symbols {
+		symbols[i] = map[int]int{}
+	}
+	if flags&syzygyFlagMapped != 0 {
+		var maps [4][]int
+		for _, d := range dtz {
+			if value, dtzMap := stored(d); d != 0 {
+				if _, found := symbols[dtzMap][value]; !found {
+					symbols[dtzMap][value] = 0
+					maps[dtzMap] = append(maps[dtzMap], value)
+				}
+			}
+		}
+		for i := range maps {
+			sort.Ints(maps[i])
+			for symbol, value := range maps[i] {
+				symbols[i][value] = symbol
+			}
+		}
+		for file := range dtzMaps {
+			dtzMaps[file] = maps
+		}
+	}
+
+	values := testSyzygyValues(t, table, tablebase, func(idx int) int {
+		value, dtzMap := stored(dtz[idx])
+		if flags&syzygyFlagMapped != 0 {
+			return symbols[dtzMap][value]
+		}
+		return value
+	})
+	writeTestSyzygy(t, filepath.Join(dir, tablebase.Signature+".rtbz"), table, [2]int{0, 0xF}, values, dtzMaps)
+}
+
+// testDTZ returns the DTZ of the positions of a generated tablebase like Syzygy probes return it: the plies to the
+// next capture, pawn move or mate, positive if the side to move wins and negative if it loses. The winning side
+// takes the shortest way and the losing side the longest, positions without moves have -1
+func testDTZ(tablebase *Tablebase, tables map[string]*Tablebase) []int {
+	gen := &tbGenerator{table: tablebase, tables: tables}
+	dtz := make([]int, len(tablebase.values))
+	known := make([]bool, len(tablebase.values))
+	successors := make([][]int, len(tablebase.values))
+
+	for idx, value := range tablebase.values {
+		if value == tbIllegal || value == tbDraw {
+			known[idx] = true
+			continue
+		}
+		wins := tbResult(value).WDL == TBWin
+		position := tbPosition{}
+		position.squares, position.side = tablebase.decode(idx)
+		zeroing := false
+		gen.moves(&position, func(successor *tbPosition, converted []int) {
+			var result uint8
+			successorIdx := -1
+			if converted != nil {
+				result, _ = probeTables(tables, converted, successor.squares[:len(converted)], successor.side)
+			} else {
+				successorIdx = tablebase.index(&successor.squares, successor.side)
+				result = tablebase.values[successorIdx]
+			}
+			if wins && (result == tbDraw || tbResult(result).WDL != TBLoss) {
+				return // the move doesn't keep the win
+			}
+
+			pawnMove := false
+			for i, piece := range tablebase.pieces {
+				if converted == nil && (piece == WP || piece == BP) && successor.squares[i] != position.squares[i] {
+					pawnMove = true
+				}
+			}
+			if converted != nil || pawnMove || result == 1 { // captures, promotions, pawn moves and mates
+				zeroing = true
+				return
+			}
+			successors[idx] = append(successors[idx], successorIdx)
+		})
+
+		if (wins && zeroing) || (!wins && len(successors[idx]) == 0) {
+			dtz[idx], known[idx] = 1, true
+			if !wins {
+				dtz[idx] = -1
+			}
+		}
+	}
+
+	// positions are resolved ply by ply: wins with a successor lost in ply-1 and losses whose
+	// successors were all resolved before
+	for ply := 2; ; ply++ {
+		var resolved []int
+		for idx := range dtz {
+			if known[idx] {
+				continue
+			}
+			if tbResult(tablebase.values[idx]).WDL == TBWin {
+				for _, successor := range successors[idx] {
+					if known[successor] && dtz[successor] == 1-ply {
+						resolved = append(resolved, idx)
+						break
+					}
+				}
+				continue
+			}
+			all := true
+			for _, successor := range successors[idx] {
+				all = all && known[successor]
+			}
+			if all {
+				resolved = append(resolved, idx)
+			}
+		}
+		if len(resolved) == 0 {
+			return dtz
+		}
+		for _, idx := range resolved {
+			dtz[idx], known[idx] = ply, true
+			if tbResult(tablebase.values[idx]).WDL == TBLoss {
+				dtz[idx] = -ply
+			}
+		}
+	}
+}
+
+// testTBResult returns the result of a position from generated tablebases, en passant squares are ignored
+func testTBResult(tables map[string]*Tablebase, board *Board) TBResult {
+	var pieces, squares []int
+	for piece := WP; piece <= BK; piece++ {
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			pieces, squares = append(pieces, piece), append(squares, bits.TrailingZeros64(bitboard))
+		}
+	}
+	value, _ := probeTables(tables, pieces, squares, board.Side)
+	return tbResult(value)
+}
+
+// testSyzygyDTZTables writes the WDL and DTZ tables of KRvK and KPvK. Returns the DTZ of the generated tablebases
+func testSyzygyDTZTables(t *testing.T, dir string) map[string][]int {
+	tablebases := generateTestTablebases(t)
+	tests := []struct {
+		signature string
+		pieces    []int
+		flags     uint8
+	}{
+		{"KRvK", []int{WK, WR, BK}, syzygyFlagMapped},                    // white to move, wins in moves
+		{"KPvK", []int{WP, WK, BK}, syzygyFlagSTM | syzygyFlagLossPlies}, // black to move, losses in plies
+		{"KQvK", []int{WK, WQ, BK}, syzygyFlagSTM | syzygyFlagMapped | syzygyFlagLossPlies},
+	}
+	dtz := map[string][]int{}
+	for _, test := range tests {
+		tablebase := tablebases[test.signature]
+		dtz[test.signature] = testDTZ(tablebase, tablebases)
+		writeTestSyzygyWDL(t, dir, tablebase, test.pieces)
+		writeTestSyzygyDTZ(t, dir, tablebase, test.pieces, test.flags, dtz[test.signature])
+	}
+	// the tables of the promotions
+	for _, piece := range []int{WB, WN} {
+		tablebase := tablebases[fmt.Sprintf("K%cvK", PieceChar[piece])]
+		writeTestSyzygyWDL(t, dir, tablebase, []int{WK, piece, BK})
+	}
+	return dtz
+}
+
+func TestSyzygyProbe(t *testing.T) {
+	tablebase := generateTestTablebases(t)["KRvK"]
+	dir, err := ioutil.TempDir("", "syzygy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the values of the file are the results of the generated tablebase, all symmetric positions
+	// have to get the same index
+	writeTestSyzygyWDL(t, dir, tablebase, []int{WK, WR, BK})
+
+	if tableNum, err := LoadSyzygy(dir + string(os.PathListSeparator) + dir); err != nil || tableNum != 1 {
+		t.Fatalf("Expected 1 table, got %d (%v)", tableNum, err)
+	}
+	defer ClearSyzygy()
+
+	// the positions are also found with swapped colours and in all symmetric positions
+	var board, other Board
+	swapped := []int{BK, BR, WK}
+	for idx, value := range tablebase.values {
+		if value == tbIllegal {
+			continue
+		}
+		squares, side := tablebase.decode(idx)
+		wdl := 2 * tbResult(value).WDL
+		for _, transformed := range symmetries(squares[:3]) {
+			for _, board := range []*Board{
+				setTestSyzygyBoard(&board, tablebase.pieces, transformed, side),
+				setTestSyzygyBoard(&other, swapped, symmetries(transformed)[2], side^1),
+			} {
+				if value, state := board.probeSyzygyTable(false, 0); state != syzygyOK || value != wdl {
+					t.Fatalf("%v (side %d): expected %d, got %d (state %d)", transformed, side, wdl, value, state)
+				}
+			}
+		}
+	}
+
+	InitHashKeys()
+	tests := []struct {
+		fen string
+		wdl int
+	}{
+		{"8/8/8/4k3/8/8/8/R3K3 w - - 0 1", WDLWin},
+		{"8/8/8/4k3/8/8/8/R3K3 b - - 0 1", WDLLoss},
+		{"8/8/8/8/8/3k4/1r6/K7 w - - 0 1", WDLDraw}, // the rook is lost
+		{"7r/8/8/8/8/1k6/8/K7 b - - 0 1", WDLWin},
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", WDLWin},
+	}
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+		if wdl, found := board.ProbeWDL(); !found || wdl != test.wdl {
+			t.Errorf("%s: expected %d, got %d (found %v)", test.fen, test.wdl, wdl, found)
+		}
+	}
+
+	board = Board{}
+	board.ParseFen("4k3/8/8/8/8/8/8/R3K3 w Q - 0 1")
+	if _, found := board.ProbeWDL(); found {
+		t.Errorf("Positions with castling rights are not in the tablebases")
+	}
+	board.ParseFen("7k/8/8/n7/8/8/8/R3K3 w - - 0 1")
+	if _, found := board.ProbeWDL(); found {
+		t.Errorf("KRvKN is not in the tablebases")
+	}
+
+	// the capture of the knight leads to a won position
+	result := board.Search(&SearchInfo{Depth: 3})
+	if result.Score < SyzygyWin-MaxDepth || result.Score > IsMate || result.TBHits == 0 {
+		t.Errorf("Expected a tablebase win, got %d (%d tbhits)", result.Score, result.TBHits)
+	}
+	if move := GetMoveString(result.BestMove); move != "a1a5" {
+		t.Errorf("Expected a1a5, got %s", move)
+	}
+
+	if _, err := LoadSyzygy(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+}
+
+// testSyzygyFen returns the FEN of a position given by its pieces and their squares
+func testSyzygyFen(pieces, squares []int, side int) string {
+	var position [BoardSquareNum]byte
+	for i, piece := range pieces {
+		position[squares[i]] = PieceChar[piece]
+	}
+	var fen strings.Builder
+	for rank := 0; rank < 8; rank++ {
+		if rank > 0 {
+			fen.WriteByte('/')
+		}
+		empty := 0
+		for file := 0; file < 8; file++ {
+			if char := position[rank*8+file]; char != 0 {
+				if empty > 0 {
+					fen.WriteByte(byte('0' + empty))
+				}
+				fen.WriteByte(char)
+				empty = 0
+			} else {
+				empty++
+			}
+		}
+		if empty > 0 {
+			fen.WriteByte(byte('0' + empty))
+		}
+	}
+	return fen.String() + map[int]string{White: " w - - 0 1", Black: " b - - 0 1"}[side]
+}
+
+// TestSyzygyTestdata compares Syzygy tables in testdata/syzygy (if there are any) with the generated tablebases.
+// Tables that store the distances in moves return DTZ values that can be one ply longer
+func TestSyzygyTestdata(t *testing.T) {
+	tableNum, err := LoadSyzygy(filepath.Join("testdata", "syzygy"))
+	if err != nil || tableNum == 0 {
+		t.Skip("No Syzygy tables in testdata/syzygy")
+	}
+	defer ClearSyzygy()
+
+	tablebases := generateTestTablebases(t)
+	for _, tablebase := range tablebases {
+		var dtz []int
+		for idx, value := range tablebase.values {
+			if value == tbIllegal {
+				continue
+			}
+			squares, side := tablebase.decode(idx)
+			board := Board{}
+			if err := board.ParseFen(testSyzygyFen(tablebase.pieces, squares[:len(tablebase.pieces)], side)); err != nil {
+				continue // the side not to move is in check
+			}
+			wdl, found := board.ProbeWDL()
+			if !found {
+				break // the table is not in testdata
+			}
+			if expected := 2 * tbResult(value).WDL; wdl != expected {
+				t.Fatalf("%s: expected %d, got %d", board.Fen(), expected, wdl)
+			}
+
+			probed, found := board.ProbeDTZ()
+			if !found {
+				continue // the DTZ table is not in testdata
+			}
+			if dtz == nil {
+				dtz = testDTZ(tablebase, tablebases)
+			}
+			if signOf(probed) != signOf(dtz[idx]) || probed-dtz[idx] > 1 || dtz[idx]-probed > 1 {
+				t.Fatalf("%s: expected DTZ %d, got %d", board.Fen(), dtz[idx], probed)
+			}
+			if idx%37 == 0 {
+				if moves, expected := rootMoveStrings(&board), testRootMoves(tablebases, &board, false); moves != expected {
+					t.Fatalf("%s: expected the root moves %s, got %s", board.Fen(), expected, moves)
+				}
+			}
+		}
+	}
+}
+
+func TestSyzygyPawnIndex(t *testing.T) {
+	tablebase := generateTestTablebases(t)["KPvK"]
+
+	table, _ := newSyzygyTable("KPvK", false, "")
+	for side := range table.pairs {
+		for file := range table.pairs[side] {
+			table.pairs[side][file].pieces = [SyzygyMaxPieces]int{syzygyPiece(WP), syzygyPiece(WK), syzygyPiece(BK)}
+			table.setGroups(&table.pairs[side][file], [2]int{0, 0xF}, file)
+		}
+	}
+
+	// positions with the pawn on the same file of the a-d half have the same part of the table,
+	// the file mirrored positions have the same index
+	values := map[[3]uint64]uint8{}
+	var board Board
+	for idx, value := range tablebase.values {
+		if value == tbIllegal {
+			continue
+		}
+		squares, side := tablebase.decode(idx)
+		for _, mirror := range []int{0, 7} {
+			mirrored := []int{squares[0] ^ mirror, squares[1] ^ mirror, squares[2] ^ mirror}
+			setTestSyzygyBoard(&board, tablebase.pieces, mirrored, side)
+			pairs, index, _ := table.encode(&board, "KPvK")
+			if size := pairs.groupIdx[3]; index >= size {
+				t.Fatalf("Index %d of %v out of range %d", index, mirrored, size)
+			}
+
+			file := uint64(mirrored[1] & 7)
+			if file > 3 {
+				file = 7 - file
+			}
+			key := [3]uint64{uint64(side), file, index}
+			if other, found := values[key]; found && other != value {
+				t.Fatalf("Index %d of %v (side %d) is also used by a position with another result", index, mirrored, side)
+			}
+			values[key] = value
+		}
+	}
+}
+
+func TestSyzygyDTZ(t *testing.T) {
+	tablebases := generateTestTablebases(t)
+	dir, err := ioutil.TempDir("", "syzygy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dtz := testSyzygyDTZTables(t, dir)
+	if tableNum, err := LoadSyzygy(dir); err != nil || tableNum != 5 {
+		t.Fatalf("Expected 5 tables, got %d (%v)", tableNum, err)
+	}
+	defer ClearSyzygy()
+
+	// the tables only store one side to move, the other one is searched. The positions are also probed
+	// with swapped colours
+	for signature, expected := range dtz {
+		tablebase := tablebases[signature]
+		pieceNum := len(tablebase.pieces)
+		swapped := make([]int, pieceNum)
+		for i, piece := range tablebase.pieces {
+			swapped[i] = swapColour(piece)
+		}
+
+		board := Board{}
+		for idx, value := range tablebase.values {
+			if value == tbIllegal {
+				continue
+			}
+			squares, side := tablebase.decode(idx)
+			mirrored := make([]int, pieceNum)
+			for i := range mirrored {
+				mirrored[i] = squares[i] ^ 56
+			}
+			for _, fen := range []string{
+				testSyzygyFen(tablebase.pieces, squares[:pieceNum], side),
+				testSyzygyFen(swapped, mirrored, side^1),
+			} {
+				if err := board.ParseFen(fen); err != nil {
+					t.Fatal(err)
+				}
+				if wdl, found := board.ProbeWDL(); !found || wdl != 2*tbResult(value).WDL {
+					t.Fatalf("%s: expected WDL %d, got %d (found %v)", fen, 2*tbResult(value).WDL, wdl, found)
+				}
+				if dtz, found := board.ProbeDTZ(); !found || dtz != expected[idx] {
+					t.Fatalf("%s: expected DTZ %d, got %d (found %v)", fen, expected[idx], dtz, found)
+				}
+			}
+		}
+	}
+}
+
+// testRootMoves returns the moves that keep the result of the generated tablebases, only the fastest wins and
+// the slowest losses if optimal is set. All moves are returned if the position is lost
+func testRootMoves(tables map[string]*Tablebase, board *Board, optimal bool) string {
+	result := testTBResult(tables, board)
+	moveList := board.GetMoves()
+	var moves []string
+	bestDTM := -1
+	for moveNum := 0; moveNum < moveList.Count; moveNum++ {
+		move := moveList.Moves[moveNum].Move
+		board.MakeMove(move)
+		child := testTBResult(tables, board)
+		board.TakeMove()
+		if result.WDL != TBLoss && child.WDL != -result.WDL {
+			continue
+		}
+		if optimal && result.WDL != TBDraw {
+			better := child.DTM < bestDTM
+			if result.WDL == TBLoss {
+				better = child.DTM > bestDTM
+			}
+			if bestDTM >= 0 && child.DTM != bestDTM && !better {
+				continue
+			}
+			if bestDTM < 0 || better {
+				bestDTM, moves = child.DTM, moves[:0]
+			}
+		}
+		moves = append(moves, GetMoveString(move))
+	}
+	sort.Strings(moves)
+	return strings.Join(moves, " ")
+}
+
+// rootMoveStrings returns the sorted moves returned by syzygyRootMoves
+func rootMoveStrings(board *Board) string {
+	var moves []string
+	for _, move := range board.syzygyRootMoves() {
+		moves = append(moves, GetMoveString(move))
+	}
+	sort.Strings(moves)
+	return strings.Join(moves, " ")
+}
+
+func TestSyzygyRootMoves(t *testing.T) {
+	tablebases := generateTestTablebases(t)
+	dir, err := ioutil.TempDir("", "syzygy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testSyzygyDTZTables(t, dir)
+	if _, err := LoadSyzygy(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer ClearSyzygy()
+
+	// far from the fifty move rule all moves that keep the result are played
+	board := Board{}
+	for _, signature := range []string{"KRvK", "KPvK", "KQvK"} {
+		tablebase := tablebases[signature]
+		pieceNum := len(tablebase.pieces)
+		for idx := 0; idx < len(tablebase.values); idx += 37 {
+			if tablebase.values[idx] == tbIllegal {
+				continue
+			}
+			squares, side := tablebase.decode(idx)
+			if err := board.ParseFen(testSyzygyFen(tablebase.pieces, squares[:pieceNum], side)); err != nil {
+				t.Fatal(err)
+			}
+			if moves, expected := rootMoveStrings(&board), testRootMoves(tablebases, &board, false); moves != expected {
+				t.Fatalf("%s: expected %s, got %s", board.Fen(), expected, moves)
+			}
+		}
+	}
+
+	// close to the fifty move rule or after a repetition only the fastest wins and the slowest losses are
+	// played. The DTZ of KRvK is the distance to mate
+	for _, fen := range []string{"8/8/8/4k3/8/8/8/R3K3 w - - %d 1", "8/8/8/4k3/8/8/8/R3K3 b - - %d 1"} {
+		board.ParseFen(fmt.Sprintf(fen, 0))
+		all, optimal := testRootMoves(tablebases, &board, false), testRootMoves(tablebases, &board, true)
+		if moves := rootMoveStrings(&board); moves != all || len(optimal) >= len(all) {
+			t.Fatalf("%s: expected %s (optimal %s), got %s", board.Fen(), all, optimal, moves)
+		}
+		board.ParseFen(fmt.Sprintf(fen, 95))
+		if moves := rootMoveStrings(&board); moves != optimal {
+			t.Errorf("%s: expected %s, got %s", board.Fen(), optimal, moves)
+		}
+	}
+
+	// losses that are converted within the fifty move rule anyway are all played, otherwise the slowest one
+	board.ParseFen("8/8/8/4k3/8/8/8/R3K3 b - - 0 1")
+	all, slowest := testRootMoves(tablebases, &board, false), testRootMoves(tablebases, &board, true)
+	loss := testTBResult(tablebases, &board).DTM
+	board.ParseFen(fmt.Sprintf("8/8/8/4k3/8/8/8/R3K3 b - - %d 1", 100-loss))
+	if moves := rootMoveStrings(&board); moves != all {
+		t.Errorf("%s: expected %s, got %s", board.Fen(), all, moves)
+	}
+	board.ParseFen(fmt.Sprintf("8/8/8/4k3/8/8/8/R3K3 b - - %d 1", 101-loss))
+	if moves := rootMoveStrings(&board); moves != slowest {
+		t.Errorf("%s: expected %s, got %s", board.Fen(), slowest, moves)
+	}
+
+	// without repetitions the wins that stay within the fifty move rule are played
+	board.ParseFen("8/8/8/4k3/8/8/8/R3K3 w - - 0 1")
+	optimal := testRootMoves(tablebases, &board, true)
+	board.MakeMoves(strings.Fields(optimal)[0])
+	fastest := testTBResult(tablebases, &board).DTM + 1
+	board.ParseFen(fmt.Sprintf("8/8/8/4k3/8/8/8/R3K3 w - - %d 1", 99-fastest))
+	if moves := rootMoveStrings(&board); moves != optimal {
+		t.Errorf("%s: expected %s, got %s", board.Fen(), optimal, moves)
+	}
+
+	board.ParseFen("8/8/8/4k3/8/8/8/R3K3 w - - 0 1")
+	board.MakeMoves("a1a2 e5e6 a2a1 e6e5")
+	if moves := rootMoveStrings(&board); moves != optimal {
+		t.Errorf("Expected %s after a repetition, got %s", optimal, moves)
+	}
+}
+
+func TestSyzygyEnPassant(t *testing.T) {
+	tablebases := generateTestTablebases(t)
+	dir, err := ioutil.TempDir("", "syzygy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testSyzygyDTZTables(t, dir)
+
+	// the KPvKP table claims that the side to move loses all positions, other results
+	// can only come from the captures that are searched
+	table := testSyzygyTable("KPvKP", false, []int{WP, BP, WK, BK}, [2]int{0, 1})
+	for file := range table.pairs[0] {
+		table.pairs[0][file].flags, table.pairs[0][file].minSymLen = syzygyFlagSingleValue, 0
+	}
+	writeTestSyzygy(t, filepath.Join(dir, "KPvKP.rtbw"), table, [2]int{0, 1}, [2][4][]int{}, [4][4][]int{})
+	if tableNum, err := LoadSyzygy(dir); err != nil || tableNum != 6 {
+		t.Fatalf("Expected 6 tables, got %d (%v)", tableNum, err)
+	}
+	defer ClearSyzygy()
+
+	tests := []struct {
+		fen string
+		wdl int
+	}{
+		{"K7/8/8/8/3pP3/8/3k4/8 b - e3 0 1", WDLWin}, // dxe3 wins
+		{"K7/8/8/8/3pP3/8/3k4/8 b - - 0 1", WDLLoss},
+		{"8/2k5/8/3pP3/8/8/8/7K w - d6 0 1", WDLDraw}, // exd6+ Kxd6 draws
+		{"8/2k5/8/3pP3/8/8/8/7K w - - 0 1", WDLLoss},
+	}
+	board := Board{}
+	for _, test := range tests {
+		if err := board.ParseFen(test.fen); err != nil {
+			t.Fatal(err)
+		}
+		if wdl, found := board.ProbeWDL(); !found || wdl != test.wdl {
+			t.Errorf("%s: expected %d, got %d (found %v)", test.fen, test.wdl, wdl, found)
+		}
+	}
+
+	// the capture is the best move, its DTZ isn't stored
+	board.ParseFen(tests[0].fen)
+	if dtz, found := board.ProbeDTZ(); !found || dtz != 1 {
+		t.Errorf("Expected DTZ 1 before the capture, got %d", dtz)
+	}
+	board.MakeMoves("d4e3")
+	if result := testTBResult(tablebases, &board); result.WDL != TBLoss {
+		t.Errorf("Expected a loss after the capture, got %v", result)
+	}
+}
@@ -0,0 +1,481 @@
+package board
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TBMaxPieces maximum number of pieces (including the kings) of the generated tablebases
+const TBMaxPieces int = 4
+
+// TBFileExtension extension of tablebase files, the file name is the signature e.g. KRvKP.ptb
+const TBFileExtension string = ".ptb"
+
+// tbFileHeader first line of a tablebase file (followed by the signature)
+const tbFileHeader string = "platypus-tb 1"
+
+// Results of tablebase probes from the side to move's perspective
+const (
+	TBLoss int = -1
+	TBDraw int = 0
+	TBWin  int = 1
+)
+
+// Values stored in the tables. Other values are the distance to mate in plies plus 1,
+// odd distances are wins for the side to move and even distances are losses
+const (
+	tbDraw    uint8 = 0
+	tbIllegal uint8 = 255
+)
+
+// tbPieceOrder order of the pieces of a side in a signature (after the king)
+const tbPieceOrder string = "QRBNP"
+
+// TBResult result of a tablebase probe
+type TBResult struct {
+	// WDL TBWin, TBDraw or TBLoss for the side to move
+	WDL int
+	// DTM distance to mate in plies, 0 for draws and positions in which the side to move is mated
+	DTM int
+}
+
+// Score returns the result as a search score from the side to move's perspective at the given ply
+func (result TBResult) Score(ply int) int {
+	switch result.WDL {
+	case TBWin:
+		return Infinite - ply - result.DTM
+	case TBLoss:
+		return -Infinite + ply + result.DTM
+	}
+	return 0
+}
+
+// tbResult converts a stored value to a probe result
+func tbResult(value uint8) TBResult {
+	if value == tbDraw {
+		return TBResult{WDL: TBDraw}
+	}
+	dtm := int(value) - 1
+	if dtm%2 == 1 {
+		return TBResult{WDL: TBWin, DTM: dtm}
+	}
+	return TBResult{WDL: TBLoss, DTM: dtm}
+}
+
+// Tablebase distance to mate of every position of a material signature. Positions are indexed by the
+// squares of the pieces, symmetric positions (mirrored and for pawnless tables also rotated) share an index
+type Tablebase struct {
+	// Signature pieces of white before the 'v' and of black after it, e.g. KRvKP
+	Signature string
+
+	pieces []int // pieces in index order: white king, other white pieces, black king, other black pieces
+	pawns  bool  // if there are pawns only the files can be mirrored
+	values []uint8
+}
+
+// Symmetries of the board. Pawnless positions use all of them, positions with pawns only the first two
+const (
+	tbMirrorFile = 1 << iota
+	tbMirrorRank
+	tbTranspose
+	tbSymmetries = 8
+)
+
+// tbKingSquares index of the white king's square among the squares it is moved to by the
+// symmetries (-1 for the other squares). Without pawns it is the triangle a1-d1-d4, otherwise the files a to d
+var tbKingSquares [2][BoardSquareNum]int
+
+// tbKingSquareNum number of squares of the white king in the index, without and with pawns
+var tbKingSquareNum [2]int
+
+func init() {
+	for pawns := 0; pawns < 2; pawns++ {
+		for sq := 0; sq < BoardSquareNum; sq++ {
+			file, rank := sq%8, 7-sq/8
+			if file < 4 && (pawns == 1 || rank <= file) {
+				tbKingSquares[pawns][sq] = tbKingSquareNum[pawns]
+				tbKingSquareNum[pawns]++
+			} else {
+				tbKingSquares[pawns][sq] = -1
+			}
+		}
+	}
+}
+
+// tbTransform applies a symmetry to a square
+func tbTransform(symmetry, sq int) int {
+	if symmetry&tbMirrorFile != 0 {
+		sq ^= 7
+	}
+	if symmetry&tbMirrorRank != 0 {
+		sq ^= 56
+	}
+	if symmetry&tbTranspose != 0 {
+		// reflection in the a1-h8 diagonal
+		sq = (7-sq%8)*8 + 7 - sq/8
+	}
+	return sq
+}
+
+// swapColour returns the piece of the same type of the other colour
+func swapColour(piece int) int {
+	if piece >= BP {
+		return piece - 6
+	}
+	return piece + 6
+}
+
+// tbSignature returns the signature of the material and if the colours have to be swapped to get
+// the signature of a table: the side with more material is always white in the tables
+func tbSignature(counts [13]int) (signature string, swapped bool) {
+	var sides [2]string
+	material := [2]int{}
+	for side := White; side <= Black; side++ {
+		pieces := "K"
+		for _, char := range tbPieceOrder {
+			piece := side*6 + strings.IndexRune(PieceChar, char)
+			pieces += strings.Repeat(string(char), counts[piece])
+			material[side] += counts[piece] * PieceValue[piece]
+		}
+		sides[side] = pieces
+	}
+
+	swapped = material[Black] > material[White] ||
+		(material[Black] == material[White] && tbSideLess(sides[White], sides[Black]))
+	if swapped {
+		return sides[Black] + "v" + sides[White], true
+	}
+	return sides[White] + "v" + sides[Black], false
+}
+
+// tbSideLess orders the sides of signatures with equal material, so the signature is unique
+func tbSideLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a > b
+}
+
+// parseTBSignature returns the pieces of a signature in index order, which is the signature order
+func parseTBSignature(signature string) ([]int, error) {
+	sides := strings.Split(signature, "v")
+	if len(sides) != 2 {
+		return nil, fmt.Errorf("Invalid tablebase signature %q", signature)
+	}
+
+	var pieces []int
+	var counts [13]int
+	for side, sidePieces := range sides {
+		if !strings.HasPrefix(sidePieces, "K") {
+			return nil, fmt.Errorf("Invalid tablebase signature %q: every side needs a king", signature)
+		}
+		for _, char := range sidePieces {
+			idx := strings.IndexRune(PieceChar, char)
+			if idx <= 0 || idx > WK {
+				return nil, fmt.Errorf("Invalid tablebase signature %q: unknown piece %c", signature, char)
+			}
+			pieces = append(pieces, side*6+idx)
+			counts[side*6+idx]++
+		}
+	}
+	if len(pieces) > TBMaxPieces {
+		return nil, fmt.Errorf("Invalid tablebase signature %q: at most %d pieces are supported", signature, TBMaxPieces)
+	}
+	if canonical, _ := tbSignature(counts); canonical != signature {
+		return nil, fmt.Errorf("Invalid tablebase signature %q: expected %q", signature, canonical)
+	}
+
+	return pieces, nil
+}
+
+// newTablebase creates an empty table of a signature
+func newTablebase(signature string) (*Tablebase, error) {
+	pieces, err := parseTBSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	table := &Tablebase{Signature: signature, pieces: pieces}
+	for _, piece := range pieces {
+		if piece == WP || piece == BP {
+			table.pawns = true
+		}
+	}
+	table.values = make([]uint8, table.size())
+	return table, nil
+}
+
+// size returns the number of indices of the table
+func (table *Tablebase) size() int {
+	size := 2 * tbKingSquareNum[table.pawnIdx()]
+	for range table.pieces[1:] {
+		size *= BoardSquareNum
+	}
+	return size
+}
+
+func (table *Tablebase) pawnIdx() int {
+	if table.pawns {
+		return 1
+	}
+	return 0
+}
+
+// index returns the index of a position, the squares are in the order of the table's pieces. The index
+// is the smallest of the symmetric positions, squares of identical pieces are sorted so it is unique
+func (table *Tablebase) index(squares *[TBMaxPieces]int, side int) int {
+	symmetries := tbSymmetries
+	if table.pawns {
+		symmetries = 2
+	}
+	kingSquares := &tbKingSquares[table.pawnIdx()]
+
+	best := -1
+	for symmetry := 0; symmetry < symmetries; symmetry++ {
+		king := kingSquares[tbTransform(symmetry, squares[0])]
+		if king < 0 {
+			continue
+		}
+
+		var transformed [TBMaxPieces]int
+		for i := 1; i < len(table.pieces); i++ {
+			transformed[i] = tbTransform(symmetry, squares[i])
+			// insertion sort of identical pieces
+			for j := i; j > 1 && table.pieces[j] == table.pieces[j-1] && transformed[j] < transformed[j-1]; j-- {
+				transformed[j], transformed[j-1] = transformed[j-1], transformed[j]
+			}
+		}
+
+		idx := king
+		for i := 1; i < len(table.pieces); i++ {
+			idx = idx*BoardSquareNum + transformed[i]
+		}
+		idx = idx*2 + side
+		if best < 0 || idx < best {
+			best = idx
+		}
+	}
+	return best
+}
+
+// decode returns the squares and the side to move of an index
+func (table *Tablebase) decode(idx int) (squares [TBMaxPieces]int, side int) {
+	side = idx % 2
+	idx /= 2
+	for i := len(table.pieces) - 1; i > 0; i-- {
+		squares[i] = idx % BoardSquareNum
+		idx /= BoardSquareNum
+	}
+	kingSquares := &tbKingSquares[table.pawnIdx()]
+	for sq := 0; sq < BoardSquareNum; sq++ {
+		if kingSquares[sq] == idx {
+			squares[0] = sq
+			break
+		}
+	}
+	return squares, side
+}
+
+// probeTables returns the stored value of a position given by its pieces and their squares
+func probeTables(tables map[string]*Tablebase, pieces, squares []int, side int) (value uint8, found bool) {
+	var counts [13]int
+	for _, piece := range pieces {
+		counts[piece]++
+	}
+	signature, swapped := tbSignature(counts)
+	if signature == "KvK" {
+		return tbDraw, true
+	}
+	table, found := tables[signature]
+	if !found {
+		return tbDraw, false
+	}
+
+	// the squares in the order of the table's pieces, with the colours swapped if needed
+	var ordered [TBMaxPieces]int
+	var used [TBMaxPieces]bool
+	for i, piece := range table.pieces {
+		if swapped {
+			piece = swapColour(piece)
+		}
+		for j := range pieces {
+			if !used[j] && pieces[j] == piece {
+				used[j] = true
+				ordered[i] = squares[j]
+				break
+			}
+		}
+		if swapped {
+			ordered[i] ^= 56
+		}
+	}
+	if swapped {
+		side ^= 1
+	}
+	return table.values[table.index(&ordered, side)], true
+}
+
+// tablebases tables used by ProbeTB
+var tablebases = struct {
+	sync.RWMutex
+	tables    map[string]*Tablebase
+	maxPieces int32 // number of pieces of the largest table, accessed atomically
+}{tables: map[string]*Tablebase{}}
+
+// AddTablebase makes a table available to ProbeTB
+func AddTablebase(table *Tablebase) {
+	tablebases.Lock()
+	defer tablebases.Unlock()
+	tablebases.tables[table.Signature] = table
+	if pieceNum := int32(len(table.pieces)); pieceNum > atomic.LoadInt32(&tablebases.maxPieces) {
+		atomic.StoreInt32(&tablebases.maxPieces, pieceNum)
+	}
+}
+
+// ClearTablebases removes all tables used by ProbeTB
+func ClearTablebases() {
+	tablebases.Lock()
+	defer tablebases.Unlock()
+	tablebases.tables = map[string]*Tablebase{}
+	atomic.StoreInt32(&tablebases.maxPieces, 0)
+}
+
+// LoadTablebases loads all tables of a directory and makes them available to ProbeTB.
+// Returns the number of loaded tables
+func LoadTablebases(dir string) (int, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*"+TBFileExtension))
+	if err != nil {
+		return 0, err
+	}
+	for _, filename := range filenames {
+		table, err := LoadTablebase(filename)
+		if err != nil {
+			return 0, err
+		}
+		AddTablebase(table)
+	}
+	return len(filenames), nil
+}
+
+// ProbeTB returns the result of the position from the loaded tablebases. Positions with castling
+// rights or an en passant square are not in the tables
+func (board *Board) ProbeTB() (result TBResult, found bool) {
+	var occupied uint64
+	for piece := WP; piece <= BK; piece++ {
+		occupied |= board.bitboards[piece]
+	}
+	if bits.OnesCount64(occupied) > int(atomic.LoadInt32(&tablebases.maxPieces)) ||
+		board.castlePermissions != 0 || board.bitboards[EP] != 0 {
+		return TBResult{}, false
+	}
+
+	var pieces, squares [TBMaxPieces]int
+	pieceNum := 0
+	for piece := WP; piece <= BK; piece++ {
+		for bitboard := board.bitboards[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			pieces[pieceNum], squares[pieceNum] = piece, bits.TrailingZeros64(bitboard)
+			pieceNum++
+		}
+	}
+
+	tablebases.RLock()
+	value, found := probeTables(tablebases.tables, pieces[:pieceNum], squares[:pieceNum], board.Side)
+	tablebases.RUnlock()
+	if !found || value == tbIllegal {
+		return TBResult{}, false
+	}
+	return tbResult(value), true
+}
+
+// Stats returns the number of won, drawn and lost positions (for the side to move) and the longest mate in plies
+func (table *Tablebase) Stats() (wins, draws, losses, longest int) {
+	for _, value := range table.values {
+		if value == tbIllegal {
+			continue
+		}
+		result := tbResult(value)
+		switch result.WDL {
+		case TBWin:
+			wins++
+		case TBDraw:
+			draws++
+		case TBLoss:
+			losses++
+		}
+		if result.DTM > longest {
+			longest = result.DTM
+		}
+	}
+	return wins, draws, losses, longest
+}
+
+// Write writes the table in the tablebase file format: a gzip stream of a header
+// line with the signature followed by one byte per index
+func (table *Tablebase) Write(writer io.Writer) error {
+	compressed := gzip.NewWriter(writer)
+	if _, err := fmt.Fprintf(compressed, "%s %s\n", tbFileHeader, table.Signature); err != nil {
+		return err
+	}
+	if _, err := compressed.Write(table.values); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// Save writes the table to a file named after its signature in the given directory
+func (table *Tablebase) Save(dir string) error {
+	file, err := os.Create(filepath.Join(dir, table.Signature+TBFileExtension))
+	if err != nil {
+		return err
+	}
+	if err := table.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadTablebase reads a table in the tablebase file format
+func ReadTablebase(reader io.Reader) (*Tablebase, error) {
+	compressed, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+
+	buffered := bufio.NewReader(compressed)
+	header, err := buffered.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Invalid tablebase header: %v", err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 || strings.Join(fields[:2], " ") != tbFileHeader {
+		return nil, fmt.Errorf("Invalid tablebase header %q", strings.TrimSpace(header))
+	}
+
+	table, err := newTablebase(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(buffered, table.values); err != nil {
+		return nil, fmt.Errorf("Truncated tablebase %s: %v", table.Signature, err)
+	}
+	return table, nil
+}
+
+// LoadTablebase reads a table from a file
+func LoadTablebase(filename string) (*Tablebase, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadTablebase(file)
+}
@@ -0,0 +1,539 @@
+package board
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// tbNoConversion marks positions without captures or promotions during the generation
+const tbNoConversion uint8 = 0
+
+// tbConversionDraw marks positions with a capture or promotion that draws during the generation
+const tbConversionDraw uint8 = 254
+
+// tbGenerator state of the retrograde analysis of a table
+type tbGenerator struct {
+	table  *Tablebase
+	tables map[string]*Tablebase // tables of the signatures captures and promotions lead to
+
+	// count number of distinct successors in the table that are not yet known to be won by the opponent
+	count []uint8
+	// conversion best result of the captures and promotions (stored value), tbConversionDraw or tbNoConversion
+	conversion []uint8
+
+	attacker Board // only used for the sliding piece attacks
+}
+
+// tbPosition position during the generation, the pieces are those of the table
+type tbPosition struct {
+	squares [TBMaxPieces]int
+	side    int
+}
+
+// TablebaseSignatures returns the signatures of all tables with up to the given number of pieces. Tables
+// come after the tables they depend on, so they can be generated in this order
+func TablebaseSignatures(maxPieces int) []string {
+	var signatures []string
+	seen := map[string]bool{}
+
+	var add func(counts [13]int, pieceNum, minPiece int)
+	add = func(counts [13]int, pieceNum, minPiece int) {
+		if pieceNum > 2 {
+			if signature, _ := tbSignature(counts); !seen[signature] {
+				seen[signature] = true
+				signatures = append(signatures, signature)
+			}
+		}
+		if pieceNum == maxPieces {
+			return
+		}
+		for piece := minPiece; piece <= BK; piece++ {
+			if piece == WK || piece == BK {
+				continue
+			}
+			counts[piece]++
+			add(counts, pieceNum+1, piece)
+			counts[piece]--
+		}
+	}
+	var kings [13]int
+	kings[WK], kings[BK] = 1, 1
+	add(kings, 2, WP)
+
+	sort.SliceStable(signatures, func(i, j int) bool {
+		if len(signatures[i]) != len(signatures[j]) {
+			return len(signatures[i]) < len(signatures[j])
+		}
+		return strings.Count(signatures[i], "P") < strings.Count(signatures[j], "P")
+	})
+	return signatures
+}
+
+// TablebaseDependencies returns the signatures of the tables a table depends on: the
+// signatures its positions can be converted to by captures and promotions
+func TablebaseDependencies(signature string) ([]string, error) {
+	pieces, err := parseTBSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies []string
+	seen := map[string]bool{}
+	addCounts := func(counts [13]int) {
+		if converted, _ := tbSignature(counts); converted != "KvK" && !seen[converted] {
+			seen[converted] = true
+			dependencies = append(dependencies, converted)
+		}
+	}
+
+	var counts [13]int
+	for _, piece := range pieces {
+		counts[piece]++
+	}
+	for piece := WP; piece <= BK; piece++ {
+		if counts[piece] == 0 || piece == WK || piece == BK {
+			continue
+		}
+		// captures
+		counts[piece]--
+		addCounts(counts)
+		counts[piece]++
+
+		if piece == WP || piece == BP {
+			for promoted := piece + 1; promoted < piece+5; promoted++ {
+				counts[piece]--
+				counts[promoted]++
+				addCounts(counts)
+				// a promotion with a capture
+				for captured := WP; captured <= BK; captured++ {
+					if counts[captured] > 0 && PieceColour[captured] != PieceColour[piece] &&
+						captured != WK && captured != BK {
+						counts[captured]--
+						addCounts(counts)
+						counts[captured]++
+					}
+				}
+				counts[promoted]--
+				counts[piece]++
+			}
+		}
+	}
+	return dependencies, nil
+}
+
+// GenerateTablebases generates the tables of the signatures and all tables they depend on that
+// are missing from tables. Generated tables are added to tables and passed to done (if not nil)
+func GenerateTablebases(signatures []string, tables map[string]*Tablebase, done func(table *Tablebase) error) error {
+	for _, signature := range signatures {
+		if _, found := tables[signature]; found {
+			continue
+		}
+		dependencies, err := TablebaseDependencies(signature)
+		if err != nil {
+			return err
+		}
+		if err := GenerateTablebases(dependencies, tables, done); err != nil {
+			return err
+		}
+
+		table, err := GenerateTablebase(signature, tables)
+		if err != nil {
+			return err
+		}
+		tables[signature] = table
+		if done != nil {
+			if err := done(table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateTablebase generates the table of a signature by retrograde analysis. The tables of all
+// signatures the positions can be converted to by captures and promotions have to be in tables.
+// Positions are resolved ply by ply: positions with a successor that is lost for the opponent in n plies
+// are won in n+1, positions whose successors are all won for the opponent are lost. The rest are draws
+func GenerateTablebase(signature string, tables map[string]*Tablebase) (*Tablebase, error) {
+	table, err := newTablebase(signature)
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := TablebaseDependencies(signature)
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		if _, found := tables[dependency]; !found {
+			return nil, fmt.Errorf("Cannot generate %s: the %s table is missing", signature, dependency)
+		}
+	}
+
+	gen := &tbGenerator{
+		table:      table,
+		tables:     tables,
+		count:      make([]uint8, len(table.values)),
+		conversion: make([]uint8, len(table.values)),
+	}
+	maxPly := gen.initialise()
+
+	for ply := 0; ply <= maxPly; ply++ {
+		if ply+2 >= int(tbConversionDraw) {
+			return nil, fmt.Errorf("Cannot generate %s: the distance to mate doesn't fit the table", signature)
+		}
+		for idx, value := range table.values {
+			// captures and promotions that win in this ply, if nothing shorter was found in the table
+			if value == tbDraw && gen.conversion[idx] == uint8(ply+1) && ply%2 == 1 {
+				table.values[idx] = uint8(ply + 1)
+			} else if value != uint8(ply+1) {
+				continue
+			}
+
+			if resolved := gen.propagate(idx, ply); resolved > maxPly {
+				maxPly = resolved
+			}
+		}
+	}
+	return table, nil
+}
+
+// initialise marks illegal positions, mates and stalemates and collects the results of captures and
+// promotions. Returns the largest ply of a result that is already known
+func (gen *tbGenerator) initialise() (maxPly int) {
+	table := gen.table
+	var successors [128]int
+
+	for idx := range table.values {
+		position := tbPosition{}
+		position.squares, position.side = table.decode(idx)
+		if !gen.valid(&position) || table.index(&position.squares, position.side) != idx {
+			table.values[idx] = tbIllegal
+			continue
+		}
+
+		successorNum, moves := 0, 0
+		conversion := tbNoConversion
+		gen.moves(&position, func(successor *tbPosition, converted []int) {
+			moves++
+			if converted != nil {
+				value, _ := probeTables(gen.tables, converted, successor.squares[:len(converted)], successor.side)
+				conversion = tbBetter(conversion, value)
+				return
+			}
+
+			// successors with several moves leading to them (or to a symmetric position) are counted once
+			successorIdx := table.index(&successor.squares, successor.side)
+			for i := 0; i < successorNum; i++ {
+				if successors[i] == successorIdx {
+					return
+				}
+			}
+			successors[successorNum] = successorIdx
+			successorNum++
+		})
+
+		gen.count[idx] = uint8(successorNum)
+		gen.conversion[idx] = conversion
+
+		if moves == 0 {
+			if gen.inCheck(&position, position.side) {
+				table.values[idx] = 1 // mated
+			}
+			continue
+		}
+		// all moves are captures or promotions that lose
+		if successorNum == 0 && conversion != tbNoConversion && conversion != tbConversionDraw && conversion%2 == 1 {
+			table.values[idx] = conversion
+		}
+
+		if conversion != tbConversionDraw && int(conversion)-1 > maxPly {
+			maxPly = int(conversion) - 1
+		}
+	}
+	return maxPly
+}
+
+// tbBetter returns the better of two results for the side that chooses between them. The current
+// result is given as a generation value (tbNoConversion, tbConversionDraw or a distance to mate), the
+// other as a stored value of the opponent's position
+func tbBetter(current, opponent uint8) uint8 {
+	result := tbConversionDraw
+	if opponent != tbDraw {
+		// the opponent's loss in n plies is our win in n+1 plies and vice versa
+		result = opponent + 1
+	}
+	if current == tbNoConversion {
+		return result
+	}
+	return tbBestOf(current, result)
+}
+
+// tbBestOf returns the better of two generation values for the side to move:
+// the shortest win, then a draw and then the longest loss
+func tbBestOf(a, b uint8) uint8 {
+	rank := func(value uint8) int {
+		switch {
+		case value == tbConversionDraw:
+			return 0
+		case (value-1)%2 == 1:
+			return 1000 - int(value) // wins
+		default:
+			return -1000 + int(value) // losses
+		}
+	}
+	if rank(a) >= rank(b) {
+		return a
+	}
+	return b
+}
+
+// propagate updates the predecessors of a position that was resolved in the given ply.
+// Returns the largest ply of a newly resolved predecessor (-1 if there is none)
+func (gen *tbGenerator) propagate(idx, ply int) (maxPly int) {
+	table := gen.table
+	position := tbPosition{}
+	position.squares, position.side = table.decode(idx)
+
+	var predecessors [128]int
+	predecessorNum := 0
+	maxPly = -1
+
+	gen.unmoves(&position, func(predecessor *tbPosition) {
+		predecessorIdx := table.index(&predecessor.squares, predecessor.side)
+		for i := 0; i < predecessorNum; i++ {
+			if predecessors[i] == predecessorIdx {
+				return
+			}
+		}
+		predecessors[predecessorNum] = predecessorIdx
+		predecessorNum++
+
+		if table.values[predecessorIdx] != tbDraw {
+			return // illegal or already resolved
+		}
+		if ply%2 == 0 {
+			// the position is lost for the side to move, so the predecessor is won
+			table.values[predecessorIdx] = uint8(ply + 2)
+			if ply+1 > maxPly {
+				maxPly = ply + 1
+			}
+			return
+		}
+
+		gen.count[predecessorIdx]--
+		conversion := gen.conversion[predecessorIdx]
+		if gen.count[predecessorIdx] > 0 || conversion == tbConversionDraw ||
+			(conversion != tbNoConversion && (conversion-1)%2 == 1) {
+			return
+		}
+		// all successors are won for the opponent, the longest loss is chosen
+		lossPly := ply + 1
+		if conversion != tbNoConversion && int(conversion)-1 > lossPly {
+			lossPly = int(conversion) - 1
+		}
+		table.values[predecessorIdx] = uint8(lossPly + 1)
+		if lossPly > maxPly {
+			maxPly = lossPly
+		}
+	})
+	return maxPly
+}
+
+// pieceAttacks returns the squares attacked by a piece of the position
+func (gen *tbGenerator) pieceAttacks(piece, sq int, occupied uint64) uint64 {
+	switch piece {
+	case WP:
+		return whitePawnAttacks(sq)
+	case BP:
+		// the attacks of a white pawn on the vertically flipped square, flipped back
+		return bits.ReverseBytes64(whitePawnAttacks(sq ^ 56))
+	case WK, BK:
+		return KingMoves[sq]
+	}
+	return gen.attacker.pieceAttacks(piece, sq, occupied)
+}
+
+// occupancy returns the squares occupied by each side
+func (gen *tbGenerator) occupancy(position *tbPosition) (sides [2]uint64) {
+	for i, piece := range gen.table.pieces {
+		sides[PieceColour[piece]] |= 1 << uint(position.squares[i])
+	}
+	return sides
+}
+
+// inCheck returns true if the king of the side is attacked
+func (gen *tbGenerator) inCheck(position *tbPosition, side int) bool {
+	pieces := gen.table.pieces
+	sides := gen.occupancy(position)
+	occupied := sides[White] | sides[Black]
+
+	king := 0
+	for i, piece := range pieces {
+		if piece == side*6+WK {
+			king = position.squares[i]
+		}
+	}
+	for i, piece := range pieces {
+		if PieceColour[piece] != side && gen.pieceAttacks(piece, position.squares[i], occupied)&(1<<uint(king)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// valid returns false if pieces share a square, pawns are on the first or last rank or the side
+// that is not to move is in check
+func (gen *tbGenerator) valid(position *tbPosition) bool {
+	var occupied uint64
+	for i, piece := range gen.table.pieces {
+		sq := position.squares[i]
+		if occupied&(1<<uint(sq)) != 0 {
+			return false
+		}
+		occupied |= 1 << uint(sq)
+		if (piece == WP || piece == BP) && (sq < 8 || sq >= 56) {
+			return false
+		}
+	}
+	return !gen.inCheck(position, position.side^1)
+}
+
+// moves calls found for every legal move of the side to move. Captures and promotions leave the table,
+// for them the pieces of the successor are passed as well (the squares are in the same order)
+func (gen *tbGenerator) moves(position *tbPosition, found func(successor *tbPosition, converted []int)) {
+	pieces := gen.table.pieces
+	side := position.side
+	sides := gen.occupancy(position)
+	occupied := sides[White] | sides[Black]
+
+	var converted [TBMaxPieces]int
+	try := func(moved, to, promoted int) {
+		successor := tbPosition{squares: position.squares, side: side ^ 1}
+		successor.squares[moved] = to
+
+		captured := -1
+		for i := range pieces {
+			if i != moved && position.squares[i] == to {
+				captured = i
+			}
+		}
+		if captured < 0 && promoted == NoPiece {
+			if !gen.inCheck(&successor, side) {
+				found(&successor, nil)
+			}
+			return
+		}
+
+		// the successor's pieces without the captured piece
+		pieceNum := 0
+		for i, piece := range pieces {
+			if i == captured {
+				continue
+			}
+			if i == moved && promoted != NoPiece {
+				piece = promoted
+			}
+			converted[pieceNum] = piece
+			successor.squares[pieceNum] = successor.squares[i]
+			pieceNum++
+		}
+		if !gen.convertedInCheck(converted[:pieceNum], successor.squares[:pieceNum], side) {
+			found(&successor, converted[:pieceNum])
+		}
+	}
+
+	for i, piece := range pieces {
+		if PieceColour[piece] != side {
+			continue
+		}
+		sq := position.squares[i]
+
+		if piece == WP || piece == BP {
+			forward, startRank, lastRank := -8, 6, 0
+			if piece == BP {
+				forward, startRank, lastRank = 8, 1, 7
+			}
+			targets := gen.pieceAttacks(piece, sq, occupied) & sides[side^1]
+			if push := sq + forward; occupied&(1<<uint(push)) == 0 {
+				targets |= 1 << uint(push)
+				if double := push + forward; sq/8 == startRank && occupied&(1<<uint(double)) == 0 {
+					targets |= 1 << uint(double)
+				}
+			}
+			for ; targets != 0; targets &= targets - 1 {
+				to := bits.TrailingZeros64(targets)
+				if to/8 != lastRank {
+					try(i, to, NoPiece)
+					continue
+				}
+				for promoted := piece + 1; promoted < piece+5; promoted++ {
+					try(i, to, promoted)
+				}
+			}
+			continue
+		}
+
+		for targets := gen.pieceAttacks(piece, sq, occupied) &^ sides[side]; targets != 0; targets &= targets - 1 {
+			try(i, bits.TrailingZeros64(targets), NoPiece)
+		}
+	}
+}
+
+// convertedInCheck returns true if the side's king is attacked after a capture or promotion
+func (gen *tbGenerator) convertedInCheck(pieces, squares []int, side int) bool {
+	var occupied uint64
+	king := 0
+	for i, piece := range pieces {
+		occupied |= 1 << uint(squares[i])
+		if piece == side*6+WK {
+			king = squares[i]
+		}
+	}
+	for i, piece := range pieces {
+		if PieceColour[piece] != side && gen.pieceAttacks(piece, squares[i], occupied)&(1<<uint(king)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// unmoves calls found for every position in the table from which a move leads to the position.
+// Captures and promotions can't lead to positions of the table, so only quiet moves are taken back
+func (gen *tbGenerator) unmoves(position *tbPosition, found func(predecessor *tbPosition)) {
+	pieces := gen.table.pieces
+	side := position.side ^ 1 // the side that made the move
+	sides := gen.occupancy(position)
+	occupied := sides[White] | sides[Black]
+
+	for i, piece := range pieces {
+		if PieceColour[piece] != side {
+			continue
+		}
+		sq := position.squares[i]
+
+		var origins uint64
+		if piece == WP || piece == BP {
+			backward, doublePushRank := 8, 4
+			if piece == BP {
+				backward, doublePushRank = -8, 3
+			}
+			// pawns can't come from the first rank
+			if from := sq + backward; from >= 8 && from < 56 && occupied&(1<<uint(from)) == 0 {
+				origins |= 1 << uint(from)
+				if double := from + backward; sq/8 == doublePushRank && occupied&(1<<uint(double)) == 0 {
+					origins |= 1 << uint(double)
+				}
+			}
+		} else {
+			origins = gen.pieceAttacks(piece, sq, occupied) &^ occupied
+		}
+
+		for ; origins != 0; origins &= origins - 1 {
+			predecessor := tbPosition{squares: position.squares, side: side}
+			predecessor.squares[i] = bits.TrailingZeros64(origins)
+			found(&predecessor)
+		}
+	}
+}
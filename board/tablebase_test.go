@@ -0,0 +1,236 @@
+package board
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+var (
+	testTablebasesOnce sync.Once
+	testTablebases     map[string]*Tablebase
+)
+
+// generateTestTablebases generates the tables with 3 pieces once for all tests
+func generateTestTablebases(t *testing.T) map[string]*Tablebase {
+	InitHashKeys()
+	testTablebasesOnce.Do(func() {
+		tables := map[string]*Tablebase{}
+		if err := GenerateTablebases(TablebaseSignatures(3), tables, nil); err != nil {
+			t.Fatal(err)
+		}
+		testTablebases = tables
+	})
+	if testTablebases == nil {
+		t.Fatal("Tablebase generation failed")
+	}
+	return testTablebases
+}
+
+func TestTablebaseSignature(t *testing.T) {
+	var counts [13]int
+	counts[WK], counts[BK], counts[BR], counts[WP] = 1, 1, 1, 1
+	if signature, swapped := tbSignature(counts); signature != "KRvKP" || !swapped {
+		t.Errorf("Expected KRvKP with swapped colours, got %s %v", signature, swapped)
+	}
+
+	expected := []string{"KNvK", "KBvK", "KRvK", "KQvK", "KPvK"}
+	signatures := TablebaseSignatures(3)
+	if len(signatures) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, signatures)
+	}
+	for _, signature := range expected {
+		found := false
+		for _, other := range signatures {
+			found = found || other == signature
+		}
+		if !found {
+			t.Errorf("Expected %s in %v", signature, signatures)
+		}
+	}
+	if signatures[len(signatures)-1] != "KPvK" {
+		t.Errorf("Expected KPvK after the tables it depends on: %v", signatures)
+	}
+
+	for _, invalid := range []string{"KRK", "KvKR", "RvK", "KXvK", "KQQQvK"} {
+		if _, err := parseTBSignature(invalid); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestGenerateTablebase(t *testing.T) {
+	tables := generateTestTablebases(t)
+
+	// the longest mates of KQK and KRK are 10 and 16 moves (lost for the side to move)
+	longestMates := map[string]int{"KQvK": 20, "KRvK": 32, "KBvK": 0, "KNvK": 0}
+	for signature, expected := range longestMates {
+		if _, _, _, longest := tables[signature].Stats(); longest != expected {
+			t.Errorf("%s: expected the longest mate in %d plies, got %d", signature, expected, longest)
+		}
+	}
+
+	// the KPK table agrees with the KPK bitbase
+	table := tables["KPvK"]
+	for idx, value := range table.values {
+		if value == tbIllegal {
+			continue
+		}
+		squares, side := table.decode(idx)
+		win := tbResult(value).WDL == TBWin
+		if side == Black {
+			win = tbResult(value).WDL == TBLoss
+		}
+		if win != probeKPK(side, squares[0], squares[2], squares[1]) {
+			t.Fatalf("KPK mismatch: white king %d, pawn %d, black king %d, side %d", squares[0], squares[1], squares[2], side)
+		}
+	}
+
+	if _, err := GenerateTablebase("KQvKR", map[string]*Tablebase{}); err == nil {
+		t.Errorf("Expected an error for missing dependencies")
+	}
+}
+
+func TestProbeTB(t *testing.T) {
+	for _, table := range generateTestTablebases(t) {
+		AddTablebase(table)
+	}
+	defer ClearTablebases()
+
+	tests := []struct {
+		fen    string
+		result TBResult
+	}{
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", TBResult{TBWin, 1}},
+		{"R6k/8/6K1/8/8/8/8/8 b - - 0 1", TBResult{TBLoss, 0}},
+		{"k7/8/1Q6/8/8/8/8/K7 b - - 0 1", TBResult{TBDraw, 0}},  // stalemate
+		{"8/8/8/8/8/3k4/1r6/K7 w - - 0 1", TBResult{TBDraw, 0}}, // the rook is lost
+		{"7r/8/8/8/8/1k6/8/K7 b - - 0 1", TBResult{TBWin, 1}},   // black has the rook
+		{"3k4/8/3K4/3P4/8/8/8/8 w - - 0 1", TBResult{WDL: TBWin}},
+		{"7k/8/7P/8/8/8/8/K7 w - - 0 1", TBResult{TBDraw, 0}},
+	}
+	for _, test := range tests {
+		board := Board{}
+		board.ParseFen(test.fen)
+		result, found := board.ProbeTB()
+		if !found {
+			t.Errorf("%s: not found in the tablebases", test.fen)
+			continue
+		}
+		if result.WDL != test.result.WDL || (test.result.DTM != 0 && result.DTM != test.result.DTM) {
+			t.Errorf("%s: expected %+v, got %+v", test.fen, test.result, result)
+		}
+	}
+
+	board := Board{}
+	board.ParseFen(StartingPosition)
+	if _, found := board.ProbeTB(); found {
+		t.Errorf("The starting position can't be in the tablebases")
+	}
+	board.ParseFen("4k3/8/8/8/8/8/8/R3K3 w Q - 0 1")
+	if _, found := board.ProbeTB(); found {
+		t.Errorf("Positions with castling rights are not in the tablebases")
+	}
+}
+
+func TestTablebaseSearch(t *testing.T) {
+	for _, table := range generateTestTablebases(t) {
+		AddTablebase(table)
+	}
+	defer ClearTablebases()
+
+	board := Board{}
+	board.ParseFen("8/8/8/4k3/8/8/8/R3K3 w - - 0 1")
+	expected, _ := board.ProbeTB()
+
+	result := board.Search(&SearchInfo{Depth: 2})
+	if result.Score != expected.Score(0) || result.TBHits == 0 {
+		t.Errorf("Expected the tablebase score %d, got %d (%d tbhits)", expected.Score(0), result.Score, result.TBHits)
+	}
+
+	// the root moves are filtered to those that keep the shortest mate
+	board.MakeMove(result.BestMove)
+	after, _ := board.ProbeTB()
+	if after.WDL != TBLoss || after.DTM != expected.DTM-1 {
+		t.Errorf("Expected a move to a loss in %d plies, got %+v", expected.DTM-1, after)
+	}
+}
+
+func TestTablebaseReadWrite(t *testing.T) {
+	table := generateTestTablebases(t)["KRvK"]
+
+	var buffer bytes.Buffer
+	if err := table.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTablebase(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Signature != table.Signature || !bytes.Equal(read.values, table.values) {
+		t.Errorf("The table changed when it was written and read")
+	}
+
+	if _, err := ReadTablebase(bytes.NewReader([]byte("not a tablebase"))); err == nil {
+		t.Errorf("Expected an error for an invalid file")
+	}
+}
+
+func TestTablebaseLongMateScores(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Generating KQvKR takes a while")
+	}
+	tables := map[string]*Tablebase{}
+	for signature, table := range generateTestTablebases(t) {
+		tables[signature] = table
+	}
+	table, err := GenerateTablebase("KQvKR", tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddTablebase(table)
+	defer ClearTablebases()
+
+	// the longest KQvKR win takes 35 moves, more than the search depth
+	longestWin, longestIdx := 0, -1
+	for idx, value := range table.values {
+		if value == tbIllegal {
+			continue
+		}
+		if result := tbResult(value); result.WDL == TBWin && result.DTM > longestWin {
+			longestWin, longestIdx = result.DTM, idx
+		}
+	}
+	if longestWin <= MaxDepth {
+		t.Fatalf("Expected a win longer than %d plies, got %d", MaxDepth, longestWin)
+	}
+
+	squares, side := table.decode(longestIdx)
+	board := setTestSyzygyBoard(&Board{}, table.pieces, squares[:len(table.pieces)], side)
+	result, found := board.ProbeTB()
+	if !found || result.WDL != TBWin || result.DTM != longestWin {
+		t.Fatalf("Expected a win in %d plies, got %+v (found %v)", longestWin, result, found)
+	}
+
+	// the won position and a lost one are reached at ply 10 and again at ply 20 through a longer line
+	hashTable := NewHashTable(1)
+	for _, score := range []int{result.Score(10), TBResult{TBLoss, longestWin - 1}.Score(10)} {
+		expectedMate := (10 + longestWin + 1) / 2
+		if score < 0 {
+			expectedMate = -(10 + longestWin - 1) / 2
+		}
+		if MateIn(score) != expectedMate {
+			t.Errorf("Expected mate in %d for score %d, got %d", expectedMate, score, MateIn(score))
+		}
+
+		hashTable.Store(1, 0, score, 2, HashExact, 10)
+		entry, _ := hashTable.Probe(1, 20)
+		expected := score - 10
+		if score < 0 {
+			expected = score + 10
+		}
+		if int(entry.Score) != expected {
+			t.Errorf("Expected the hashed score %d to be %d at ply 20, got %d", score, expected, entry.Score)
+		}
+	}
+}
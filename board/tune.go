@@ -0,0 +1,254 @@
+package board
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// TuneEntry position of a tuning dataset labelled with the result of the game it was taken from
+type TuneEntry struct {
+	pieces [13]uint64 // piece bitboards, all other state is irrelevant to the evaluation
+	side   int
+	// Result game result from white's perspective: 1 win, 0.5 draw, 0 loss
+	Result float64
+}
+
+// tuneResults result notations accepted in datasets
+var tuneResults = map[string]float64{
+	"1-0": 1, "1/2-1/2": 0.5, "0-1": 0,
+	"1": 1, "1.0": 1, "0.5": 0.5, "0": 0, "0.0": 0,
+}
+
+// ReadTuneEntries reads a dataset with one labelled position per line. Two formats are accepted:
+//
+//	EPD:  <pieces> <side> <castling> <en passant> [opcodes] c9 "1-0";  (or the result in brackets e.g. [0.5])
+//	CSV:  <fen>,<result>
+//
+// where the result is one of 1-0, 1/2-1/2, 0-1 or 1, 0.5, 0. Empty lines and lines starting with # are skipped
+func ReadTuneEntries(reader io.Reader) ([]TuneEntry, error) {
+	var entries []TuneEntry
+	board := Board{}
+
+	scanner := bufio.NewScanner(reader)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fen, result, err := parseTuneLine(line)
+		if err == nil {
+			err = board.ParseFen(fen)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", lineNum, err)
+		}
+
+		entry := TuneEntry{side: board.Side, Result: result}
+		copy(entry.pieces[:], board.bitboards[:13])
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// parseTuneLine splits a dataset line into the FEN and the game result
+func parseTuneLine(line string) (fen string, result float64, err error) {
+	var resultString string
+	if comma := strings.LastIndex(line, ","); comma >= 0 {
+		fen, resultString = line[:comma], line[comma+1:]
+	} else {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			return "", 0, fmt.Errorf("Expected a position followed by a result: %q", line)
+		}
+		fen = strings.Join(fields[:4], " ")
+
+		// the result is either the c9 opcode or the last field
+		opcodes := strings.Join(fields[4:], " ")
+		if idx := strings.Index(opcodes, "c9 "); idx >= 0 {
+			resultString = strings.SplitN(opcodes[idx+3:], ";", 2)[0]
+		} else {
+			resultString = fields[len(fields)-1]
+		}
+	}
+
+	resultString = strings.Trim(strings.TrimSpace(resultString), "\";[]")
+	result, ok := tuneResults[resultString]
+	if !ok {
+		return "", 0, fmt.Errorf("Unknown result %q: %q", resultString, line)
+	}
+	return strings.TrimSpace(fen), result, nil
+}
+
+// setTuneEntry sets up the pieces of a dataset position on the board
+func (board *Board) setTuneEntry(entry *TuneEntry) {
+	board.Reset()
+	board.Side = entry.side
+	for piece := WP; piece <= BK; piece++ {
+		board.bitboards[piece] = entry.pieces[piece]
+		for bitboard := entry.pieces[piece]; bitboard != 0; bitboard &= bitboard - 1 {
+			board.position[bits.TrailingZeros64(bitboard)] = piece
+		}
+	}
+	board.material = board.computeMaterial()
+	board.psqt, board.phase = board.computePieceSquareScores()
+	board.UpdateBitMasks()
+}
+
+// Tuner fits the evaluation parameters to a dataset of positions by minimising the mean squared
+// error between the game results and the win probability predicted from the evaluation
+type Tuner struct {
+	Entries []TuneEntry
+	Params  *EvalParams
+	// K scaling constant of the sigmoid that maps evaluations to win probabilities
+	K float64
+	// Workers number of goroutines the positions are split between (runtime.GOMAXPROCS if <= 0)
+	Workers int
+
+	boards []Board // one board per worker
+}
+
+// NewTuner creates a tuner that modifies the given parameters
+func NewTuner(entries []TuneEntry, params *EvalParams, workers int) *Tuner {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &Tuner{Entries: entries, Params: params, K: 1, Workers: workers}
+}
+
+// sigmoid maps an evaluation in centipawns to the expected result for white
+func sigmoid(k float64, score int) float64 {
+	return 1 / (1 + math.Pow(10, -k*float64(score)/400))
+}
+
+// Error returns the mean squared error of the current parameters over the dataset
+func (tuner *Tuner) Error() float64 {
+	return tuner.errorWithK(tuner.K)
+}
+
+func (tuner *Tuner) errorWithK(k float64) float64 {
+	if len(tuner.Entries) == 0 {
+		return 0
+	}
+	if len(tuner.boards) != tuner.Workers {
+		tuner.boards = make([]Board, tuner.Workers)
+	}
+	tuner.Params.update()
+
+	errors := make([]float64, tuner.Workers)
+	chunk := (len(tuner.Entries) + tuner.Workers - 1) / tuner.Workers
+	var wg sync.WaitGroup
+	for worker := 0; worker < tuner.Workers; worker++ {
+		start, end := worker*chunk, (worker+1)*chunk
+		if end > len(tuner.Entries) {
+			end = len(tuner.Entries)
+		}
+		wg.Add(1)
+		go func(worker int, entries []TuneEntry) {
+			defer wg.Done()
+			board := &tuner.boards[worker]
+			board.params = tuner.Params
+			for idx := range entries {
+				board.setTuneEntry(&entries[idx])
+				diff := entries[idx].Result - sigmoid(k, board.evaluate(nil))
+				errors[worker] += diff * diff
+			}
+		}(worker, tuner.Entries[start:end])
+	}
+	wg.Wait()
+
+	sum := 0.0
+	for _, err := range errors {
+		sum += err
+	}
+	return sum / float64(len(tuner.Entries))
+}
+
+// FitK finds the scaling constant that minimises the error of the current parameters
+// with a golden-section search and stores it in the tuner
+func (tuner *Tuner) FitK() float64 {
+	low, high := 0.0, 10.0
+	ratio := (math.Sqrt(5) - 1) / 2
+
+	for high-low > 0.0001 {
+		k1 := high - ratio*(high-low)
+		k2 := low + ratio*(high-low)
+		if tuner.errorWithK(k1) < tuner.errorWithK(k2) {
+			high = k2
+		} else {
+			low = k1
+		}
+	}
+	tuner.K = (low + high) / 2
+	return tuner.K
+}
+
+// tuneWeights returns pointers to all weights of the parameters that are tuned
+func (params *EvalParams) tuneWeights() []*int {
+	weights := []*int{&params.PawnValue, &params.KnightValue, &params.BishopValue,
+		&params.RookValue, &params.QueenValue}
+
+	tables := []*PieceSquareTable{&params.PawnTable, &params.KnightTable, &params.BishopTable,
+		&params.RookTable, &params.QueenTable, &params.KingTable}
+	for _, table := range tables {
+		for sq := 0; sq < BoardSquareNum; sq++ {
+			// pawns never stand on the first or last rank
+			if table == &params.PawnTable && (sq < 8 || sq >= 56) {
+				continue
+			}
+			weights = append(weights, &table.Mg[sq], &table.Eg[sq])
+		}
+	}
+
+	// the first and last rank of the passed pawn bonuses can't be reached
+	for rank := 1; rank < 7; rank++ {
+		weights = append(weights, &params.PawnPassed[rank].Mg, &params.PawnPassed[rank].Eg)
+	}
+	for _, score := range []*EvalScore{&params.PawnIsolated, &params.PawnDoubled, &params.RookOpenFile,
+		&params.RookSemiOpenFile, &params.QueenOpenFile, &params.QueenSemiOpenFile, &params.BishopPair,
+		&params.KingDanger, &params.KingShield[0], &params.KingShield[1], &params.KingPawnStorm[1],
+		&params.KingPawnStorm[2], &params.KingPawnStorm[3], &params.KingNearOpenFile, &params.KingNearSemiOpenFile} {
+		weights = append(weights, &score.Mg, &score.Eg)
+	}
+	for idx := range params.KingAttackWeight {
+		weights = append(weights, &params.KingAttackWeight[idx])
+	}
+
+	for _, mobility := range [][]EvalScore{params.KnightMobility[:], params.BishopMobility[:],
+		params.RookMobility[:], params.QueenMobility[:]} {
+		for idx := range mobility {
+			weights = append(weights, &mobility[idx].Mg, &mobility[idx].Eg)
+		}
+	}
+	return weights
+}
+
+// Tune runs one iteration of local search: every weight is changed by +1 and -1 and the
+// change is kept if it lowers the error. Returns the error after the iteration
+func (tuner *Tuner) Tune() float64 {
+	bestError := tuner.Error()
+
+	for _, weight := range tuner.Params.tuneWeights() {
+		*weight++
+		if err := tuner.Error(); err < bestError {
+			bestError = err
+			continue
+		}
+
+		*weight -= 2
+		if err := tuner.Error(); err < bestError {
+			bestError = err
+			continue
+		}
+		*weight++
+	}
+
+	tuner.Params.update()
+	return bestError
+}
@@ -0,0 +1,84 @@
+package board
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDataset = `# white is a queen up
+4k3/8/8/8/8/8/8/3QK3 w - - c9 "1-0";
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - c9 "1/2-1/2";
+rnb1kbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - [1.0]
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNB1KBNR w KQkq - 0 1,0-1
+r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3,0.5
+8/5k2/8/8/8/8/1K6/8 w - - c9 "1/2-1/2"; id "draw";
+
+4k3/8/8/8/8/8/4PPPP/4K3 w - - c9 "1-0";
+4k3/pppp4/8/8/8/8/8/4K3 b - - c9 "0-1";
+`
+
+func TestReadTuneEntries(t *testing.T) {
+	InitHashKeys()
+
+	entries, err := ReadTuneEntries(strings.NewReader(testDataset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{1, 0.5, 1, 0, 0.5, 0.5, 1, 0}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(entries))
+	}
+	for idx, entry := range entries {
+		if entry.Result != expected[idx] {
+			t.Errorf("Entry %d: expected result %v, got %v", idx, expected[idx], entry.Result)
+		}
+	}
+	if entries[2].side != Black || entries[0].pieces[WQ] == 0 {
+		t.Errorf("Incorrect position in entry: %+v", entries[2])
+	}
+
+	for _, line := range []string{
+		"4k3/8/8/8/8/8/8/3QK3 w - -",
+		"4k3/8/8/8/8/8/8/3QK3 w - - c9 \"win\";",
+		"4k3/8/8/8/8/8/8/3QKK2 w - -,1-0",
+	} {
+		if _, err := ReadTuneEntries(strings.NewReader(line)); err == nil {
+			t.Errorf("Expected an error for %q", line)
+		}
+	}
+}
+
+func TestTuner(t *testing.T) {
+	InitHashKeys()
+
+	entries, err := ReadTuneEntries(strings.NewReader(testDataset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := DefaultEvalParams()
+	tuner := NewTuner(entries, params, 3)
+
+	// the evaluation has to predict the results
+	if k := tuner.FitK(); k <= 0 || k >= 10 {
+		t.Errorf("Expected a positive scaling constant, got %f", k)
+	}
+
+	initialError := tuner.Error()
+	tunedError := tuner.Tune()
+	if tunedError >= initialError {
+		t.Errorf("Tuning did not lower the error: %f >= %f", tunedError, initialError)
+	}
+	if err := tuner.Error(); err != tunedError {
+		t.Errorf("Error of the tuned parameters %f != %f", err, tunedError)
+	}
+	if *params == *DefaultEvalParams() {
+		t.Errorf("Tuning did not change the parameters")
+	}
+
+	// the result is independent of the number of workers
+	single := NewTuner(entries, params, 1)
+	single.K = tuner.K
+	if err := single.Error(); err-tunedError > 1e-12 || tunedError-err > 1e-12 {
+		t.Errorf("Error with a single worker %f != %f", err, tunedError)
+	}
+}
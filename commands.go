@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AngelVI13/platypus/board"
+)
+
+// commands command line tools of the engine, without a command the engine speaks UCI on stdin/stdout
+var commands = map[string]func(args []string, out io.Writer) error{
+	"eval":  evalCommand,
+	"tbgen": tbgenCommand,
+	"tune":  tuneCommand,
+}
+
+// runCommand runs the command named by the first argument with the remaining arguments
+func runCommand(args []string, out io.Writer) error {
+	command, ok := commands[args[0]]
+	if !ok {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Unknown command %q (available: %s)", args[0], strings.Join(names, ", "))
+	}
+	return command(args[1:], out)
+}
+
+// evalCommand prints the evaluation breakdown of a position: eval [-json] [-params file] [fen]
+func evalCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "print the evaluation as JSON")
+	paramsFile := flags.String("params", "", "JSON file with the evaluation parameters")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fen := strings.Join(flags.Args(), " ")
+	if fen == "" {
+		fen = board.StartingPosition
+	}
+	pos := board.Board{}
+	if *paramsFile != "" {
+		params, err := board.LoadEvalParams(*paramsFile)
+		if err != nil {
+			return err
+		}
+		pos.SetEvalParams(params)
+	}
+	if err := pos.ParseFen(fen); err != nil {
+		return fmt.Errorf("Invalid FEN (%s): %v", fen, err)
+	}
+
+	trace := pos.EvalTrace()
+	if *jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(trace)
+	}
+	_, err := fmt.Fprint(out, trace)
+	return err
+}
+
+// tuneCommand tunes the evaluation parameters on a dataset of labelled positions:
+// tune [-params file] [-out file] [-iterations n] [-threads n] [-k k] dataset
+func tuneCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	paramsFile := flags.String("params", "", "JSON file with the initial parameters (default: built-in parameters)")
+	outFile := flags.String("out", "tuned.json", "JSON file the tuned parameters are written to after every iteration")
+	iterations := flags.Int("iterations", 100, "maximum number of local search iterations")
+	threads := flags.Int("threads", 0, "number of threads (default: number of CPUs)")
+	k := flags.Float64("k", 0, "scaling constant of the sigmoid (default: fitted to the dataset)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("tune: expected a single dataset (EPD or CSV)")
+	}
+
+	params := board.DefaultEvalParams()
+	if *paramsFile != "" {
+		var err error
+		if params, err = board.LoadEvalParams(*paramsFile); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	entries, err := board.ReadTuneEntries(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", flags.Arg(0), err)
+	}
+	fmt.Fprintf(out, "Loaded %d positions\n", len(entries))
+
+	tuner := board.NewTuner(entries, params, *threads)
+	if *k > 0 {
+		tuner.K = *k
+	} else {
+		fmt.Fprintf(out, "Fitted K: %.4f\n", tuner.FitK())
+	}
+
+	bestError := tuner.Error()
+	fmt.Fprintf(out, "Initial error: %.8f\n", bestError)
+	for iteration := 1; iteration <= *iterations; iteration++ {
+		err := tuner.Tune()
+		fmt.Fprintf(out, "Iteration %d error: %.8f\n", iteration, err)
+		if saveErr := params.Save(*outFile); saveErr != nil {
+			return saveErr
+		}
+		// a local minimum was reached
+		if err >= bestError {
+			break
+		}
+		bestError = err
+	}
+	fmt.Fprintf(out, "Parameters written to %s\n", *outFile)
+	return nil
+}
+
+// tbgenCommand generates endgame tablebases by retrograde analysis: tbgen [-dir dir] [-pieces n] [signature...]
+// Without signatures (e.g. KRvKP) all tables with up to the given number of pieces are generated
+func tbgenCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tbgen", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "directory the tables are written to, tables that are already there are reused")
+	pieces := flags.Int("pieces", 3, fmt.Sprintf("generate all tables with up to this many pieces (3 to %d)", board.TBMaxPieces))
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	signatures := flags.Args()
+	if len(signatures) == 0 {
+		if *pieces < 3 || *pieces > board.TBMaxPieces {
+			return fmt.Errorf("tbgen: the number of pieces must be between 3 and %d", board.TBMaxPieces)
+		}
+		signatures = board.TablebaseSignatures(*pieces)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	tables := map[string]*board.Tablebase{}
+	filenames, err := filepath.Glob(filepath.Join(*dir, "*"+board.TBFileExtension))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		table, err := board.LoadTablebase(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		tables[table.Signature] = table
+	}
+
+	start := time.Now()
+	return board.GenerateTablebases(signatures, tables, func(table *board.Tablebase) error {
+		wins, draws, losses, longest := table.Stats()
+		fmt.Fprintf(out, "%s: %d wins, %d draws, %d losses, longest mate %d plies (%v)\n",
+			table.Signature, wins, draws, losses, longest, time.Since(start).Round(time.Millisecond))
+		start = time.Now()
+		return table.Save(*dir)
+	})
+}
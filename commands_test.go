@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AngelVI13/platypus/board"
+)
+
+func TestEvalCommand(t *testing.T) {
+	board.InitHashKeys()
+
+	var out bytes.Buffer
+	args := strings.Fields("eval r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err := runCommand(args, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Material", "Knight PST", "Passed pawns", "Score:"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runCommand([]string{"eval", "-json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Terms []struct {
+			Name  string
+			Total int
+		}
+		Score int
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(result.Terms) == 0 || result.Score != 0 {
+		t.Errorf("Expected a balanced evaluation of the starting position, got %s", out.String())
+	}
+
+	if err := runCommand(strings.Fields("eval 8/8/8 w - - 0 1"), &out); err == nil {
+		t.Errorf("Expected an error for an invalid FEN")
+	}
+	if err := runCommand([]string{"evaluate"}, &out); err == nil {
+		t.Errorf("Expected an error for an unknown command")
+	}
+}
+
+func TestTuneCommand(t *testing.T) {
+	board.InitHashKeys()
+
+	dir := t.TempDir()
+	dataset := filepath.Join(dir, "dataset.epd")
+	lines := "4k3/8/8/8/8/8/8/3QK3 w - - c9 \"1-0\";\n" +
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - c9 \"1/2-1/2\";\n" +
+		"4k3/pppp4/8/8/8/8/8/4K3 b - - c9 \"0-1\";\n"
+	if err := ioutil.WriteFile(dataset, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	tuned := filepath.Join(dir, "tuned.json")
+	if err := runCommand([]string{"tune", "-iterations", "1", "-out", tuned, dataset}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Loaded 3 positions", "Fitted K", "Iteration 1 error"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, out.String())
+		}
+	}
+	if _, err := board.LoadEvalParams(tuned); err != nil {
+		t.Errorf("Tuned parameters were not written: %v", err)
+	}
+
+	if err := runCommand([]string{"tune", filepath.Join(dir, "missing.epd")}, &out); err == nil {
+		t.Errorf("Expected an error for a missing dataset")
+	}
+}
+
+func TestTbgenCommand(t *testing.T) {
+	board.InitHashKeys()
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	if err := runCommand([]string{"tbgen", "-dir", dir, "KRvK"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "KRvK: ") || !strings.Contains(out.String(), "longest mate 32 plies") {
+		t.Errorf("Expected the statistics of KRvK in output:\n%s", out.String())
+	}
+	if _, err := board.LoadTablebase(filepath.Join(dir, "KRvK"+board.TBFileExtension)); err != nil {
+		t.Errorf("The table was not written: %v", err)
+	}
+
+	// tables that were already generated are reused
+	out.Reset()
+	if err := runCommand([]string{"tbgen", "-dir", dir, "KRvK"}, &out); err != nil || out.Len() != 0 {
+		t.Errorf("Expected the existing table to be reused: %v\n%s", err, out.String())
+	}
+
+	if err := runCommand([]string{"tbgen", "-dir", dir, "KvKR"}, &out); err == nil {
+		t.Errorf("Expected an error for a signature with the weaker side first")
+	}
+	if err := runCommand([]string{"tbgen", "-dir", dir, "-pieces", "6"}, &out); err == nil {
+		t.Errorf("Expected an error for too many pieces")
+	}
+}
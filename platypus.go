@@ -2,10 +2,22 @@ package main
 
 import (
 	"fmt"
+	"os"
+
 	"github.com/AngelVI13/platypus/board"
 )
 
 func main() {
-	boardVar := board.Board{}
-	fmt.Println(&boardVar)
+	board.InitHashKeys()
+
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	engine := newUciEngine(os.Stdout)
+	engine.Loop(os.Stdin)
 }
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AngelVI13/platypus/board"
+)
+
+// EngineName name reported to the GUI on `uci`
+const EngineName = "Platypus"
+
+// EngineAuthor author reported to the GUI on `uci`
+const EngineAuthor = "AngelVI13"
+
+// uciOption describes an option that can be changed by the GUI through `setoption`
+type uciOption struct {
+	name  string // option name as reported to the GUI
+	kind  string // one of check, spin, combo, button, string
+	def   string // default value
+	min   int    // minimum value (spin options only)
+	max   int    // maximum value (spin options only)
+	apply func(engine *uciEngine, value string) error
+}
+
+// String Returns the option declaration sent in response to `uci`
+func (option *uciOption) String() string {
+	declaration := fmt.Sprintf("option name %s type %s", option.name, option.kind)
+	if option.kind != "button" {
+		declaration += fmt.Sprintf(" default %s", option.def)
+	}
+	if option.kind == "spin" {
+		declaration += fmt.Sprintf(" min %d max %d", option.min, option.max)
+	}
+	return declaration
+}
+
+// uciOptions all options supported by the engine
+var uciOptions = []uciOption{
+	{
+		name: "Hash", kind: "spin", def: strconv.Itoa(board.DefaultHashSize), min: 1, max: 4096,
+		apply: func(engine *uciEngine, value string) error {
+			megabytes, err := strconv.Atoi(value)
+			if err != nil || megabytes < 1 || megabytes > 4096 {
+				return fmt.Errorf("setoption: invalid Hash value %s", value)
+			}
+			engine.hashTable.Resize(megabytes)
+			return nil
+		},
+	},
+	{
+		name: "Clear Hash", kind: "button",
+		apply: func(engine *uciEngine, value string) error {
+			engine.hashTable.Clear()
+			return nil
+		},
+	},
+	{
+		name: "EvalFile", kind: "string", def: "<empty>",
+		apply: func(engine *uciEngine, value string) error {
+			// an empty value restores the built-in parameters
+			if value == "" || value == "<empty>" {
+				engine.board.SetEvalParams(nil)
+				engine.pawnTable.Clear()
+				return nil
+			}
+			params, err := board.LoadEvalParams(value)
+			if err != nil {
+				return fmt.Errorf("setoption: cannot load EvalFile: %v", err)
+			}
+			engine.board.SetEvalParams(params)
+			// the cached pawn structure scores were computed with the old parameters
+			engine.pawnTable.Clear()
+			return nil
+		},
+	},
+	{
+		name: "TablebasePath", kind: "string", def: "<empty>",
+		apply: func(engine *uciEngine, value string) error {
+			board.ClearTablebases()
+			engine.hashTable.Clear()
+			if value == "" || value == "<empty>" {
+				return nil
+			}
+			tableNum, err := board.LoadTablebases(value)
+			if err != nil {
+				return fmt.Errorf("setoption: cannot load TablebasePath: %v", err)
+			}
+			engine.send("info string loaded %d tablebases from %s", tableNum, value)
+			return nil
+		},
+	},
+	{
+		name: "SyzygyPath", kind: "string", def: "<empty>",
+		apply: func(engine *uciEngine, value string) error {
+			board.ClearSyzygy()
+			engine.hashTable.Clear()
+			if value == "" || value == "<empty>" {
+				return nil
+			}
+			// several directories are separated like in the PATH environment variable
+			tableNum, err := board.LoadSyzygy(value)
+			if err != nil {
+				return fmt.Errorf("setoption: cannot load SyzygyPath: %v", err)
+			}
+			engine.send("info string found %d Syzygy tables in %s", tableNum, value)
+			return nil
+		},
+	},
+	{
+		name: "OwnBook", kind: "check", def: "false",
+		apply: func(engine *uciEngine, value string) error {
+			ownBook, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("setoption: invalid OwnBook value %s", value)
+			}
+			engine.ownBook = ownBook
+			return nil
+		},
+	},
+	{
+		name: "BookFile", kind: "string", def: "<empty>",
+		apply: func(engine *uciEngine, value string) error {
+			engine.book = nil
+			if value == "" || value == "<empty>" {
+				return nil
+			}
+			book, err := board.LoadBook(value)
+			if err != nil {
+				return fmt.Errorf("setoption: cannot load BookFile: %v", err)
+			}
+			engine.book = book
+			engine.send("info string loaded %d book entries from %s", book.Size(), value)
+			return nil
+		},
+	},
+	{
+		name: "BestBookMove", kind: "check", def: "false",
+		apply: func(engine *uciEngine, value string) error {
+			// otherwise book moves are chosen at random, in proportion to their weights
+			bestBookMove, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("setoption: invalid BestBookMove value %s", value)
+			}
+			engine.bestBookMove = bestBookMove
+			return nil
+		},
+	},
+}
+
+// uciEngine holds the state of the engine between UCI commands
+type uciEngine struct {
+	board     board.Board
+	hashTable *board.HashTable // kept between searches of the same game
+	pawnTable *board.PawnHashTable
+	out       io.Writer
+	outLock   sync.Mutex // guards out, search output may come from another goroutine
+
+	book         *board.Book // opening book, nil if no BookFile is set
+	ownBook      bool        // play moves from the book instead of searching
+	bestBookMove bool        // play the book move with the highest weight instead of a random one
+	random       *rand.Rand
+
+	searchInfo *board.SearchInfo // info of the currently running search, nil if idle
+	stopChan   chan struct{}     // closed when `stop` or `quit` is received during a search
+	searching  sync.WaitGroup
+}
+
+func newUciEngine(out io.Writer) *uciEngine {
+	engine := &uciEngine{
+		out:       out,
+		hashTable: board.NewHashTable(board.DefaultHashSize),
+		pawnTable: board.NewPawnHashTable(board.DefaultPawnHashSize),
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	engine.board.ParseFen(board.StartingPosition)
+	return engine
+}
+
+// send writes a single line to the GUI
+func (engine *uciEngine) send(format string, args ...interface{}) {
+	engine.outLock.Lock()
+	defer engine.outLock.Unlock()
+	fmt.Fprintf(engine.out, format+"\n", args...)
+}
+
+// sendError reports an error to the GUI without interrupting the engine
+func (engine *uciEngine) sendError(err error) {
+	engine.send("info string %s", err)
+}
+
+// Loop reads UCI commands line by line until `quit` is received or input is exhausted
+func (engine *uciEngine) Loop(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !engine.handle(line) {
+			return
+		}
+	}
+	// input was closed without `quit`
+	engine.stopSearch()
+}
+
+// handle executes a single command. Returns false when the engine should exit
+func (engine *uciEngine) handle(line string) bool {
+	fields := strings.Fields(line)
+	command, args := fields[0], fields[1:]
+
+	switch command {
+	case "uci":
+		engine.send("id name %s", EngineName)
+		engine.send("id author %s", EngineAuthor)
+		for idx := range uciOptions {
+			engine.send("%s", &uciOptions[idx])
+		}
+		engine.send("uciok")
+	case "isready":
+		engine.send("readyok")
+	case "ucinewgame":
+		engine.stopSearch()
+		engine.board.ParseFen(board.StartingPosition)
+		engine.hashTable.Clear()
+		engine.pawnTable.Clear()
+	case "position":
+		engine.stopSearch()
+		if err := engine.position(args); err != nil {
+			engine.sendError(err)
+		}
+	case "go":
+		engine.stopSearch()
+		if err := engine.goSearch(args); err != nil {
+			engine.sendError(err)
+		}
+	case "stop":
+		engine.stopSearch()
+	case "setoption":
+		engine.stopSearch()
+		if err := engine.setOption(args); err != nil {
+			engine.sendError(err)
+		}
+	case "debug", "register":
+		// not supported, silently ignored as allowed by the protocol
+	case "quit":
+		engine.stopSearch()
+		return false
+	default:
+		engine.send("info string Unknown command: %s", line)
+	}
+	return true
+}
+
+// position handles `position [startpos | fen <fen>] [moves <move1> ... <moveN>]`
+func (engine *uciEngine) position(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("position: missing startpos or fen")
+	}
+
+	movesIdx := len(args)
+	for idx, arg := range args {
+		if arg == "moves" {
+			movesIdx = idx
+			break
+		}
+	}
+
+	var fen string
+	switch args[0] {
+	case "startpos":
+		fen = board.StartingPosition
+	case "fen":
+		fen = strings.Join(args[1:movesIdx], " ")
+	default:
+		return fmt.Errorf("position: expected startpos or fen, got %s", args[0])
+	}
+
+	if err := engine.board.ParseFen(fen); err != nil {
+		// leave the engine in a well defined state
+		engine.board.ParseFen(board.StartingPosition)
+		return fmt.Errorf("Invalid FEN (%s): %v", fen, err)
+	}
+
+	if movesIdx+1 < len(args) {
+		if err := engine.board.MakeMoves(strings.Join(args[movesIdx+1:], " ")); err != nil {
+			// don't search a position in the middle of the move list
+			engine.board.ParseFen(board.StartingPosition)
+			return err
+		}
+	}
+	return nil
+}
+
+// searchLimits limits parsed from the arguments of `go`
+type searchLimits struct {
+	timeLeft  [2]time.Duration // wtime, btime
+	increment [2]time.Duration // winc, binc
+	movesToGo int
+	moveTime  time.Duration
+	depth     int
+	infinite  bool
+}
+
+// parseGoArgs parses `go [wtime x] [btime x] [winc x] [binc x] [movestogo x] [movetime x] [depth x] [infinite]`
+func parseGoArgs(args []string) (limits searchLimits, err error) {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if arg == "infinite" {
+			limits.infinite = true
+			continue
+		}
+		if arg == "ponder" {
+			continue
+		}
+
+		if idx+1 >= len(args) {
+			return limits, fmt.Errorf("go: missing value for %s", arg)
+		}
+		idx++
+		value, convErr := strconv.Atoi(args[idx])
+		if convErr != nil {
+			return limits, fmt.Errorf("go: invalid value for %s: %s", arg, args[idx])
+		}
+		milliseconds := time.Duration(value) * time.Millisecond
+
+		switch arg {
+		case "wtime":
+			limits.timeLeft[board.White] = milliseconds
+		case "btime":
+			limits.timeLeft[board.Black] = milliseconds
+		case "winc":
+			limits.increment[board.White] = milliseconds
+		case "binc":
+			limits.increment[board.Black] = milliseconds
+		case "movestogo":
+			limits.movesToGo = value
+		case "movetime":
+			limits.moveTime = milliseconds
+		case "depth":
+			limits.depth = value
+		case "nodes", "mate":
+			// not supported, the search is limited by the remaining arguments
+		default:
+			return limits, fmt.Errorf("go: unknown argument %s", arg)
+		}
+	}
+	return limits, nil
+}
+
+// moveOverhead time reserved for communication with the GUI
+const moveOverhead = 50 * time.Millisecond
+
+// defaultMovesToGo number of moves the remaining time is split into when `movestogo` is not given
+const defaultMovesToGo = 30
+
+// allocateTime returns how long the search for the given side may run. Returns false if there is no time limit
+func (limits *searchLimits) allocateTime(side int) (time.Duration, bool) {
+	if limits.infinite {
+		return 0, false
+	}
+	if limits.moveTime > 0 {
+		return maxDuration(limits.moveTime-moveOverhead, limits.moveTime/2), true
+	}
+	if limits.timeLeft[side] <= 0 {
+		return 0, false
+	}
+
+	movesToGo := limits.movesToGo
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	allocated := limits.timeLeft[side]/time.Duration(movesToGo) + limits.increment[side]/2
+	// never use more than what is left on the clock
+	allocated = minDuration(allocated, limits.timeLeft[side]-moveOverhead)
+	return maxDuration(allocated, time.Millisecond), true
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// goSearch handles `go` by starting a search in the background. The result is reported with `bestmove`
+func (engine *uciEngine) goSearch(args []string) error {
+	if len(args) > 0 && args[0] == "perft" {
+		return engine.perft(args[1:])
+	}
+
+	limits, err := parseGoArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// book moves are played without a search, except when analysing
+	if move, found := engine.bookMove(); found && !limits.infinite {
+		engine.send("bestmove %s", board.GetMoveString(move))
+		return nil
+	}
+
+	info := &board.SearchInfo{
+		Depth:         limits.depth,
+		Output:        engine.sendInfo,
+		HashTable:     engine.hashTable,
+		PawnHashTable: engine.pawnTable,
+	}
+	if searchTime, ok := limits.allocateTime(engine.board.Side); ok {
+		info.TimeSet = true
+		info.StopTime = time.Now().Add(searchTime)
+	}
+
+	// search a copy so the board can't be modified while the search is running
+	searchBoard := engine.board
+	engine.searchInfo = info
+	engine.stopChan = make(chan struct{})
+	stopChan := engine.stopChan
+
+	engine.searching.Add(1)
+	go func() {
+		defer engine.searching.Done()
+		result := searchBoard.Search(info)
+
+		// in infinite mode bestmove may only be sent after `stop`
+		if limits.infinite {
+			<-stopChan
+		}
+		if result.BestMove == 0 {
+			engine.send("bestmove 0000")
+		} else {
+			engine.send("bestmove %s", board.GetMoveString(result.BestMove))
+		}
+	}()
+	return nil
+}
+
+// bookMove returns a move from the opening book if the engine plays its own book moves
+func (engine *uciEngine) bookMove() (int, bool) {
+	if !engine.ownBook || engine.book == nil {
+		return 0, false
+	}
+	if engine.bestBookMove {
+		return engine.book.BestMove(&engine.board)
+	}
+	return engine.book.RandomMove(&engine.board, engine.random)
+}
+
+// perft handles `go perft <depth>` by printing the number of leaf nodes for every root move
+func (engine *uciEngine) perft(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("go perft: expected a depth")
+	}
+	depth, err := strconv.Atoi(args[0])
+	if err != nil || depth < 1 {
+		return fmt.Errorf("go perft: invalid depth %s", args[0])
+	}
+
+	var total uint64
+	for _, result := range board.PerftDivide(&engine.board, depth) {
+		engine.send("%s", result)
+		total += result.Nodes
+	}
+	engine.send("")
+	engine.send("Nodes searched: %d", total)
+	return nil
+}
+
+// stopSearch stops the running search (if any) and waits for it to report its best move
+func (engine *uciEngine) stopSearch() {
+	if engine.searchInfo == nil {
+		return
+	}
+	engine.searchInfo.Stop()
+	close(engine.stopChan)
+	engine.searching.Wait()
+	engine.searchInfo = nil
+}
+
+// sendInfo reports the result of a completed search iteration
+func (engine *uciEngine) sendInfo(result board.SearchResult) {
+	score := fmt.Sprintf("cp %d", result.Score)
+	if mateIn := board.MateIn(result.Score); mateIn != 0 {
+		score = fmt.Sprintf("mate %d", mateIn)
+	}
+
+	milliseconds := result.Time.Milliseconds()
+	nps := int64(result.Nodes) * 1000 / (milliseconds + 1)
+
+	pv := make([]string, len(result.PV))
+	for idx, move := range result.PV {
+		pv[idx] = board.GetMoveString(move)
+	}
+
+	engine.send("info depth %d score %s nodes %d nps %d hashfull %d tbhits %d time %d pv %s",
+		result.Depth, score, result.Nodes, nps, result.HashFull, result.TBHits, milliseconds, strings.Join(pv, " "))
+}
+
+// setOption handles `setoption name <id> [value <x>]`
+func (engine *uciEngine) setOption(args []string) error {
+	if len(args) < 2 || args[0] != "name" {
+		return fmt.Errorf("setoption: expected `name <id> [value <x>]`")
+	}
+
+	valueIdx := len(args)
+	for idx, arg := range args {
+		if arg == "value" {
+			valueIdx = idx
+			break
+		}
+	}
+
+	name := strings.Join(args[1:valueIdx], " ")
+	value := ""
+	if valueIdx < len(args) {
+		value = strings.Join(args[valueIdx+1:], " ")
+	}
+
+	for idx := range uciOptions {
+		if strings.EqualFold(uciOptions[idx].name, name) {
+			return uciOptions[idx].apply(engine, value)
+		}
+	}
+	return fmt.Errorf("setoption: unknown option %s", name)
+}
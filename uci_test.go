@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AngelVI13/platypus/board"
+)
+
+func runUci(commands string) string {
+	board.InitHashKeys()
+
+	var out bytes.Buffer
+	engine := newUciEngine(&out)
+	engine.Loop(strings.NewReader(commands))
+	return out.String()
+}
+
+func TestUciHandshake(t *testing.T) {
+	out := runUci("uci\nisready\nquit\n")
+
+	for _, expected := range []string{"id name " + EngineName, "uciok", "readyok"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestUciPositionAndGo(t *testing.T) {
+	out := runUci("position startpos moves e2e4 e7e5 g1f3\ngo depth 1\nquit\n")
+
+	if !strings.Contains(out, "bestmove ") {
+		t.Errorf("Expected a bestmove in output:\n%s", out)
+	}
+	if strings.Contains(out, "info string") {
+		t.Errorf("Unexpected error in output:\n%s", out)
+	}
+}
+
+func TestUciErrorsDoNotCrash(t *testing.T) {
+	commands := []string{
+		"position fen 8/8",
+		"position fen rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1",
+		"position startpos moves e2e5",
+		"position",
+		"setoption name DoesNotExist value 1",
+		"nonsense",
+		"isready",
+	}
+	out := runUci(strings.Join(commands, "\n"))
+
+	if strings.Count(out, "info string") != 6 {
+		t.Errorf("Expected 6 errors reported as info string, got:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "readyok\n") {
+		t.Errorf("Engine did not keep processing commands after errors:\n%s", out)
+	}
+}
+
+func TestUciCommandDuringInfiniteSearch(t *testing.T) {
+	done := make(chan string)
+	go func() {
+		done <- runUci("go infinite\nposition startpos moves e2e4\ngo depth 1\nquit\n")
+	}()
+
+	select {
+	case out := <-done:
+		if strings.Count(out, "bestmove ") != 2 {
+			t.Errorf("Expected the infinite search to be stopped by position, got:\n%s", out)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Engine hangs after a command during an infinite search")
+	}
+}
+
+func TestUciInvalidMoveResetsPosition(t *testing.T) {
+	board.InitHashKeys()
+	var out bytes.Buffer
+	engine := newUciEngine(&out)
+
+	engine.handle("position startpos moves e2e4 e7e5 e2e5")
+	if !strings.Contains(out.String(), "info string") {
+		t.Errorf("Expected the illegal move to be reported:\n%s", out.String())
+	}
+	if fen := engine.board.Fen(); fen != board.StartingPosition {
+		t.Errorf("Expected the starting position after an illegal move, got %s", fen)
+	}
+}
+
+func TestUciCheckmatedPosition(t *testing.T) {
+	// fool's mate, white has no legal moves
+	out := runUci("position startpos moves f2f3 e7e5 g2g4 d8h4\ngo\n")
+
+	if !strings.Contains(out, "bestmove 0000") {
+		t.Errorf("Expected null bestmove when there are no legal moves:\n%s", out)
+	}
+}
+
+func TestUciHashOption(t *testing.T) {
+	out := runUci("uci\nsetoption name Hash value 2\nsetoption name Hash value 0\nsetoption name Clear Hash\n" +
+		"position startpos\ngo depth 2\n")
+
+	if !strings.Contains(out, "option name Hash type spin") {
+		t.Errorf("Hash option not declared:\n%s", out)
+	}
+	if strings.Count(out, "info string") != 1 {
+		t.Errorf("Expected exactly one error for the invalid Hash value:\n%s", out)
+	}
+	if !strings.Contains(out, "bestmove ") {
+		t.Errorf("Expected a bestmove in output:\n%s", out)
+	}
+}
+
+func TestUciEvalFileOption(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "params.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"pawnValue": 90}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runUci("uci\nsetoption name EvalFile value " + filename + "\nsetoption name EvalFile value missing.json\n" +
+		"position startpos\ngo depth 2\n")
+
+	if !strings.Contains(out, "option name EvalFile type string default <empty>") {
+		t.Errorf("EvalFile option not declared:\n%s", out)
+	}
+	if strings.Count(out, "info string") != 1 || !strings.Contains(out, "missing.json") {
+		t.Errorf("Expected exactly one error for the missing file:\n%s", out)
+	}
+	if !strings.Contains(out, "bestmove ") {
+		t.Errorf("Expected a bestmove in output:\n%s", out)
+	}
+}
+
+func TestUciTablebasePathOption(t *testing.T) {
+	dir := t.TempDir()
+	if err := runCommand([]string{"tbgen", "-dir", dir, "KQvK"}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	defer board.ClearTablebases()
+
+	out := runUci("uci\nsetoption name TablebasePath value " + dir + "\n" +
+		"position fen 8/8/8/4k3/8/8/8/3QK3 w - - 0 1\ngo depth 2\n")
+
+	if !strings.Contains(out, "option name TablebasePath type string default <empty>") {
+		t.Errorf("TablebasePath option not declared:\n%s", out)
+	}
+	if !strings.Contains(out, "loaded 1 tablebases") {
+		t.Errorf("Expected the table to be loaded:\n%s", out)
+	}
+	if !strings.Contains(out, "bestmove ") {
+		t.Errorf("Expected a bestmove in output:\n%s", out)
+	}
+}
+
+func TestUciSyzygyPathOption(t *testing.T) {
+	dir := t.TempDir()
+	defer board.ClearSyzygy()
+
+	out := runUci("uci\nsetoption name SyzygyPath value " + dir + "\n" +
+		"setoption name SyzygyPath value " + filepath.Join(dir, "missing") + "\n")
+
+	if !strings.Contains(out, "option name SyzygyPath type string default <empty>") {
+		t.Errorf("SyzygyPath option not declared:\n%s", out)
+	}
+	if !strings.Contains(out, "found 0 Syzygy tables") {
+		t.Errorf("Expected the directory to be searched:\n%s", out)
+	}
+	if !strings.Contains(out, "cannot load SyzygyPath") {
+		t.Errorf("Expected an error for a missing directory:\n%s", out)
+	}
+}
+
+func TestUciOwnBookOption(t *testing.T) {
+	board.InitHashKeys()
+	start := board.Board{}
+	start.ParseFen(board.StartingPosition)
+
+	// the only book move of the starting position is d2d4 (from d2 = 11 to d4 = 27 in Polyglot squares)
+	var book bytes.Buffer
+	binary.Write(&book, binary.BigEndian, struct {
+		Key          uint64
+		Move, Weight uint16
+		Learn        uint32
+	}{start.PolyglotKey(), 27 | 11<<6, 1, 0})
+	filename := filepath.Join(t.TempDir(), "book.bin")
+	if err := ioutil.WriteFile(filename, book.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runUci("uci\nsetoption name BookFile value " + filename + "\nsetoption name OwnBook value true\n" +
+		"position startpos\ngo depth 3\nsetoption name OwnBook value maybe\n")
+
+	for _, expected := range []string{
+		"option name OwnBook type check default false",
+		"option name BookFile type string default <empty>",
+		"loaded 1 book entries",
+		"bestmove d2d4",
+		"invalid OwnBook value maybe",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "info depth") {
+		t.Errorf("Expected the book move without a search:\n%s", out)
+	}
+}
+
+func TestUciSendInfo(t *testing.T) {
+	board.InitHashKeys()
+
+	var out bytes.Buffer
+	engine := newUciEngine(&out)
+	move := board.GetMoveInt(52, 36, board.NoPiece, board.NoPiece, board.MoveFlagPawnStart)
+	engine.sendInfo(board.SearchResult{
+		BestMove: move, Score: board.Infinite - 3, PV: []int{move}, Depth: 3, Nodes: 1000, HashFull: 5, TBHits: 7,
+	})
+
+	expected := "info depth 3 score mate 2 nodes 1000 nps 1000000 hashfull 5 tbhits 7 time 0 pv e2e4\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestUciPerft(t *testing.T) {
+	out := runUci("position startpos moves e2e4\ngo perft 2\ngo perft x\n")
+
+	if !strings.Contains(out, "e7e5: 29\n") || !strings.Contains(out, "Nodes searched: 600\n") {
+		t.Errorf("Incorrect perft output:\n%s", out)
+	}
+	if strings.Count(out, "info string") != 1 {
+		t.Errorf("Expected an error for the invalid depth:\n%s", out)
+	}
+}